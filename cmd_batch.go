@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/load"
+	"github.com/crossplane-contrib/xrender/pkg/output"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// BatchCmd renders many XRs using one Composition.
+type BatchCmd struct {
+	CompositeResources string `arg:"" help:"A stream or directory of YAML manifests containing the Composite Resources (XRs) to render. Each XR may have observed composed resources in a directory named for it, alongside the stream or in the directory."`
+	Composition        string `arg:"" help:"A stream or directory of YAML manifests containing the Composition to use, or Compositions and CompositionRevisions to select it from. Resources mode Compositions are rendered using function-patch-and-transform."`
+	Functions          string `arg:"" help:"A stream or directory of YAML manifests containing the Composition Functions to use."`
+
+	OutputDir string `required:"" type:"path" help:"A directory to which to write the output of each XR, as <xr-name>.yaml."`
+
+	EnvironmentConfigs []string `short:"e" help:"An optional stream or directory of YAML manifests containing EnvironmentConfigs the Composition may select."`
+	Revision           int64    `help:"Render using this revision number of the selected Composition, rather than the revision each XR would use. Requires CompositionRevisions."`
+	XRD                string   `name:"xrd" type:"existingfile" help:"An optional YAML manifest containing the CompositeResourceDefinition (XRD) that defines the XRs. Its OpenAPI schema is used to default and validate the XRs, like the API server would."`
+	RuntimeConfig      []string `type:"existingfile" help:"An optional YAML manifest configuring how to run Functions. Takes precedence over Function annotations. May be repeated; later files take precedence."`
+
+	Concurrency    int           `help:"How many XRs to render at once." default:"8"`
+	Timeout        time.Duration `help:"How long to run before timing out." default:"10m"`
+	IncludeResults bool          `short:"r" default:"true" help:"Include Results in the output. Results are emitted as a 'fake' KRM-like object of kind: Result."`
+}
+
+// Run the batch command.
+func (c *BatchCmd) Run(log logging.Logger, rec *functions.ContainerRecord) error {
+	xrs, err := load.CompositeResources(c.CompositeResources)
+	if err != nil {
+		return errors.Wrapf(err, "cannot load composite resources from %q", c.CompositeResources)
+	}
+
+	shared, err := c.inputs().LoadShared(log)
+	if err != nil {
+		return err
+	}
+
+	// XRs that we can't load the inputs for fail individually, like XRs that
+	// we can't render.
+	results := make([]render.BatchResult, len(xrs))
+	ins := make([]render.Inputs, 0, len(xrs))
+	idx := make([]int, 0, len(xrs))
+	seen := map[string]bool{}
+	for i, xr := range xrs {
+		name := xr.GetName()
+		if seen[name] {
+			return errors.Errorf("more than one composite resource is named %q", name)
+		}
+		seen[name] = true
+		results[i].Name = name
+
+		// Logging what we selected for every XR at info level would drown
+		// out everything else.
+		in, err := InputsFor(shared, xr, observedResourcesFor(c.CompositeResources, name), log, log.Debug)
+		if err != nil {
+			results[i].Error = errors.Wrap(err, "cannot load inputs")
+			continue
+		}
+		ins = append(ins, in)
+		idx = append(idx, i)
+	}
+
+	ReapLeftoverContainers(log)
+
+	ctx, stop := NotifyContext()
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	rendered, err := render.RenderBatch(ctx, ins, c.Concurrency, render.WithLogger(log), render.WithFunctionOptions(functions.WithContainerRecord(rec)))
+	if err != nil {
+		return err
+	}
+	for i, r := range rendered {
+		// Show the defaulted XR if we have an XRD.
+		if c.XRD != "" && r.Error == nil {
+			r.Outputs = render.WithCompositeSpec(r.Outputs, ins[i].CompositeResource)
+		}
+		results[idx[i]] = r
+	}
+
+	return c.write(results)
+}
+
+// inputs returns the flags to load the inputs shared by every XR.
+func (c *BatchCmd) inputs() *InputFlags {
+	return &InputFlags{
+		Composition:        c.Composition,
+		Functions:          c.Functions,
+		EnvironmentConfigs: c.EnvironmentConfigs,
+		Revision:           c.Revision,
+		XRD:                c.XRD,
+		RuntimeConfig:      c.RuntimeConfig,
+	}
+}
+
+// observedResourcesFor returns the directory of observed resources for the
+// named XR, if one exists alongside the stream or in the directory the XR was
+// loaded from.
+func observedResourcesFor(xrs, name string) []string {
+	dir := xrs
+	if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && fi.IsDir() {
+		return []string{filepath.Join(dir, name)}
+	}
+	return nil
+}
+
+// write the output of each successfully rendered XR to the output directory,
+// then a summary of the batch to stderr. It returns an error if any XR failed.
+func (c *BatchCmd) write(results []render.BatchResult) error {
+	if err := os.MkdirAll(c.OutputDir, 0o750); err != nil {
+		return errors.Wrapf(err, "cannot create output directory %q", c.OutputDir)
+	}
+
+	failed := 0
+	for i := range results {
+		r := &results[i]
+		if r.Error == nil {
+			r.Error = c.writeOutputs(r.Name, r.Outputs)
+		}
+		if r.Error != nil {
+			failed++
+		}
+	}
+
+	output.WriteBatchSummary(os.Stderr, results)
+	if failed > 0 {
+		return errors.Errorf("cannot render %d of %d composite resources", failed, len(results))
+	}
+	return nil
+}
+
+func (c *BatchCmd) writeOutputs(name string, out render.Outputs) error {
+	buf := &bytes.Buffer{}
+	if err := output.WriteOutputs(buf, out, c.IncludeResults); err != nil {
+		return err
+	}
+	file := filepath.Join(c.OutputDir, name+".yaml")
+	return errors.Wrapf(os.WriteFile(file, buf.Bytes(), 0o600), "cannot write output to %q", file)
+}
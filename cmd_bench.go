@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/load"
+	"github.com/crossplane-contrib/xrender/pkg/output"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// BenchCmd repeatedly renders XRs and reports how long rendering took.
+type BenchCmd struct {
+	CompositeResources string `arg:"" help:"A stream or directory of YAML manifests containing the Composite Resources (XRs) to render. Renders cycle through the XRs. Each XR may have observed composed resources in a directory named for it, alongside the stream or in the directory."`
+	Composition        string `arg:"" help:"A stream or directory of YAML manifests containing the Composition to use, or Compositions and CompositionRevisions to select it from. Resources mode Compositions are rendered using function-patch-and-transform."`
+	Functions          string `arg:"" help:"A stream or directory of YAML manifests containing the Composition Functions to use."`
+
+	EnvironmentConfigs []string `short:"e" help:"An optional stream or directory of YAML manifests containing EnvironmentConfigs the Composition may select."`
+	Revision           int64    `help:"Render using this revision number of the selected Composition, rather than the revision each XR would use. Requires CompositionRevisions."`
+	XRD                string   `name:"xrd" type:"existingfile" help:"An optional YAML manifest containing the CompositeResourceDefinition (XRD) that defines the XRs. Its OpenAPI schema is used to default and validate the XRs, like the API server would."`
+	RuntimeConfig      []string `type:"existingfile" help:"An optional YAML manifest configuring how to run Functions. Takes precedence over Function annotations. May be repeated; later files take precedence."`
+
+	Renders     int           `short:"n" help:"How many times to render." default:"100"`
+	Concurrency int           `help:"How many renders to run at once." default:"1"`
+	Timeout     time.Duration `help:"How long to run before timing out." default:"10m"`
+	Format      string        `help:"Format in which to report render latency and response sizes." enum:"table,json" default:"table"`
+}
+
+// Run the bench command.
+func (c *BenchCmd) Run(log logging.Logger, rec *functions.ContainerRecord) error {
+	if c.Renders < 1 {
+		return errors.New("--renders must be at least 1")
+	}
+	if c.Concurrency < 1 {
+		return errors.New("--concurrency must be at least 1")
+	}
+
+	xrs, err := load.CompositeResources(c.CompositeResources)
+	if err != nil {
+		return errors.Wrapf(err, "cannot load composite resources from %q", c.CompositeResources)
+	}
+
+	f := &InputFlags{
+		Composition:        c.Composition,
+		Functions:          c.Functions,
+		EnvironmentConfigs: c.EnvironmentConfigs,
+		Revision:           c.Revision,
+		XRD:                c.XRD,
+		RuntimeConfig:      c.RuntimeConfig,
+	}
+	shared, err := f.LoadShared(log)
+	if err != nil {
+		return err
+	}
+
+	ins := make([]render.Inputs, 0, len(xrs))
+	for _, xr := range xrs {
+		// Logging what we selected for every XR at info level would drown
+		// out everything else.
+		in, err := InputsFor(shared, xr, observedResourcesFor(c.CompositeResources, xr.GetName()), log, log.Debug)
+		if err != nil {
+			return errors.Wrapf(err, "cannot load inputs for composite resource %q", xr.GetName())
+		}
+		ins = append(ins, in)
+	}
+
+	ReapLeftoverContainers(log)
+
+	ctx, stop := NotifyContext()
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	log.Info("Running benchmark", "renders", c.Renders, "concurrency", c.Concurrency, "composite-resources", len(ins))
+	b, err := render.RenderBenchmark(ctx, ins, c.Renders, c.Concurrency, render.WithLogger(log), render.WithFunctionOptions(functions.WithContainerRecord(rec)))
+	if err != nil {
+		return err
+	}
+
+	return output.WriteBenchmark(os.Stdout, b, c.Format)
+}
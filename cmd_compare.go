@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/output"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// CompareCmd compares how an XR renders using two Compositions.
+type CompareCmd struct {
+	CompositeResource string `arg:"" type:"existingfile" help:"A YAML manifest containing the Composite Resource (XR) to render."`
+	Functions         string `arg:"" help:"A stream or directory of YAML manifests containing the Composition Functions to use."`
+
+	Base          string `required:"" help:"A stream or directory of YAML manifests containing the base Composition, e.g. before a change."`
+	Head          string `required:"" help:"A stream or directory of YAML manifests containing the head Composition, e.g. after a change."`
+	BaseFunctions string `help:"A stream or directory of YAML manifests containing the Composition Functions to use with the base Composition. Defaults to the Functions argument."`
+	HeadFunctions string `help:"A stream or directory of YAML manifests containing the Composition Functions to use with the head Composition. Defaults to the Functions argument."`
+
+	ObservedResources  []string `short:"o" help:"An optional stream or directory of YAML manifests mocking the observed state of composed resources."`
+	EnvironmentConfigs []string `short:"e" help:"An optional stream or directory of YAML manifests containing EnvironmentConfigs the Compositions may select."`
+	XRD                string   `name:"xrd" type:"existingfile" help:"An optional YAML manifest containing the CompositeResourceDefinition (XRD) that defines the XR. Its OpenAPI schema is used to default and validate the XR, like the API server would."`
+	RuntimeConfig      []string `type:"existingfile" help:"An optional YAML manifest configuring how to run Functions. Takes precedence over Function annotations. May be repeated; later files take precedence."`
+
+	Timeout          time.Duration `help:"How long to run before timing out." default:"1m"`
+	IncludeUnchanged bool          `help:"Include composed resources that didn't change in the output."`
+	FailOnChanges    bool          `help:"Return an error if the head Composition renders differently from the base Composition."`
+}
+
+// Run the compare command.
+func (c *CompareCmd) Run(log logging.Logger, rec *functions.ContainerRecord) error {
+	base, err := c.inputs(c.Base, c.BaseFunctions).Load(log)
+	if err != nil {
+		return errors.Wrap(err, "cannot load base inputs")
+	}
+	head, err := c.inputs(c.Head, c.HeadFunctions).Load(log)
+	if err != nil {
+		return errors.Wrap(err, "cannot load head inputs")
+	}
+
+	ReapLeftoverContainers(log)
+
+	ctx, stop := NotifyContext()
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	bout, hout, err := render.RenderBoth(ctx, base, head, render.WithLogger(log), render.WithFunctionOptions(functions.WithContainerRecord(rec)))
+	if err != nil {
+		return err
+	}
+
+	diff := render.Compare(bout, hout)
+	if err := output.WriteComparison(os.Stdout, diff, c.IncludeUnchanged); err != nil {
+		return err
+	}
+	if c.FailOnChanges && diff.Changed() {
+		return errors.New("head Composition renders differently from base Composition")
+	}
+	return nil
+}
+
+// inputs returns the flags to load the supplied Composition and Functions.
+func (c *CompareCmd) inputs(comp, fns string) *InputFlags {
+	if fns == "" {
+		fns = c.Functions
+	}
+	return &InputFlags{
+		CompositeResource:  c.CompositeResource,
+		Composition:        comp,
+		Functions:          fns,
+		ObservedResources:  c.ObservedResources,
+		EnvironmentConfigs: c.EnvironmentConfigs,
+		XRD:                c.XRD,
+		RuntimeConfig:      c.RuntimeConfig,
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane-contrib/xrender/pkg/load"
+	"github.com/crossplane-contrib/xrender/pkg/output"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// ConvertCmd converts a Resources mode Composition to Pipeline mode.
+type ConvertCmd struct {
+	Composition string `arg:"" type:"existingfile" help:"A YAML manifest containing the Resources mode Composition to convert."`
+
+	FunctionName string `default:"function-patch-and-transform" help:"The name of the function-patch-and-transform Function the converted Composition should use."`
+	Output       string `short:"o" type:"path" help:"A file to write the converted Composition to. Defaults to stdout."`
+}
+
+// Run the convert command.
+func (c *ConvertCmd) Run() error {
+	comp, err := load.Composition(c.Composition)
+	if err != nil {
+		return errors.Wrapf(err, "cannot load Composition from %q", c.Composition)
+	}
+
+	pc, err := render.ConvertToPipeline(comp, c.FunctionName)
+	if err != nil {
+		return errors.Wrapf(err, "cannot convert Composition %q", comp.GetName())
+	}
+
+	if c.Output == "" {
+		return output.WriteComposition(os.Stdout, pc)
+	}
+
+	f, err := os.Create(c.Output)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create %q", c.Output)
+	}
+	if err := output.WriteComposition(f, pc); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return errors.Wrapf(f.Close(), "cannot close %q", c.Output)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/output"
+)
+
+// DebugCmd steps through a Composition Function pipeline interactively.
+type DebugCmd struct {
+	InputFlags
+
+	IncludeResults bool   `short:"r" default:"true" help:"Include Results in the output. Results are emitted as a 'fake' KRM-like object of kind: Result."`
+	Editor         string `env:"EDITOR" default:"vi" help:"The editor used to edit a step's input."`
+}
+
+// Run the debug command.
+func (c *DebugCmd) Run(log logging.Logger, rec *functions.ContainerRecord) error {
+	in, err := c.Load(log)
+	if err != nil {
+		return err
+	}
+
+	ReapLeftoverContainers(log)
+
+	// There's no timeout - the user may take as long as they like.
+	ctx, stop := NotifyContext()
+	defer stop()
+
+	fns, err := functions.Referenced(in.Functions, in.Composition.Spec.Pipeline)
+	if err != nil {
+		return err
+	}
+	rf, err := functions.Start(ctx, fns, functions.WithLogger(log), functions.WithContainerRecord(rec))
+	if err != nil {
+		return errors.Wrap(err, "cannot start Functions")
+	}
+	defer rf.Cleanup()
+
+	// The debugger talks to the user on stderr, so stdout contains only the
+	// rendered output.
+	out, err := NewDebugger(os.Stdin, os.Stderr, c.Editor, rf).Run(ctx, in)
+	if err != nil {
+		return errors.Wrap(err, "cannot debug composite resource")
+	}
+
+	return output.WriteOutputs(os.Stdout, out, c.IncludeResults)
+}
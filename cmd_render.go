@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/load"
+	"github.com/crossplane-contrib/xrender/pkg/output"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+	"github.com/crossplane-contrib/xrender/pkg/timing"
+)
+
+// RenderCmd renders an XR.
+type RenderCmd struct {
+	InputFlags
+
+	Timeout        time.Duration `help:"How long to run before timing out." default:"1m"`
+	IncludeResults bool          `short:"r" default:"true" help:"Include Results in the output. Results are emitted as a 'fake' KRM-like object of kind: Result."`
+	TraceDir       string        `type:"path" help:"An optional directory to which to write the request, response and desired state diff of each pipeline step."`
+	Timings        bool          `help:"Report how long each phase of rendering took to stderr, including pulling, starting and waiting for each Function, each pipeline step and encoding the output."`
+	TimingsFormat  string        `enum:"table,json" default:"table" help:"Format of the --timings report. One of table or json."`
+
+	FromStep      string `help:"Start rendering from this pipeline step, skipping the steps before it."`
+	UntilStep     string `help:"Stop rendering after this pipeline step, skipping the steps after it."`
+	PipelineState string `type:"existingfile" help:"An optional YAML RunFunctionRequest or RunFunctionResponse (e.g. from --trace-dir) whose desired state and context are sent to the first step rendered."`
+
+	Iterations     int      `help:"How many reconciles to simulate. The desired composed resources of each iteration become the observed composed resources of the next. Defaults to 1, or 10 with --until-stable."`
+	UntilStable    bool     `help:"Simulate reconciles until the desired state stops changing, up to --iterations times."`
+	StatusFixtures []string `help:"An optional stream or directory of YAML manifests containing the status of composed resources. Used to observe desired composed resources with the same composition resource name between iterations."`
+
+	FakeProvider    bool     `help:"Simulate a provider creating and reconciling composed resources. Fills in the names, external names, Ready and Synced conditions and status.atProvider of observed composed resources, including those observed between iterations."`
+	StatusTemplates []string `help:"An optional stream or directory of YAML manifests containing the status of composed resources. Used by --fake-provider to fill in the status of composed resources of the same apiVersion and kind."`
+
+	FailOnDeletions bool `help:"Return an error if any observed composed resources would be deleted because the Function pipeline no longer desires them."`
+
+	Assertions       []string `short:"a" help:"An optional stream or directory of YAML manifests containing CEL assertions about the rendered output. Violated assertions are emitted as Results."`
+	FailOnViolations bool     `help:"Return an error if any assertion with severity Fatal is violated."`
+}
+
+// Run the render command.
+func (c *RenderCmd) Run(log logging.Logger, rec *functions.ContainerRecord) error {
+	in, err := c.Load(log)
+	if err != nil {
+		return err
+	}
+	in.FromStep = c.FromStep
+	in.UntilStep = c.UntilStep
+
+	if c.PipelineState != "" {
+		in.Desired, in.Context, err = load.PipelineState(c.PipelineState)
+		if err != nil {
+			return errors.Wrapf(err, "cannot load pipeline state from %q", c.PipelineState)
+		}
+	}
+
+	as, err := load.Each(c.Assertions, load.Assertions)
+	if err != nil {
+		return errors.Wrap(err, "cannot load assertions")
+	}
+
+	fp, err := c.fakeProvider()
+	if err != nil {
+		return err
+	}
+	if fp != nil {
+		in.ObservedResources, err = fp.Observe(context.Background(), in.ObservedResources)
+		if err != nil {
+			return errors.Wrap(err, "cannot fake observed composed resources")
+		}
+	}
+
+	ReapLeftoverContainers(log)
+
+	var tr *timing.Recorder
+	if c.Timings {
+		tr = timing.NewRecorder()
+		defer c.reportTimings(tr, log)
+	}
+	ro := []render.Option{render.WithLogger(log), render.WithFunctionOptions(functions.WithTimings(tr), functions.WithContainerRecord(rec))}
+
+	// Render stops any Functions it started when it returns, including when
+	// it's interrupted.
+	ctx, stop := NotifyContext()
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	if c.Iterations > 1 || c.UntilStable {
+		return c.renderIterations(ctx, in, fp, as, tr, ro...)
+	}
+
+	out, err := render.Render(ctx, in, ro...)
+
+	// Write the trace even if rendering failed - it's probably most useful when
+	// it did.
+	if c.TraceDir != "" {
+		if err := output.WriteTrace(c.TraceDir, out.Steps); err != nil {
+			return errors.Wrapf(err, "cannot write trace to %q", c.TraceDir)
+		}
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "cannot render composite resource")
+	}
+
+	out, err = c.finalize(out, in.CompositeResource, as)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err := output.WriteOutputs(os.Stdout, out, c.IncludeResults); err != nil {
+		return err
+	}
+	tr.Since(start, timing.Record{Phase: timing.PhaseEncode})
+
+	if err := c.checkDeletions(out); err != nil {
+		return err
+	}
+	return c.checkViolations(out)
+}
+
+// fakeProvider returns the FakeProvider to use, or nil if none should be used.
+func (c *RenderCmd) fakeProvider() (*render.FakeProvider, error) {
+	if !c.FakeProvider {
+		return nil, nil
+	}
+	templates, err := load.Each(c.StatusTemplates, load.ObservedResources)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load status templates")
+	}
+	return render.NewFakeProvider(templates), nil
+}
+
+func (c *RenderCmd) renderIterations(ctx context.Context, in render.Inputs, fp *render.FakeProvider, as []render.Assertion, tr *timing.Recorder, ro ...render.Option) error {
+	if c.FromStep != "" || c.UntilStep != "" {
+		return errors.New("--from-step and --until-step can't be used with --iterations or --until-stable, because part of the pipeline can't show whether rendering converges")
+	}
+
+	fixtures, err := load.Each(c.StatusFixtures, load.ObservedResources)
+	if err != nil {
+		return errors.Wrap(err, "cannot load status fixtures")
+	}
+
+	n := c.Iterations
+	if n < 1 {
+		n = 1
+		if c.UntilStable {
+			n = render.DefaultMaxIterations
+		}
+	}
+
+	// Status fixtures take precedence over the fake provider.
+	var o render.Observer = render.NewStatusFixtures(fixtures)
+	if fp != nil {
+		o = render.ObserverChain{fp, o}
+	}
+
+	outs, err := render.RenderIterations(ctx, in, n, c.UntilStable, o, ro...)
+
+	// Each iteration's trace is written to its own directory.
+	if c.TraceDir != "" {
+		for i, out := range outs {
+			dir := filepath.Join(c.TraceDir, fmt.Sprintf("iteration-%02d", i+1))
+			if err := output.WriteTrace(dir, out.Steps); err != nil {
+				return errors.Wrapf(err, "cannot write trace to %q", dir)
+			}
+		}
+	}
+
+	// We still want to see what happened if we didn't converge.
+	if err != nil && !errors.Is(err, render.ErrNotConverged) {
+		return errors.Wrap(err, "cannot render composite resource")
+	}
+	for i := range outs {
+		var ferr error
+		if outs[i], ferr = c.finalize(outs[i], in.CompositeResource, as); ferr != nil {
+			return ferr
+		}
+	}
+	start := time.Now()
+	if err := output.WriteIterations(os.Stdout, outs, c.IncludeResults); err != nil {
+		return err
+	}
+	tr.Since(start, timing.Record{Phase: timing.PhaseEncode})
+	if err != nil {
+		return err
+	}
+	if err := c.checkDeletions(outs...); err != nil {
+		return err
+	}
+	return c.checkViolations(outs...)
+}
+
+// reportTimings writes the supplied timings to stderr. Timings are reported
+// even if rendering failed, so there's nothing to return an error to.
+func (c *RenderCmd) reportTimings(tr *timing.Recorder, log logging.Logger) {
+	if err := output.WriteTimings(os.Stderr, tr.Records(), c.TimingsFormat); err != nil {
+		log.Info("Cannot report timings", "error", err)
+	}
+}
+
+// finalize returns the supplied output with the supplied defaulted XR's spec,
+// if we have an XRD, and with a Result for each violated assertion.
+func (c *RenderCmd) finalize(out render.Outputs, xr *composite.Unstructured, as []render.Assertion) (render.Outputs, error) {
+	// Show the defaulted XR if we have an XRD.
+	if c.XRD != "" {
+		out = render.WithCompositeSpec(out, xr)
+	}
+	return render.WithAssertionResults(out, as)
+}
+
+// checkDeletions returns an error if --fail-on-deletions was passed and any of
+// the supplied outputs would delete composed resources.
+func (c *RenderCmd) checkDeletions(outs ...render.Outputs) error {
+	if !c.FailOnDeletions {
+		return nil
+	}
+	names := make([]string, 0)
+	for _, out := range outs {
+		for _, d := range out.Deletions {
+			names = append(names, fmt.Sprintf("%v", d.Object["compositionResourceName"]))
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return errors.Errorf("composed resources would be deleted: %s", strings.Join(names, ", "))
+}
+
+// checkViolations returns an error if --fail-on-violations was passed and any
+// of the supplied outputs violate a Fatal assertion.
+func (c *RenderCmd) checkViolations(outs ...render.Outputs) error {
+	if !c.FailOnViolations {
+		return nil
+	}
+	violations := make([]unstructured.Unstructured, 0)
+	for _, out := range outs {
+		violations = append(violations, render.AssertionResults(out.Results)...)
+	}
+	return render.ViolationsError(violations)
+}
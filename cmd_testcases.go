@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/load"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+	"github.com/crossplane-contrib/xrender/pkg/testcase"
+)
+
+// TestCmd runs test cases.
+type TestCmd struct {
+	TestCases []string `arg:"" help:"Streams or directories of YAML manifests containing the test cases to run."`
+
+	RuntimeConfig []string      `type:"existingfile" help:"An optional YAML manifest configuring how to run Functions. Takes precedence over Function annotations. May be repeated; later files take precedence."`
+	Assertions    []string      `short:"a" help:"An optional stream or directory of YAML manifests containing CEL assertions that the rendered output of every test case must satisfy, in addition to its own."`
+	Timeout       time.Duration `help:"How long to run each test case before timing out." default:"1m"`
+	Update        bool          `help:"Write the golden file of each test case that expects one, rather than checking it."`
+}
+
+// Run the test command.
+func (c *TestCmd) Run(log logging.Logger, rec *functions.ContainerRecord) error {
+	tcs, err := load.Each(c.TestCases, testcase.Load)
+	if err != nil {
+		return errors.Wrap(err, "cannot load test cases")
+	}
+	as, err := load.Each(c.Assertions, load.Assertions)
+	if err != nil {
+		return errors.Wrap(err, "cannot load assertions")
+	}
+	rcs := make([]*functions.RuntimeConfig, 0, len(c.RuntimeConfig))
+	for _, file := range c.RuntimeConfig {
+		rc, err := load.RuntimeConfig(file)
+		if err != nil {
+			return errors.Wrapf(err, "cannot load runtime config from %q", file)
+		}
+		rcs = append(rcs, rc)
+	}
+
+	ReapLeftoverContainers(log)
+
+	ctx, stop := NotifyContext()
+	defer stop()
+
+	results := testcase.RunAll(ctx, tcs,
+		testcase.WithLogger(log),
+		testcase.WithRenderOptions(render.WithFunctionOptions(functions.WithContainerRecord(rec))),
+		testcase.WithRuntimeConfigs(rcs...),
+		testcase.WithAssertions(as...),
+		testcase.WithUpdate(c.Update),
+		testcase.WithTimeout(c.Timeout),
+	)
+
+	failed := 0
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(os.Stdout, "--- PASS: %s\n", r.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(os.Stdout, "--- FAIL: %s\n", r.Name)
+		for _, f := range r.Failures {
+			fmt.Fprintf(os.Stdout, "    %s\n", strings.ReplaceAll(f, "\n", "\n    "))
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "%d passed, %d failed\n", len(tcs)-failed, failed)
+	if failed > 0 {
+		return errors.Errorf("%d of %d test cases failed", failed, len(tcs))
+	}
+	return nil
+}
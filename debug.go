@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// ErrDebuggerQuit is returned by a Debugger when the user quits.
+var ErrDebuggerQuit = errors.New("quit debugger")
+
+const debuggerHelp = `Commands:
+  run, r            Run (or re-run) the current step against the state it was sent.
+  next, n           Accept the current step's result and pause before the next step.
+                    Runs the current step first if it hasn't been run.
+  skip, s           Skip the current step, leaving the desired state unchanged.
+  continue, c       Accept the current step's result and run the rest of the
+                    pipeline without pausing.
+  input, i          Print the current step's input.
+  edit, e           Edit the current step's input in $EDITOR.
+  get, g QUERY      Print the result of a JSONPath query, e.g. 'g .request.desired'.
+                    Queries run against an object with the fields 'request' and
+                    (once the step has run) 'response'.
+  diff, d           Show how the current step changed the desired state.
+  quit, q           Quit without rendering.
+  help, h           Show this help.
+`
+
+// A Debugger steps through a Composition Function pipeline interactively. It
+// pauses before each step of the pipeline, letting the user inspect, edit, run
+// and re-run the step without restarting the pipeline.
+type Debugger struct {
+	in     *bufio.Scanner
+	line   chan bool
+	out    io.Writer
+	editor string
+	runner render.StepRunner
+}
+
+// NewDebugger returns a Debugger that reads commands from the supplied reader,
+// writes to the supplied writer, and runs steps using the supplied runner.
+// Step input is edited using the supplied editor command.
+func NewDebugger(in io.Reader, out io.Writer, editor string, r render.StepRunner) *Debugger {
+	return &Debugger{in: bufio.NewScanner(in), out: out, editor: editor, runner: r}
+}
+
+// debugStep is the state of the pipeline step the debugger is paused at.
+type debugStep struct {
+	fn  apiextensionsv1.PipelineStep
+	req *fnv1beta1.RunFunctionRequest
+	rsp *fnv1beta1.RunFunctionResponse
+	err error
+}
+
+// Run the pipeline of the supplied Composition. Like RunPipeline, it doesn't
+// report deletions unless every step of the pipeline ran.
+func (d *Debugger) Run(ctx context.Context, in render.Inputs) (render.Outputs, error) { //nolint:gocyclo // A command loop is easiest to follow in one place.
+	o, err := render.AsState(in.CompositeResource, render.ObservedByName(in.ObservedResources))
+	if err != nil {
+		return render.Outputs{}, errors.Wrap(err, "cannot build observed composite and composed resources for RunFunctionRequest")
+	}
+
+	desired := &fnv1beta1.State{}
+	fctx, err := render.WithEnvironment(in.Context, in.CompositeResource, in.Composition, in.EnvironmentConfigs)
+	if err != nil {
+		return render.Outputs{}, err
+	}
+	if in.Desired != nil {
+		desired = in.Desired
+	}
+
+	pipeline, err := render.PipelineRange(in.Composition.Spec.Pipeline, in.FromStep, in.UntilStep)
+	if err != nil {
+		return render.Outputs{}, err
+	}
+
+	// Steps are numbered by their position in the full pipeline.
+	offset := 0
+	for i := range in.Composition.Spec.Pipeline {
+		if len(pipeline) > 0 && in.Composition.Spec.Pipeline[i].Step == pipeline[0].Step {
+			offset = i
+			break
+		}
+	}
+
+	results := make([]unstructured.Unstructured, 0)
+	steps := make([]render.Step, 0, len(pipeline))
+	pause := true
+	skipped := false
+
+	for i := range pipeline {
+		s := &debugStep{fn: pipeline[i]}
+		accept := false
+
+		if pause {
+			fmt.Fprintf(d.out, "\nPaused before step %d/%d %q (Function %q). Type 'help' for commands.\n", offset+i+1, len(in.Composition.Spec.Pipeline), s.fn.Step, s.fn.FunctionRef.Name)
+		}
+
+	prompt:
+		for pause {
+			cmd, arg, err := d.prompt(ctx)
+			if err != nil {
+				return render.Outputs{Steps: steps}, err
+			}
+
+			switch cmd {
+			case "":
+			case "run", "r":
+				d.run(ctx, s, o, desired, fctx)
+			case "next", "n":
+				if s.rsp == nil {
+					d.run(ctx, s, o, desired, fctx)
+				}
+				if s.err != nil {
+					// Let the user fix the step's input, or skip it.
+					continue
+				}
+				accept = true
+				break prompt
+			case "continue", "c":
+				pause = false
+			case "skip", "s":
+				fmt.Fprintf(d.out, "Skipped step %q.\n", s.fn.Step)
+				skipped = true
+				break prompt
+			case "input", "i":
+				d.printInput(s)
+			case "edit", "e":
+				if err := d.edit(s); err != nil {
+					fmt.Fprintf(d.out, "Cannot edit input: %s\n", err)
+					continue
+				}
+				// The step's result (if any) is stale now.
+				s.rsp, s.err = nil, nil
+				fmt.Fprintln(d.out, "Updated input. Type 'run' to run the step with the new input.")
+			case "get", "g":
+				d.get(s, o, desired, fctx, arg)
+			case "diff", "d":
+				d.diff(s)
+			case "quit", "q":
+				return render.Outputs{Steps: steps}, ErrDebuggerQuit
+			case "help", "h", "?":
+				fmt.Fprint(d.out, debuggerHelp)
+			default:
+				fmt.Fprintf(d.out, "Unknown command %q. Type 'help' for commands.\n", cmd)
+			}
+		}
+
+		// We're not pausing (anymore), so run the step if we haven't already.
+		if !pause {
+			if s.rsp == nil {
+				d.run(ctx, s, o, desired, fctx)
+			}
+			accept = true
+		}
+
+		if !accept {
+			continue
+		}
+		if s.err != nil {
+			return render.Outputs{Steps: steps}, s.err
+		}
+		steps = append(steps, render.Step{Step: s.fn.Step, Index: offset + i, Request: s.req, Response: s.rsp})
+		rs, err := render.StepResults(s.fn.Step, s.rsp)
+		if err != nil {
+			return render.Outputs{Steps: steps}, err
+		}
+		results = append(results, rs...)
+		desired = s.rsp.GetDesired()
+		fctx = s.rsp.GetContext()
+	}
+
+	out, err := render.RenderDesired(in.CompositeResource, in.ObservedResources, desired)
+	if err != nil {
+		return render.Outputs{Steps: steps}, err
+	}
+	out.Results = results
+	out.Context = fctx
+	out.Steps = steps
+
+	// Observed composed resources desired only by a step that didn't run
+	// would otherwise be reported as deletions.
+	if skipped || len(pipeline) < len(in.Composition.Spec.Pipeline) {
+		out.Deletions = nil
+	}
+	return out, nil
+}
+
+// prompt for a command. Reading a command is cancelled with the supplied
+// context, e.g. when the user presses Ctrl-C.
+func (d *Debugger) prompt(ctx context.Context) (cmd, arg string, err error) {
+	// Read one line at a time, only while prompting. Reading ahead would
+	// steal input from the user's editor.
+	if d.line == nil {
+		d.line = make(chan bool, 1)
+		go func() { d.line <- d.in.Scan() }()
+	}
+
+	fmt.Fprint(d.out, "(xrender) ")
+	select {
+	case <-ctx.Done():
+		fmt.Fprintln(d.out)
+		return "", "", ctx.Err()
+	case ok := <-d.line:
+		d.line = nil
+		if !ok {
+			if err := d.in.Err(); err != nil {
+				return "", "", errors.Wrap(err, "cannot read command")
+			}
+			// The user closed stdin (e.g. pressed Ctrl-D).
+			fmt.Fprintln(d.out)
+			return "", "", ErrDebuggerQuit
+		}
+		f := strings.Fields(d.in.Text())
+		if len(f) == 0 {
+			return "", "", nil
+		}
+		return f[0], strings.Join(f[1:], " "), nil
+	}
+}
+
+// run the supplied step, recording its request and response (or error).
+func (d *Debugger) run(ctx context.Context, s *debugStep, o, desired *fnv1beta1.State, fctx *structpb.Struct) {
+	s.req, s.err = render.NewRunFunctionRequest(s.fn, o, desired, fctx)
+	if s.err != nil {
+		fmt.Fprintf(d.out, "Cannot build request: %s\n", s.err)
+		return
+	}
+	s.rsp, s.err = d.runner.RunFunction(ctx, s.fn, s.req)
+	if s.err != nil {
+		fmt.Fprintf(d.out, "Step %q failed: %s\n", s.fn.Step, s.err)
+		return
+	}
+
+	fmt.Fprintf(d.out, "Ran step %q. It returned %d desired composed resources and %d results.\n", s.fn.Step, len(s.rsp.GetDesired().GetResources()), len(s.rsp.GetResults()))
+	for _, r := range s.rsp.GetResults() {
+		fmt.Fprintf(d.out, "  %s: %s\n", r.GetSeverity(), r.GetMessage())
+	}
+}
+
+func (d *Debugger) printInput(s *debugStep) {
+	if s.fn.Input == nil {
+		fmt.Fprintf(d.out, "Step %q has no input.\n", s.fn.Step)
+		return
+	}
+	y, err := yaml.JSONToYAML(s.fn.Input.Raw)
+	if err != nil {
+		fmt.Fprintf(d.out, "Cannot print input: %s\n", err)
+		return
+	}
+	fmt.Fprint(d.out, string(y))
+}
+
+// edit the supplied step's input using the debugger's editor.
+func (d *Debugger) edit(s *debugStep) error {
+	var y []byte
+	if s.fn.Input != nil {
+		var err error
+		if y, err = yaml.JSONToYAML(s.fn.Input.Raw); err != nil {
+			return errors.Wrap(err, "cannot convert input to YAML")
+		}
+	}
+
+	f, err := os.CreateTemp("", "xrender-input-*.yaml")
+	if err != nil {
+		return errors.Wrap(err, "cannot create temporary file")
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // Not much we can do about this.
+	if _, err := f.Write(y); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "cannot write temporary file")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "cannot close temporary file")
+	}
+
+	args := strings.Fields(d.editor)
+	if len(args) == 0 {
+		return errors.New("no editor configured - set $EDITOR")
+	}
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...) //nolint:gosec // Running the user's editor is intentional.
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "cannot run editor %q", d.editor)
+	}
+
+	y, err = os.ReadFile(f.Name())
+	if err != nil {
+		return errors.Wrap(err, "cannot read edited input")
+	}
+	j, err := yaml.YAMLToJSON(y)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse edited input as YAML")
+	}
+	if strings.TrimSpace(string(j)) == "null" {
+		s.fn.Input = nil
+		return nil
+	}
+	if err := json.Unmarshal(j, &map[string]any{}); err != nil {
+		return errors.Wrap(err, "input must be an object")
+	}
+	s.fn.Input = &runtime.RawExtension{Raw: j}
+	return nil
+}
+
+// get prints the result of a JSONPath query against the current step.
+func (d *Debugger) get(s *debugStep, o, desired *fnv1beta1.State, fctx *structpb.Struct, query string) {
+	if query == "" {
+		fmt.Fprintln(d.out, "Usage: get QUERY, e.g. get .request.desired.resources")
+		return
+	}
+
+	// Show the request the step would be sent, even if it hasn't run yet.
+	req := s.req
+	if req == nil {
+		var err error
+		if req, err = render.NewRunFunctionRequest(s.fn, o, desired, fctx); err != nil {
+			fmt.Fprintf(d.out, "Cannot build request: %s\n", err)
+			return
+		}
+	}
+
+	msgs := map[string]proto.Message{"request": req}
+	if s.rsp != nil {
+		msgs["response"] = s.rsp
+	}
+	obj := map[string]any{}
+	for k, m := range msgs {
+		j, err := protojson.Marshal(m)
+		if err != nil {
+			fmt.Fprintf(d.out, "Cannot marshal %s: %s\n", k, err)
+			return
+		}
+		var v any
+		if err := json.Unmarshal(j, &v); err != nil {
+			fmt.Fprintf(d.out, "Cannot unmarshal %s: %s\n", k, err)
+			return
+		}
+		obj[k] = v
+	}
+
+	if !strings.HasPrefix(query, "{") {
+		query = "{" + query + "}"
+	}
+	jp := jsonpath.New("get")
+	if err := jp.Parse(query); err != nil {
+		fmt.Fprintf(d.out, "Cannot parse query: %s\n", err)
+		return
+	}
+	found, err := jp.FindResults(obj)
+	if err != nil {
+		fmt.Fprintf(d.out, "Cannot run query: %s\n", err)
+		return
+	}
+	for _, rs := range found {
+		for _, r := range rs {
+			y, err := yaml.Marshal(r.Interface())
+			if err != nil {
+				fmt.Fprintf(d.out, "Cannot marshal query result: %s\n", err)
+				return
+			}
+			fmt.Fprint(d.out, string(y))
+		}
+	}
+}
+
+// diff prints how the step changed the desired state it was sent.
+func (d *Debugger) diff(s *debugStep) {
+	if s.rsp == nil {
+		fmt.Fprintf(d.out, "Step %q hasn't run yet. Type 'run' to run it.\n", s.fn.Step)
+		return
+	}
+	diff := cmp.Diff(s.req.GetDesired(), s.rsp.GetDesired(), protocmp.Transform())
+	if diff == "" {
+		fmt.Fprintln(d.out, "No changes to desired state.")
+		return
+	}
+	fmt.Fprintf(d.out, "Desired state: -sent to step, +returned by step\n%s", diff)
+}
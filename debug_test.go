@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+func TestDebuggerRun(t *testing.T) {
+	// Each step adds a desired composed resource named after the step.
+	addResource := render.StepRunnerFn(func(_ context.Context, fn apiextensionsv1.PipelineStep, req *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+		d := proto.Clone(req.GetDesired()).(*fnv1beta1.State) //nolint:forcetypeassert // Clone returns the type it was passed.
+		if d.Resources == nil {
+			d.Resources = map[string]*fnv1beta1.Resource{}
+		}
+		d.Resources[fn.Step] = &fnv1beta1.Resource{Resource: xtest.MustStructJSON(`{"apiVersion":"test.crossplane.io/v1","kind":"Composed"}`)}
+		return &fnv1beta1.RunFunctionResponse{Desired: d}, nil
+	})
+
+	xr := composite.New()
+	xr.SetAPIVersion("example.org/v1")
+	xr.SetKind("XR")
+	xr.SetName("test-render")
+
+	observed := func(name string) composed.Unstructured {
+		cd := composed.New()
+		cd.SetAPIVersion("test.crossplane.io/v1")
+		cd.SetKind("Composed")
+		cd.SetName("test-" + name)
+		cd.SetAnnotations(map[string]string{render.AnnotationKeyCompositionResourceName: name})
+		return *cd
+	}
+
+	in := render.Inputs{
+		CompositeResource: xr,
+		Composition: &apiextensionsv1.Composition{
+			Spec: apiextensionsv1.CompositionSpec{
+				Pipeline: []apiextensionsv1.PipelineStep{
+					{Step: "first", FunctionRef: apiextensionsv1.FunctionReference{Name: "function-test"}},
+					{Step: "second", FunctionRef: apiextensionsv1.FunctionReference{Name: "function-test"}},
+				},
+			},
+		},
+		// Only the first step desires resource first. No step desires resource
+		// third, so it's deleted if every step runs.
+		ObservedResources: []composed.Unstructured{observed("first"), observed("third")},
+	}
+
+	type args struct {
+		commands string
+		runner   render.StepRunner
+	}
+	type want struct {
+		steps     []string
+		resources []string
+		deletions []string
+		output    string
+		err       error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NextThroughPipeline": {
+			reason: "Running each step with next should run the whole pipeline.",
+			args: args{
+				commands: "next\nn\n",
+				runner:   addResource,
+			},
+			want: want{
+				steps:     []string{"first", "second"},
+				resources: []string{"first", "second"},
+				deletions: []string{"third"},
+			},
+		},
+		"SkipStep": {
+			reason: "A skipped step shouldn't change the desired state, and deletions shouldn't be reported because the whole pipeline didn't run.",
+			args: args{
+				commands: "skip\nnext\n",
+				runner:   addResource,
+			},
+			want: want{
+				steps:     []string{"second"},
+				resources: []string{"second"},
+			},
+		},
+		"SkipStepThenContinue": {
+			reason: "Finishing the pipeline after skipping a step shouldn't report deletions.",
+			args: args{
+				commands: "skip\ncontinue\n",
+				runner:   addResource,
+			},
+			want: want{
+				steps:     []string{"second"},
+				resources: []string{"second"},
+			},
+		},
+		"Continue": {
+			reason: "Continuing should run the rest of the pipeline without pausing.",
+			args: args{
+				commands: "continue\n",
+				runner:   addResource,
+			},
+			want: want{
+				steps:     []string{"first", "second"},
+				resources: []string{"first", "second"},
+				deletions: []string{"third"},
+			},
+		},
+		"GetQuery": {
+			reason: "A JSONPath query should print its results.",
+			args: args{
+				commands: "next\nget .request.desired.resources.first.resource.kind\nquit\n",
+				runner:   addResource,
+			},
+			want: want{
+				steps:  []string{"first"},
+				output: "Composed",
+				err:    ErrDebuggerQuit,
+			},
+		},
+		"Quit": {
+			reason: "Quitting should return an error.",
+			args: args{
+				commands: "quit\n",
+				runner:   addResource,
+			},
+			want: want{
+				err: ErrDebuggerQuit,
+			},
+		},
+		"EndOfInput": {
+			reason: "Running out of input should be treated like quitting.",
+			args: args{
+				commands: "run\n",
+				runner:   addResource,
+			},
+			want: want{
+				err: ErrDebuggerQuit,
+			},
+		},
+		"FatalResult": {
+			reason: "Accepting a step that returned a fatal result should return an error.",
+			args: args{
+				commands: "next\n",
+				runner: render.StepRunnerFn(func(_ context.Context, _ apiextensionsv1.PipelineStep, _ *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+					return &fnv1beta1.RunFunctionResponse{Results: []*fnv1beta1.Result{{Severity: fnv1beta1.Severity_SEVERITY_FATAL, Message: "oh no"}}}, nil
+				}),
+			},
+			want: want{
+				steps: []string{"first"},
+				err:   cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			b := &strings.Builder{}
+			out, err := NewDebugger(strings.NewReader(tc.args.commands), b, "", tc.args.runner).Run(context.Background(), in)
+
+			steps := make([]string, 0, len(out.Steps))
+			for _, s := range out.Steps {
+				steps = append(steps, s.Step)
+			}
+			resources := make([]string, 0, len(out.ComposedResources))
+			for _, cd := range out.ComposedResources {
+				resources = append(resources, cd.GetAnnotations()[render.AnnotationKeyCompositionResourceName])
+			}
+
+			if diff := cmp.Diff(tc.want.steps, steps, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("%s\nRun(...): -want steps, +got steps:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.resources, resources, cmpopts.EquateEmpty(), cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("%s\nRun(...): -want composed resources, +got composed resources:\n%s", tc.reason, diff)
+			}
+			deletions := make([]string, 0, len(out.Deletions))
+			for _, d := range out.Deletions {
+				deletions = append(deletions, d.Object["compositionResourceName"].(string)) //nolint:forcetypeassert // Deletions always have a string name.
+			}
+
+			if diff := cmp.Diff(tc.want.deletions, deletions, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("%s\nRun(...): -want deletions, +got deletions:\n%s", tc.reason, diff)
+			}
+			if !strings.Contains(b.String(), tc.want.output) {
+				t.Errorf("%s\nRun(...): want output containing %q, got:\n%s", tc.reason, tc.want.output, b.String())
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nRun(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
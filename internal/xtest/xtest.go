@@ -0,0 +1,83 @@
+// Package xtest contains helpers for testing xrender.
+package xtest
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+)
+
+// EquateRawJSON compares RawExtensions by the JSON they contain.
+var EquateRawJSON = cmp.Transformer("RawJSON", func(r runtime.RawExtension) any {
+	var v any
+	if err := json.Unmarshal(r.Raw, &v); err != nil {
+		return string(r.Raw)
+	}
+	return v
+})
+
+// MustLoadJSON unmarshals the supplied JSON object, or panics.
+func MustLoadJSON(j string) map[string]any {
+	out := make(map[string]any)
+	if err := json.Unmarshal([]byte(j), &out); err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// MustStructJSON unmarshals the supplied JSON object into a protobuf Struct,
+// or panics.
+func MustStructJSON(j string) *structpb.Struct {
+	s := &structpb.Struct{}
+	if err := protojson.Unmarshal([]byte(j), s); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewFunction serves a Function that always returns the supplied response. It
+// returns the listener the Function serves on. Close it to stop the Function.
+func NewFunction(t *testing.T, rsp *fnv1beta1.RunFunctionResponse) net.Listener {
+	t.Helper()
+	return NewFunctionServer(t, &MockFunctionRunner{Response: rsp})
+}
+
+// NewFunctionServer serves the supplied Function, without transport security.
+// It returns the listener the Function serves on. Close it to stop the
+// Function.
+func NewFunctionServer(t *testing.T, s fnv1beta1.FunctionRunnerServiceServer) net.Listener {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer(grpc.Creds(insecure.NewCredentials()))
+	fnv1beta1.RegisterFunctionRunnerServiceServer(srv, s)
+	go srv.Serve(lis) //nolint:errcheck // This will stop when lis is closed.
+
+	return lis
+}
+
+// A MockFunctionRunner is a Function that returns a fixed response and error.
+type MockFunctionRunner struct {
+	fnv1beta1.UnimplementedFunctionRunnerServiceServer
+
+	Response *fnv1beta1.RunFunctionResponse
+	Error    error
+}
+
+// RunFunction returns the mock's response and error.
+func (r *MockFunctionRunner) RunFunction(context.Context, *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+	return r.Response, r.Error
+}
@@ -3,112 +3,161 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 
 	"github.com/alecthomas/kong"
-	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
-	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
 
 	v1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/load"
+	"github.com/crossplane-contrib/xrender/pkg/render"
 )
 
-// CLI arguments and flags for xrender.
+// CLI arguments, flags and commands for xrender.
 type CLI struct {
-	Debug   bool          `short:"d" help:"Emit debug logs in addition to info logs."`
-	Timeout time.Duration `help:"How long to run before timing out." default:"1m"`
+	Debug     bool   `short:"d" help:"Emit debug logs in addition to info logs."`
+	LogFormat string `enum:"console,json" default:"console" help:"Format of the logs written to stderr. One of console or json."`
+
+	Render   RenderCmd  `cmd:"" default:"withargs" help:"Render an XR using Composition Functions. This is the default command."`
+	DebugCmd DebugCmd   `cmd:"" name:"debug" help:"Step through a Composition Function pipeline interactively."`
+	Batch    BatchCmd   `cmd:"" help:"Render many XRs using one Composition, starting the Functions they use once."`
+	Bench    BenchCmd   `cmd:"" help:"Repeatedly render XRs and report latency percentiles for each pipeline step and each render."`
+	Test     TestCmd    `cmd:"" help:"Run test cases that render XRs and check what they render."`
+	Compare  CompareCmd `cmd:"" help:"Compare how an XR renders using a base and a head Composition, or base and head Functions."`
+	Convert  ConvertCmd `cmd:"" help:"Convert a Resources mode Composition to a Pipeline mode Composition that uses function-patch-and-transform."`
+}
 
+// InputFlags are the inputs shared by commands that render an XR.
+type InputFlags struct {
 	CompositeResource string `arg:"" type:"existingfile" help:"A YAML manifest containing the Composite Resource (XR) to render."`
-	Composition       string `arg:"" type:"existingfile" help:"A YAML manifest containing the Composition to use. Must be mode: Pipeline."`
+	Composition       string `arg:"" help:"A stream or directory of YAML manifests containing the Composition to use, or Compositions and CompositionRevisions to select it from. Resources mode Compositions are rendered using function-patch-and-transform."`
 	Functions         string `arg:"" help:"A stream or directory of YAML manifests containing the Composition Functions to use."`
 
-	ObservedResources []string `short:"o" help:"An optional stream or directory of YAML manifests mocking the observed state of composed resources."`
-	IncludeResults    bool     `short:"r" default:"true" help:"Include Results in the output. Results are emitted as a 'fake' KRM-like object of kind: Result."`
+	ObservedResources  []string `short:"o" help:"An optional stream or directory of YAML manifests mocking the observed state of composed resources."`
+	EnvironmentConfigs []string `short:"e" help:"An optional stream or directory of YAML manifests containing EnvironmentConfigs the Composition may select."`
+	Revision           int64    `help:"Render using this revision number of the selected Composition, rather than the revision the XR would use. Requires CompositionRevisions."`
+	XRD                string   `name:"xrd" type:"existingfile" help:"An optional YAML manifest containing the CompositeResourceDefinition (XRD) that defines the XR. Its OpenAPI schema is used to default and validate the XR, like the API server would."`
+	RuntimeConfig      []string `type:"existingfile" help:"An optional YAML manifest configuring how to run Functions. Takes precedence over Function annotations. May be repeated; later files take precedence."`
 }
 
-// Run xrender.
-func (c *CLI) Run() error { //nolint:gocyclo // Only a touch over.
-	xr, err := LoadCompositeResource(c.CompositeResource)
+// Load the inputs.
+func (f *InputFlags) Load(log logging.Logger) (render.Inputs, error) {
+	xr, err := load.CompositeResource(f.CompositeResource)
 	if err != nil {
-		return errors.Wrapf(err, "cannot load composite resource from %q", c.CompositeResource)
+		return render.Inputs{}, errors.Wrapf(err, "cannot load composite resource from %q", f.CompositeResource)
 	}
+	log.Debug("Loaded composite resource", "file", f.CompositeResource, "xr", xr.GetName())
+	return f.LoadFor(xr, log)
+}
 
-	// TODO(negz): Should we do some simple validations, e.g. that the
-	// Composition's compositeTypeRef matches the XR's type?
-	comp, err := LoadComposition(c.Composition)
+// LoadFor loads the inputs needed to render the supplied XR. The
+// CompositeResource flag is ignored.
+func (f *InputFlags) LoadFor(xr *composite.Unstructured, log logging.Logger) (render.Inputs, error) {
+	s, err := f.LoadShared(log)
 	if err != nil {
-		return errors.Wrapf(err, "cannot load Composition from %q", c.Composition)
+		return render.Inputs{}, err
 	}
+	return InputsFor(s, xr, f.ObservedResources, log, log.Info)
+}
 
-	if m := comp.Spec.Mode; m == nil || *m != v1.CompositionModePipeline {
-		return errors.Errorf("xrender only supports Composition Function pipelines: Composition %q must use spec.mode: Pipeline", comp.GetName())
+// LoadShared loads the inputs that don't depend on which XR is rendered. The
+// CompositeResource and ObservedResources flags are ignored.
+func (f *InputFlags) LoadShared(log logging.Logger) (*render.SharedInputs, error) {
+	s, err := load.SharedInputs(load.SharedInputFiles{
+		Compositions:       f.Composition,
+		Functions:          f.Functions,
+		EnvironmentConfigs: f.EnvironmentConfigs,
+		XRD:                f.XRD,
+		RuntimeConfigs:     f.RuntimeConfig,
+	})
+	if err != nil {
+		return nil, err
 	}
+	s.Revision = f.Revision
+	log.Debug("Loaded shared inputs", "compositions", len(s.Compositions), "revisions", len(s.CompositionRevisions), "functions", len(s.Functions), "environment-configs", len(s.EnvironmentConfigs))
+	return s, nil
+}
 
-	fns, err := LoadFunctions(c.Functions)
+// InputsFor returns the inputs needed to render the supplied XR, with the
+// observed composed resources loaded from the supplied files. It logs what
+// Composition and CompositionRevision were selected, and why, using the
+// supplied logging function.
+func InputsFor(s *render.SharedInputs, xr *composite.Unstructured, observed []string, log logging.Logger, logSelection func(msg string, keysAndValues ...any)) (render.Inputs, error) {
+	ors, err := load.Each(observed, load.ObservedResources)
 	if err != nil {
-		return errors.Wrapf(err, "cannot load functions from %q", c.Functions)
+		return render.Inputs{}, errors.Wrap(err, "cannot load observed composed resources")
 	}
+	log.Debug("Loaded observed composed resources", "xr", xr.GetName(), "count", len(ors))
 
-	ors := []composed.Unstructured{}
-	for i := range c.ObservedResources {
-		loaded, err := LoadObservedResources(c.ObservedResources[i])
-		if err != nil {
-			return errors.Wrapf(err, "cannot load observed composed resources from %q", c.ObservedResources[i])
-		}
-		ors = append(ors, loaded...)
+	in, sel, err := s.InputsFor(xr, ors)
+	if sel.Composition.Composition != nil {
+		logSelection("Selected Composition", "xr", xr.GetName(), "composition", sel.Composition.Composition.GetName(), "reason", sel.Composition.Reason)
+	}
+	if sel.Revision != nil {
+		logSelection("Selected CompositionRevision", "xr", xr.GetName(), "revision", sel.Revision.Revision.GetName(), "number", sel.Revision.Revision.Spec.Revision, "reason", sel.Revision.Reason)
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
-	defer cancel()
-
-	out, err := Render(ctx, RenderInputs{
-		CompositeResource: xr,
-		Composition:       comp,
-		Functions:         fns,
-		ObservedResources: ors,
-	})
 	if err != nil {
-		return errors.Wrap(err, "cannot render composite resource")
+		return render.Inputs{}, err
 	}
-
-	// TODO(negz): Right now we're just emitting the desired state, which is an
-	// overlay on the observed state. Would it be more useful to apply the
-	// overlay to show something more like what the final result would be? The
-	// challenge with that would be that we'd have to try emulate what
-	// server-side apply would do (e.g. merging vs atomically replacing arrays)
-	// and we don't have enough context (i.e. OpenAPI schemas) to do that.
-
-	s := json.NewSerializerWithOptions(json.DefaultMetaFactory, nil, nil, json.SerializerOptions{Yaml: true})
-
-	fmt.Println("---")
-	if err := s.Encode(out.CompositeResource, os.Stdout); err != nil {
-		return errors.Wrapf(err, "cannot marshal composite resource %q to YAML", xr.GetName())
+	mode := sel.Composition.Composition.Spec.Mode
+	if sel.Revision != nil {
+		mode = sel.Revision.Revision.Spec.Mode
 	}
-
-	for i := range out.ComposedResources {
-		fmt.Println("---")
-		if err := s.Encode(&out.ComposedResources[i], os.Stdout); err != nil {
-			// TODO(negz): Use composed name annotation instead.
-			return errors.Wrapf(err, "cannot marshal composed resource %q to YAML", out.ComposedResources[i].GetName())
-		}
+	if mode == nil || *mode == v1.CompositionModeResources {
+		log.Debug("Converted Resources mode Composition to use function-patch-and-transform", "xr", xr.GetName(), "composition", in.Composition.GetName())
 	}
+	return in, nil
+}
 
-	if c.IncludeResults {
-		for i := range out.Results {
-			fmt.Println("---")
-			if err := s.Encode(&out.Results[i], os.Stdout); err != nil {
-				return errors.Wrap(err, "cannot marshal result to YAML")
-			}
-		}
+// ReapLeftoverContainers removes any Docker containers left running by an
+// earlier run of xrender that exited before it could remove them. It logs what
+// it did.
+func ReapLeftoverContainers(log logging.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), functions.StopTimeout)
+	defer cancel()
+	reaped, err := functions.ReapContainers(ctx, functions.DefaultContainerRecordDir())
+	for _, id := range reaped {
+		log.Info("Removed Docker container left running by an earlier run", "container", id)
 	}
+	if err != nil {
+		log.Info("Cannot remove Docker containers left running by an earlier run", "error", err)
+	}
+}
 
-	return nil
+// NotifyContext returns a context that is done when xrender is interrupted or
+// terminated. Once the context is done xrender stops catching signals, so a
+// second signal kills it as usual - even while it's still stopping Functions.
+func NotifyContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// NewLogger returns a logger that writes to stderr, so that stdout contains
+// only rendered output. Debug logs are only written if debug is true.
+func NewLogger(debug bool, format string) logging.Logger {
+	enc := zap.ConsoleEncoder()
+	if format == "json" {
+		enc = zap.JSONEncoder()
+	}
+	return logging.NewLogrLogger(zap.New(zap.WriteTo(os.Stderr), zap.UseDevMode(debug), enc))
 }
 
 func main() {
-	ctx := kong.Parse(&CLI{}, kong.Description("Render an XR using Composition Functions."))
+	c := &CLI{}
+	ctx := kong.Parse(c, kong.Description("Render an XR using Composition Functions."))
+	ctx.BindTo(NewLogger(c.Debug, c.LogFormat), (*logging.Logger)(nil))
+	ctx.Bind(functions.NewContainerRecord(functions.DefaultContainerRecordDir(), os.Getpid()))
 	ctx.FatalIfErrorf(ctx.Run())
 }
@@ -0,0 +1,106 @@
+package functions
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// Annotations that can be used to configure the Development runtime.
+const (
+	// AnnotationKeyRuntimeDevelopmentTarget can be used to configure the gRPC
+	// target where the Function is listening. The default is localhost:9443.
+	AnnotationKeyRuntimeDevelopmentTarget = "xrender.crossplane.io/runtime-development-target"
+
+	// AnnotationKeyRuntimeDevelopmentTLSCABundle can be used to configure the
+	// path to a PEM encoded bundle of CA certificates used to verify the
+	// Function's server certificate. xrender connects to the Function using
+	// TLS when this annotation is set, and without transport security when it
+	// isn't.
+	AnnotationKeyRuntimeDevelopmentTLSCABundle = "xrender.crossplane.io/runtime-development-tls-ca-bundle"
+
+	// AnnotationKeyRuntimeDevelopmentTLSClientCert and
+	// AnnotationKeyRuntimeDevelopmentTLSClientKey can be used to configure the
+	// paths to a PEM encoded client certificate and private key xrender will
+	// present to the Function, for mutual TLS.
+	AnnotationKeyRuntimeDevelopmentTLSClientCert = "xrender.crossplane.io/runtime-development-tls-client-cert"
+	AnnotationKeyRuntimeDevelopmentTLSClientKey  = "xrender.crossplane.io/runtime-development-tls-client-key"
+)
+
+// RuntimeDevelopment is largely a no-op. It expects you to run the Function
+// manually. This is useful for developing Functions.
+type RuntimeDevelopment struct {
+	// Target is the gRPC target for the running function, for example
+	// localhost:9443.
+	Target string
+
+	// TLSCABundle is the path to a PEM encoded CA bundle. If it's empty we
+	// connect to the Function without transport security.
+	TLSCABundle string
+
+	// TLSClientCert and TLSClientKey are the paths to a PEM encoded client
+	// certificate and private key. They're optional.
+	TLSClientCert string
+	TLSClientKey  string
+}
+
+// GetRuntimeDevelopment extracts RuntimeDevelopment configuration from the
+// supplied Function.
+func GetRuntimeDevelopment(fn pkgv1beta1.Function) *RuntimeDevelopment {
+	r := &RuntimeDevelopment{
+		Target:        "localhost:9443",
+		TLSCABundle:   fn.GetAnnotations()[AnnotationKeyRuntimeDevelopmentTLSCABundle],
+		TLSClientCert: fn.GetAnnotations()[AnnotationKeyRuntimeDevelopmentTLSClientCert],
+		TLSClientKey:  fn.GetAnnotations()[AnnotationKeyRuntimeDevelopmentTLSClientKey],
+	}
+	if t := fn.GetAnnotations()[AnnotationKeyRuntimeDevelopmentTarget]; t != "" {
+		r.Target = t
+	}
+	return r
+}
+
+var _ Runtime = &RuntimeDevelopment{}
+
+// Start does nothing. It returns a Stop function that also does nothing.
+func (r *RuntimeDevelopment) Start(_ context.Context) (RuntimeContext, error) {
+	creds, err := r.credentials()
+	if err != nil {
+		return RuntimeContext{}, errors.Wrap(err, "cannot load TLS credentials")
+	}
+	return RuntimeContext{Target: r.Target, Credentials: creds, Stop: func(_ context.Context) error { return nil }}, nil
+}
+
+func (r *RuntimeDevelopment) credentials() (credentials.TransportCredentials, error) {
+	if r.TLSCABundle == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	ca, err := os.ReadFile(r.TLSCABundle) //nolint:gosec // Taking this input is intentional.
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read CA bundle")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.Errorf("no PEM encoded certificates found in CA bundle %q", r.TLSCABundle)
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+
+	if r.TLSClientCert == "" && r.TLSClientKey == "" {
+		return credentials.NewTLS(cfg), nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.TLSClientCert, r.TLSClientKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load client certificate")
+	}
+	cfg.Certificates = []tls.Certificate{cert}
+
+	return credentials.NewTLS(cfg), nil
+}
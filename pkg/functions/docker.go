@@ -1,10 +1,15 @@
-package main
+package functions
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -13,8 +18,11 @@ import (
 	"github.com/docker/go-connections/nat"
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
 
 	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/timing"
 )
 
 // Annotations that can be used to configure the Docker runtime.
@@ -78,6 +86,20 @@ type RuntimeDocker struct {
 
 	// PullPolicy controls how the runtime image is pulled.
 	PullPolicy DockerPullPolicy
+
+	// Record of containers that should be stopped once rendering is done, so
+	// they can be reaped if xrender exits before it can stop them.
+	Record *ContainerRecord
+
+	// Certificates the Function should serve, and that we should use to
+	// connect to it.
+	Certificates *FunctionCertificates
+
+	// Container configuration, e.g. environment variables and mounts.
+	Container DockerContainerConfig
+
+	// Log is used to log what the runtime does, e.g. pulling images.
+	Log logging.Logger
 }
 
 // GetDockerPullPolicy extracts PullPolicy configuration from the supplied
@@ -106,8 +128,8 @@ func GetDockerCleanup(fn pkgv1beta1.Function) (DockerCleanup, error) {
 }
 
 // GetRuntimeDocker extracts RuntimeDocker configuration from the supplied
-// Function.
-func GetRuntimeDocker(fn pkgv1beta1.Function) (*RuntimeDocker, error) {
+// Function. The Function will serve the supplied certificates.
+func GetRuntimeDocker(fn pkgv1beta1.Function, certs *FunctionCertificates) (*RuntimeDocker, error) {
 	cleanup, err := GetDockerCleanup(fn)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get cleanup policy for Function %q", fn.GetName())
@@ -119,10 +141,17 @@ func GetRuntimeDocker(fn pkgv1beta1.Function) (*RuntimeDocker, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get pull policy for Function %q", fn.GetName())
 	}
+	ccfg, err := GetDockerContainerConfig(fn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get container configuration for Function %q", fn.GetName())
+	}
 	r := &RuntimeDocker{
-		Image:      fn.Spec.Package,
-		Stop:       cleanup == AnnotationValueRuntimeDockerCleanupStop,
-		PullPolicy: pullPolicy,
+		Image:        fn.Spec.Package,
+		Stop:         cleanup == AnnotationValueRuntimeDockerCleanupStop,
+		PullPolicy:   pullPolicy,
+		Certificates: certs,
+		Container:    ccfg,
+		Log:          logging.NewNopLogger(),
 	}
 	if i := fn.GetAnnotations()[AnnotationKeyRuntimeDockerImage]; i != "" {
 		r.Image = i
@@ -134,6 +163,12 @@ var _ Runtime = &RuntimeDocker{}
 
 // Start a Function as a Docker container.
 func (r *RuntimeDocker) Start(ctx context.Context) (RuntimeContext, error) { //nolint:gocyclo // TODO(phisco): Refactor to break this up a bit, not so easy.
+	log := r.Log
+	if log == nil {
+		log = logging.NewNopLogger()
+	}
+	log = log.WithValues("image", r.Image)
+
 	c, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return RuntimeContext{}, errors.Wrap(err, "cannot create Docker client using environment variables")
@@ -156,20 +191,26 @@ func (r *RuntimeDocker) Start(ctx context.Context) (RuntimeContext, error) { //n
 
 	cfg := &container.Config{
 		Image:        r.Image,
-		Cmd:          []string{"--insecure"},
+		Env:          []string{"TLS_SERVER_CERTS_DIR=" + TLSServerCertsDir},
 		ExposedPorts: expose,
 	}
 	hcfg := &container.HostConfig{
 		PortBindings: bind,
 	}
+	r.Container.Apply(cfg, hcfg)
 
+	timings := make([]timing.Record, 0, 2)
 	if r.PullPolicy == AnnotationValueRuntimeDockerPullPolicyAlways {
-		err = PullImage(ctx, c, r.Image)
+		pull, err := pullImage(ctx, log, c, r.Image)
 		if err != nil {
 			return RuntimeContext{}, errors.Wrapf(err, "cannot pull Docker image %q", r.Image)
 		}
+		timings = append(timings, pull)
 	}
 
+	// Time starting the container, not including any time spent pulling.
+	started := time.Now()
+
 	// TODO(negz): Set a container name? Presumably unique across runs.
 	rsp, err := c.ContainerCreate(ctx, cfg, hcfg, nil, nil, "")
 	if err != nil {
@@ -178,10 +219,12 @@ func (r *RuntimeDocker) Start(ctx context.Context) (RuntimeContext, error) { //n
 		}
 
 		// The image was not found, but we're allowed to pull it.
-		err = PullImage(ctx, c, r.Image)
+		pull, err := pullImage(ctx, log, c, r.Image)
 		if err != nil {
 			return RuntimeContext{}, errors.Wrapf(err, "cannot pull Docker image %q", r.Image)
 		}
+		timings = append(timings, pull)
+		started = time.Now()
 
 		rsp, err = c.ContainerCreate(ctx, cfg, hcfg, nil, nil, "")
 		if err != nil {
@@ -189,22 +232,117 @@ func (r *RuntimeDocker) Start(ctx context.Context) (RuntimeContext, error) { //n
 		}
 	}
 
+	// Record the container as soon as it exists, so it can be reaped if we
+	// exit before we can remove it. If we can't finish starting it we remove
+	// it ourselves, using a fresh context - ctx may have been cancelled, for
+	// example because another Function failed to start.
+	if err := r.Record.Add(rsp.ID); err != nil {
+		return RuntimeContext{}, errors.Join(errors.Wrap(err, "cannot record Docker container"), r.remove(c, rsp.ID))
+	}
+
+	// We copy our certificates into the container rather than bind mounting
+	// them, so that this works with remote Docker daemons.
+	certs, err := r.certificatesArchive()
+	if err != nil {
+		return RuntimeContext{}, errors.Join(errors.Wrap(err, "cannot archive TLS certificates"), r.remove(c, rsp.ID))
+	}
+	if err := c.CopyToContainer(ctx, rsp.ID, "/", certs, types.CopyToContainerOptions{}); err != nil {
+		return RuntimeContext{}, errors.Join(errors.Wrap(err, "cannot copy TLS certificates to Docker container"), r.remove(c, rsp.ID))
+	}
+
 	if err := c.ContainerStart(ctx, rsp.ID, types.ContainerStartOptions{}); err != nil {
-		return RuntimeContext{}, errors.Wrap(err, "cannot start Docker container")
+		return RuntimeContext{}, errors.Join(errors.Wrap(err, "cannot start Docker container"), r.remove(c, rsp.ID))
 	}
+	timings = append(timings, timing.Record{Phase: timing.PhaseStart, Duration: time.Since(started)})
+	log = log.WithValues("container", rsp.ID)
+	log.Debug("Started Docker container", "address", addr)
 
 	stop := func(_ context.Context) error {
-		// TODO(negz): Maybe log to stderr that we're leaving the container running?
+		log.Info("Leaving Docker container running, per its cleanup policy")
 		return nil
 	}
-	if r.Stop {
-		stop = func(ctx context.Context) error {
-			err := c.ContainerStop(ctx, rsp.ID, container.StopOptions{})
+	if !r.Stop {
+		// We're deliberately leaving this container running, so it must not
+		// be reaped.
+		if err := r.Record.Remove(rsp.ID); err != nil {
+			return RuntimeContext{}, errors.Join(errors.Wrap(err, "cannot remove Docker container from record"), r.remove(c, rsp.ID))
+		}
+		return RuntimeContext{Target: addr, Credentials: r.Certificates.ClientCredentials(), Stop: stop, Timings: timings}, nil
+	}
+
+	stop = func(ctx context.Context) error {
+		if err := c.ContainerStop(ctx, rsp.ID, container.StopOptions{}); err != nil {
 			return errors.Wrap(err, "cannot stop Docker container")
 		}
+		if err := c.ContainerRemove(ctx, rsp.ID, types.ContainerRemoveOptions{}); err != nil {
+			return errors.Wrap(err, "cannot remove Docker container")
+		}
+		log.Debug("Stopped and removed Docker container")
+		return errors.Wrap(r.Record.Remove(rsp.ID), "cannot remove Docker container from record")
+	}
+
+	return RuntimeContext{Target: addr, Credentials: r.Certificates.ClientCredentials(), Stop: stop, Timings: timings}, nil
+}
+
+// remove the supplied container, which failed to start, and remove it from the
+// record. It uses a fresh context bounded by StopTimeout, because the context
+// the container was started with may have been cancelled.
+func (r *RuntimeDocker) remove(c *client.Client, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), StopTimeout)
+	defer cancel()
+	if err := c.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true}); err != nil && !errdefs.IsNotFound(err) {
+		return errors.Wrapf(err, "cannot remove Docker container %q", id)
+	}
+	return errors.Wrap(r.Record.Remove(id), "cannot remove Docker container from record")
+}
+
+// certificatesArchive returns a tar archive containing the certificates the
+// Function should serve, laid out the way function-sdk-go expects.
+func (r *RuntimeDocker) certificatesArchive() (io.Reader, error) {
+	if r.Certificates == nil {
+		return nil, errors.New("no TLS certificates supplied")
+	}
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	dir := strings.TrimPrefix(TLSServerCertsDir, "/")
+	for _, d := range []string{path.Dir(dir), dir} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: d + "/", Mode: 0o755}); err != nil {
+			return nil, err
+		}
+	}
+
+	files := map[string][]byte{
+		TLSCAFile:   r.Certificates.CA,
+		TLSCertFile: r.Certificates.ServerCert,
+		TLSKeyFile:  r.Certificates.ServerKey,
+	}
+	for _, name := range []string{TLSCAFile, TLSCertFile, TLSKeyFile} {
+		// Functions usually run as a non-root user, so they need to be able
+		// to read these files regardless of who owns them. They're only valid
+		// for this run of xrender.
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: path.Join(dir, name), Mode: 0o644, Size: int64(len(files[name]))}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, err
+		}
 	}
 
-	return RuntimeContext{Target: addr, Stop: stop}, nil
+	return buf, tw.Close()
+}
+
+// pullImage pulls the supplied image, logging and returning how long it took.
+func pullImage(ctx context.Context, log logging.Logger, c *client.Client, image string) (timing.Record, error) {
+	log.Info("Pulling Docker image")
+	start := time.Now()
+	if err := PullImage(ctx, c, image); err != nil {
+		return timing.Record{}, err
+	}
+	pull := timing.Record{Phase: timing.PhasePull, Duration: time.Since(start)}
+	log.Debug("Pulled Docker image", "duration", pull.Duration)
+	return pull, nil
 }
 
 // PullImage pulls the supplied image using the supplied client. It blocks until
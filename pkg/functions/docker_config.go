@@ -0,0 +1,135 @@
+package functions
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// Annotations that can be used to configure a Function's Docker container.
+// Annotations that accept a list of values expect one value per line.
+const (
+	// AnnotationKeyRuntimeDockerEnv configures environment variables for the
+	// Function's container, as NAME=value pairs.
+	AnnotationKeyRuntimeDockerEnv = "xrender.crossplane.io/runtime-docker-env"
+
+	// AnnotationKeyRuntimeDockerMounts configures bind mounts for the
+	// Function's container, using Docker's /host/path:/container/path[:ro]
+	// syntax.
+	AnnotationKeyRuntimeDockerMounts = "xrender.crossplane.io/runtime-docker-mounts"
+
+	// AnnotationKeyRuntimeDockerNetwork configures the Docker network the
+	// Function's container is connected to.
+	AnnotationKeyRuntimeDockerNetwork = "xrender.crossplane.io/runtime-docker-network"
+
+	// AnnotationKeyRuntimeDockerCPUs limits the CPU available to the Function's
+	// container, using Kubernetes quantity syntax (e.g. 500m or 2).
+	AnnotationKeyRuntimeDockerCPUs = "xrender.crossplane.io/runtime-docker-cpus"
+
+	// AnnotationKeyRuntimeDockerMemory limits the memory available to the
+	// Function's container, using Kubernetes quantity syntax (e.g. 512Mi).
+	AnnotationKeyRuntimeDockerMemory = "xrender.crossplane.io/runtime-docker-memory"
+
+	// AnnotationKeyRuntimeDockerUser configures the user the Function's
+	// container runs as, using Docker's user[:group] syntax.
+	AnnotationKeyRuntimeDockerUser = "xrender.crossplane.io/runtime-docker-user"
+
+	// AnnotationKeyRuntimeDockerArgs configures extra arguments passed to the
+	// Function, for example --debug.
+	AnnotationKeyRuntimeDockerArgs = "xrender.crossplane.io/runtime-docker-args"
+)
+
+// DockerContainerConfig configures a Function's Docker container.
+type DockerContainerConfig struct {
+	// Env variables, as NAME=value pairs.
+	Env []string
+
+	// Mounts, using Docker's /host/path:/container/path[:ro] syntax.
+	Mounts []string
+
+	// Network the container should be connected to.
+	Network string
+
+	// NanoCPUs limits the container's CPU, in units of 1e-9 CPUs.
+	NanoCPUs int64
+
+	// Memory limits the container's memory, in bytes.
+	Memory int64
+
+	// User the container should run as.
+	User string
+
+	// Args passed to the Function.
+	Args []string
+}
+
+// GetDockerContainerConfig extracts DockerContainerConfig from the supplied
+// Function.
+func GetDockerContainerConfig(fn pkgv1beta1.Function) (DockerContainerConfig, error) {
+	a := fn.GetAnnotations()
+	cfg := DockerContainerConfig{
+		Env:     lines(a[AnnotationKeyRuntimeDockerEnv]),
+		Mounts:  lines(a[AnnotationKeyRuntimeDockerMounts]),
+		Network: a[AnnotationKeyRuntimeDockerNetwork],
+		User:    a[AnnotationKeyRuntimeDockerUser],
+		Args:    lines(a[AnnotationKeyRuntimeDockerArgs]),
+	}
+
+	for _, e := range cfg.Env {
+		if !strings.Contains(e, "=") {
+			return DockerContainerConfig{}, errors.Errorf("invalid %q annotation value %q (must be NAME=value)", AnnotationKeyRuntimeDockerEnv, e)
+		}
+	}
+
+	// xrender connects to the Function using a port it publishes on the host.
+	// Docker doesn't publish ports for containers using these network modes.
+	if m := container.NetworkMode(cfg.Network); m.IsHost() || m.IsNone() || m.IsContainer() {
+		return DockerContainerConfig{}, errors.Errorf("unsupported %q annotation value %q (xrender must publish the Function's port, which Docker doesn't do for host, none or container networks)", AnnotationKeyRuntimeDockerNetwork, cfg.Network)
+	}
+
+	if v := a[AnnotationKeyRuntimeDockerCPUs]; v != "" {
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return DockerContainerConfig{}, errors.Wrapf(err, "invalid %q annotation value %q", AnnotationKeyRuntimeDockerCPUs, v)
+		}
+		cfg.NanoCPUs = q.MilliValue() * 1e6
+	}
+
+	if v := a[AnnotationKeyRuntimeDockerMemory]; v != "" {
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return DockerContainerConfig{}, errors.Wrapf(err, "invalid %q annotation value %q", AnnotationKeyRuntimeDockerMemory, v)
+		}
+		cfg.Memory = q.Value()
+	}
+
+	return cfg, nil
+}
+
+// Apply the configuration to the supplied Docker container configuration.
+func (c DockerContainerConfig) Apply(cfg *container.Config, hcfg *container.HostConfig) {
+	cfg.Env = append(cfg.Env, c.Env...)
+	cfg.User = c.User
+	cfg.Cmd = append(cfg.Cmd, c.Args...)
+
+	hcfg.Binds = append(hcfg.Binds, c.Mounts...)
+	hcfg.NetworkMode = container.NetworkMode(c.Network)
+	hcfg.NanoCPUs = c.NanoCPUs
+	hcfg.Memory = c.Memory
+}
+
+// lines returns the non-empty, trimmed lines of the supplied string.
+func lines(s string) []string {
+	out := make([]string, 0)
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestGetDockerContainerConfig(t *testing.T) {
+	type want struct {
+		cfg DockerContainerConfig
+		err error
+	}
+	cases := map[string]struct {
+		reason      string
+		annotations map[string]string
+		want        want
+	}{
+		"NoAnnotations": {
+			reason: "A Function with no annotations should get an empty configuration.",
+			want: want{
+				cfg: DockerContainerConfig{},
+			},
+		},
+		"AllAnnotations": {
+			reason: "Each annotation should be parsed into the configuration.",
+			annotations: map[string]string{
+				AnnotationKeyRuntimeDockerEnv:     "FOO=bar\n  BAZ=qux,quux  \n\n",
+				AnnotationKeyRuntimeDockerMounts:  "/tmp/config:/config:ro",
+				AnnotationKeyRuntimeDockerNetwork: "stand-ins",
+				AnnotationKeyRuntimeDockerCPUs:    "500m",
+				AnnotationKeyRuntimeDockerMemory:  "128Mi",
+				AnnotationKeyRuntimeDockerUser:    "1000:1000",
+				AnnotationKeyRuntimeDockerArgs:    "--debug",
+			},
+			want: want{
+				cfg: DockerContainerConfig{
+					Env:      []string{"FOO=bar", "BAZ=qux,quux"},
+					Mounts:   []string{"/tmp/config:/config:ro"},
+					Network:  "stand-ins",
+					NanoCPUs: 500000000,
+					Memory:   128 * 1024 * 1024,
+					User:     "1000:1000",
+					Args:     []string{"--debug"},
+				},
+			},
+		},
+		"InvalidEnv": {
+			reason: "Environment variables must be NAME=value pairs.",
+			annotations: map[string]string{
+				AnnotationKeyRuntimeDockerEnv: "FOO",
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"HostNetwork": {
+			reason: "The host network isn't supported, because Docker doesn't publish the Function's port.",
+			annotations: map[string]string{
+				AnnotationKeyRuntimeDockerNetwork: "host",
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"ContainerNetwork": {
+			reason: "Another container's network isn't supported, because Docker doesn't publish the Function's port.",
+			annotations: map[string]string{
+				AnnotationKeyRuntimeDockerNetwork: "container:function-a",
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"InvalidMemory": {
+			reason: "Memory limits must be Kubernetes quantities.",
+			annotations: map[string]string{
+				AnnotationKeyRuntimeDockerMemory: "lots",
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fn := pkgv1beta1.Function{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			cfg, err := GetDockerContainerConfig(fn)
+
+			if diff := cmp.Diff(tc.want.cfg, cfg, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("%s\nGetDockerContainerConfig(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nGetDockerContainerConfig(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
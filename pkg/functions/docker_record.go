@@ -0,0 +1,179 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+// DefaultContainerRecordDir returns the directory in which xrender records the
+// Docker containers it starts.
+func DefaultContainerRecordDir() string {
+	d, err := os.UserCacheDir()
+	if err != nil {
+		d = os.TempDir()
+	}
+	return filepath.Join(d, "xrender", "containers")
+}
+
+// A ContainerRecord is a small on-disk record of the Docker containers a run of
+// xrender has started, and intends to stop. If xrender crashes before it can
+// stop its containers the next run can use the record to reap them.
+type ContainerRecord struct {
+	mx   sync.Mutex
+	path string
+
+	PID        int      `json:"pid"`
+	Containers []string `json:"containers"`
+}
+
+// NewContainerRecord returns a record of the Docker containers started by the
+// supplied process ID, stored in the supplied directory.
+func NewContainerRecord(dir string, pid int) *ContainerRecord {
+	return &ContainerRecord{path: filepath.Join(dir, strconv.Itoa(pid)+".json"), PID: pid}
+}
+
+// Add a container to the record.
+func (r *ContainerRecord) Add(id string) error {
+	if r == nil {
+		return nil
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.Containers = append(r.Containers, id)
+	return r.write()
+}
+
+// Remove a container from the record. The record file is deleted once it
+// records no containers.
+func (r *ContainerRecord) Remove(id string) error {
+	if r == nil {
+		return nil
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for i := range r.Containers {
+		if r.Containers[i] == id {
+			r.Containers = append(r.Containers[:i], r.Containers[i+1:]...)
+			break
+		}
+	}
+	if len(r.Containers) > 0 {
+		return r.write()
+	}
+	err := os.Remove(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return errors.Wrap(err, "cannot remove container record file")
+}
+
+func (r *ContainerRecord) write() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o700); err != nil {
+		return errors.Wrap(err, "cannot create container record directory")
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal container record")
+	}
+
+	// Write then rename, so a crash can't leave a partially written record.
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return errors.Wrap(err, "cannot write container record file")
+	}
+	return errors.Wrap(os.Rename(tmp, r.path), "cannot rename container record file")
+}
+
+// ReapContainers stops and removes any Docker containers recorded in the
+// supplied directory by runs of xrender that are no longer running. It returns
+// the IDs of any containers it removed.
+func ReapContainers(ctx context.Context, dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot glob container record files")
+	}
+
+	var c *client.Client
+	reaped := make([]string, 0)
+	errs := make([]error, 0)
+	for _, f := range files {
+		b, err := os.ReadFile(f) //nolint:gosec // We only read files we wrote.
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "cannot read container record file %q", f))
+			continue
+		}
+		r := &ContainerRecord{}
+		if err := json.Unmarshal(b, r); err != nil {
+			errs = append(errs, errors.Wrapf(err, "cannot unmarshal container record file %q", f))
+			continue
+		}
+		if r.PID == os.Getpid() || processRunning(r.PID) {
+			continue
+		}
+
+		if c == nil {
+			c, err = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+			if err != nil {
+				return reaped, errors.Wrap(err, "cannot create Docker client using environment variables")
+			}
+		}
+
+		stopped := true
+		for _, id := range r.Containers {
+			err := c.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true})
+			if errdefs.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "cannot remove leftover Docker container %q", id))
+				stopped = false
+				continue
+			}
+			reaped = append(reaped, id)
+		}
+
+		// Keep the record around to try again next time if we couldn't remove
+		// all of the containers it recorded.
+		if !stopped {
+			continue
+		}
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, errors.Wrapf(err, "cannot remove container record file %q", f))
+		}
+	}
+
+	return reaped, errors.Join(errs...)
+}
+
+// processRunning returns false if the supplied process is known not to be
+// running. It otherwise returns true, so that we never remove containers that
+// are still in use.
+//
+// On Windows os.FindProcess opens the process, and fails if it doesn't exist.
+// Windows doesn't support signal 0, so that's all we can check. Elsewhere
+// os.FindProcess always succeeds, so we send the process signal 0. That fails
+// with os.ErrProcessDone if the process doesn't exist, and with other errors
+// (e.g. EPERM) if it exists but we can't signal it.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release() //nolint:errcheck // Not much we can do about this.
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	return !errors.Is(p.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
@@ -0,0 +1,69 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestContainerRecord(t *testing.T) {
+	dir := t.TempDir()
+	pid := 42
+	path := filepath.Join(dir, strconv.Itoa(pid)+".json")
+
+	r := NewContainerRecord(dir, pid)
+	if err := r.Add("a"); err != nil {
+		t.Fatalf("r.Add(...): %v", err)
+	}
+	if err := r.Add("b"); err != nil {
+		t.Fatalf("r.Add(...): %v", err)
+	}
+	if err := r.Remove("a"); err != nil {
+		t.Fatalf("r.Remove(...): %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile(...): %v", err)
+	}
+	got := &ContainerRecord{}
+	if err := json.Unmarshal(b, got); err != nil {
+		t.Fatalf("json.Unmarshal(...): %v", err)
+	}
+	want := &ContainerRecord{PID: pid, Containers: []string{"b"}}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(ContainerRecord{})); diff != "" {
+		t.Errorf("ContainerRecord file: -want, +got:\n%s", diff)
+	}
+
+	if err := r.Remove("b"); err != nil {
+		t.Fatalf("r.Remove(...): %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("os.Stat(...): want record file to be removed once it records no containers, got err %v", err)
+	}
+}
+
+func TestReapContainersSkipsRunningProcesses(t *testing.T) {
+	dir := t.TempDir()
+
+	// This process is running, so its containers must not be reaped. If they
+	// were we'd fail trying to talk to Docker.
+	r := NewContainerRecord(dir, os.Getpid())
+	if err := r.Add("a"); err != nil {
+		t.Fatalf("r.Add(...): %v", err)
+	}
+
+	reaped, err := ReapContainers(context.Background(), dir)
+	if diff := cmp.Diff([]string{}, reaped, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("ReapContainers(...): -want, +got:\n%s", diff)
+	}
+	if err != nil {
+		t.Errorf("ReapContainers(...): %v", err)
+	}
+}
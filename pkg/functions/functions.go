@@ -0,0 +1,341 @@
+// Package functions runs Composition Functions locally, using a runtime such as
+// Docker, and calls them.
+package functions
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/timing"
+)
+
+// Wait for the server to be ready before sending RPCs. Notably this gives
+// Docker containers time to start before we make a request. See
+// https://grpc.io/docs/guides/wait-for-ready/
+const waitForReady = `{
+	"methodConfig":[{
+		"name": [{"service": "apiextensions.fn.proto.v1beta1.FunctionRunnerService"}],
+		"waitForReady": true
+	}]
+}`
+
+// MaxConcurrentFunctionStarts is the maximum number of Functions that will be
+// started at once. Starting a Function may involve pulling a Docker image, so
+// we don't want to start too many at the same time.
+const MaxConcurrentFunctionStarts = 8
+
+// StopTimeout is how long we'll wait for running Functions to stop.
+const StopTimeout = 30 * time.Second
+
+// Referenced returns the Functions referenced by the supplied
+// Composition pipeline, in the order they're first referenced. It returns an
+// error if the pipeline references a Function that wasn't supplied.
+func Referenced(fns []pkgv1beta1.Function, pipeline []apiextensionsv1.PipelineStep) ([]pkgv1beta1.Function, error) {
+	byName := make(map[string]pkgv1beta1.Function, len(fns))
+	for _, fn := range fns {
+		byName[fn.GetName()] = fn
+	}
+
+	seen := map[string]bool{}
+	out := make([]pkgv1beta1.Function, 0, len(pipeline))
+	for _, s := range pipeline {
+		name := s.FunctionRef.Name
+		if seen[name] {
+			continue
+		}
+		fn, ok := byName[name]
+		if !ok {
+			return nil, errors.Errorf("unknown Function %q, referenced by pipeline step %q - does it exist in your Functions file?", name, s.Step)
+		}
+		seen[name] = true
+		out = append(out, fn)
+	}
+	return out, nil
+}
+
+// Running are Functions that have been started, and that we have
+// dialed.
+type Running struct {
+	mx       sync.Mutex
+	log      logging.Logger
+	logs     bool      // Whether WithLogger configured log.
+	stderr   io.Writer // Where Cleanup writes errors if log isn't configured.
+	timings  *timing.Recorder
+	record   *ContainerRecord
+	runtimes map[RuntimeType]RuntimeFn
+	certs    *FunctionCertificates
+	conns    map[string]*grpc.ClientConn
+	stops    []func(context.Context) error
+}
+
+// A RuntimeFn returns the Runtime used to run the supplied Function. Runtimes
+// that run the Function themselves should configure it to serve the supplied
+// certificates.
+type RuntimeFn func(fn pkgv1beta1.Function, certs *FunctionCertificates) (Runtime, error)
+
+// An Option configures how Functions are started.
+type Option func(rf *Running)
+
+// WithLogger configures how Functions log. Functions don't log by default,
+// except that Cleanup writes any error to stderr.
+func WithLogger(l logging.Logger) Option {
+	return func(rf *Running) {
+		rf.log = l
+		rf.logs = true
+	}
+}
+
+// WithTimings configures Functions to record how long it takes to start them,
+// and to run each pipeline step, using the supplied Recorder. When timings are
+// recorded Start waits for each Function to be ready to serve requests, so it
+// can record how long that took.
+func WithTimings(r *timing.Recorder) Option {
+	return func(rf *Running) {
+		rf.timings = r
+	}
+}
+
+// WithContainerRecord configures Functions run using Docker to be recorded in
+// the supplied ContainerRecord while they're running, so they can be reaped if
+// this process exits before it can stop them. Containers aren't recorded by
+// default.
+func WithContainerRecord(r *ContainerRecord) Option {
+	return func(rf *Running) {
+		rf.record = r
+	}
+}
+
+// WithRuntime configures Functions annotated with the supplied runtime type to
+// be run using the supplied RuntimeFn. It may be used to replace one of the
+// built-in runtimes, or to add a new one.
+func WithRuntime(t RuntimeType, fn RuntimeFn) Option {
+	return func(rf *Running) {
+		rf.runtimes[t] = fn
+	}
+}
+
+// Start starts the supplied Functions concurrently and dials each of
+// them. If any Function fails to start, any Functions that did start are
+// stopped before an error is returned. Functions that xrender runs itself are
+// configured to serve ephemeral certificates generated for this call, and are
+// dialed using mutual TLS.
+func Start(ctx context.Context, fns []pkgv1beta1.Function, o ...Option) (*Running, error) {
+	certs, err := NewFunctionCertificates()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot generate TLS certificates for Functions")
+	}
+	rf := &Running{
+		log:      logging.NewNopLogger(),
+		stderr:   os.Stderr,
+		runtimes: map[RuntimeType]RuntimeFn{},
+		certs:    certs,
+		conns:    make(map[string]*grpc.ClientConn, len(fns)),
+	}
+	for _, fn := range o {
+		fn(rf)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(MaxConcurrentFunctionStarts)
+	for _, fn := range fns {
+		fn := fn // Pin range variable so we can use it in our goroutine.
+		g.Go(func() error { return rf.start(gctx, fn) })
+	}
+
+	if err := g.Wait(); err != nil {
+		rf.Cleanup()
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+func (rf *Running) start(ctx context.Context, fn pkgv1beta1.Function) error {
+	log := rf.log.WithValues("function", fn.GetName())
+
+	runtime, err := rf.runtime(fn, log)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get runtime for Function %q", fn.GetName())
+	}
+
+	log.Debug("Starting Function", "package", fn.Spec.Package)
+	start := time.Now()
+	rctx, err := runtime.Start(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "cannot start Function %q", fn.GetName())
+	}
+	log.Debug("Started Function", "target", rctx.Target, "duration", time.Since(start))
+	for _, t := range rctx.Timings {
+		t.Function = fn.GetName()
+		rf.timings.Record(t)
+	}
+
+	conn, err := rf.dial(ctx, fn.GetName(), rctx, log)
+	if err != nil {
+		return err
+	}
+
+	// Dialing doesn't wait for the Function to be ready - the first call to
+	// RunFunction does. We only wait here if we're recording timings.
+	if !rf.timings.Enabled() {
+		return nil
+	}
+	start = time.Now()
+	if err := ready(ctx, conn); err != nil {
+		return errors.Wrapf(err, "cannot wait for Function %q to be ready", fn.GetName())
+	}
+	rf.timings.Since(start, timing.Record{Phase: timing.PhaseReady, Function: fn.GetName()})
+	log.Debug("Function is ready", "duration", time.Since(start))
+
+	return nil
+}
+
+// dial the supplied started Function. The Function will be stopped and its
+// connection closed when the running Functions are stopped.
+func (rf *Running) dial(ctx context.Context, name string, rctx RuntimeContext, log logging.Logger) (*grpc.ClientConn, error) {
+	rf.mx.Lock()
+	defer rf.mx.Unlock()
+	rf.stops = append(rf.stops, func(ctx context.Context) error {
+		log.Debug("Stopping Function")
+		return errors.Wrapf(rctx.Stop(ctx), "cannot stop Function %q", name)
+	})
+
+	conn, err := grpc.DialContext(ctx, rctx.Target,
+		grpc.WithTransportCredentials(rctx.Credentials),
+		grpc.WithDefaultServiceConfig(waitForReady))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot dial Function %q at address %q", name, rctx.Target)
+	}
+	rf.conns[name] = conn
+	rf.stops = append(rf.stops, func(_ context.Context) error {
+		// This only returns an error if the connection is already closed or
+		// closing, so we don't bother returning it.
+		_ = conn.Close()
+		return nil
+	})
+
+	return conn, nil
+}
+
+// ready blocks until the supplied connection is ready, or the supplied context
+// is done.
+func ready(ctx context.Context, conn *grpc.ClientConn) error {
+	for {
+		s := conn.GetState()
+		switch s { //nolint:exhaustive // We only care about these states.
+		case connectivity.Ready:
+			return nil
+		case connectivity.Idle:
+			conn.Connect()
+		}
+		if !conn.WaitForStateChange(ctx, s) {
+			return ctx.Err()
+		}
+	}
+}
+
+// runtime returns the Runtime used to run the supplied Function, preferring
+// any configured using WithRuntime.
+func (rf *Running) runtime(fn pkgv1beta1.Function, log logging.Logger) (Runtime, error) {
+	t := RuntimeType(fn.GetAnnotations()[AnnotationKeyRuntime])
+	if t == "" {
+		t = AnnotationValueRuntimeDefault
+	}
+	if get, ok := rf.runtimes[t]; ok {
+		return get(fn, rf.certs)
+	}
+	r, err := GetRuntime(fn, rf.certs, log)
+	if d, ok := r.(*RuntimeDocker); ok {
+		d.Record = rf.record
+	}
+	return r, err
+}
+
+// Conn returns a gRPC client connection to the named Function.
+func (rf *Running) Conn(name string) (*grpc.ClientConn, bool) {
+	rf.mx.Lock()
+	defer rf.mx.Unlock()
+	conn, ok := rf.conns[name]
+	return conn, ok
+}
+
+// RunFunction sends the supplied request to the Function referenced by the
+// supplied pipeline step.
+func (rf *Running) RunFunction(ctx context.Context, fn apiextensionsv1.PipelineStep, req *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+	conn, ok := rf.Conn(fn.FunctionRef.Name)
+	if !ok {
+		return nil, errors.Errorf("unknown Function %q, referenced by pipeline step %q - does it exist in your Functions file?", fn.FunctionRef.Name, fn.Step)
+	}
+
+	log := rf.log.WithValues("step", fn.Step, "function", fn.FunctionRef.Name)
+	log.Debug("Running pipeline step")
+	start := time.Now()
+	rsp, err := fnv1beta1.NewFunctionRunnerServiceClient(conn).RunFunction(ctx, req)
+	if err != nil {
+		log.Debug("Pipeline step failed", "duration", time.Since(start), "error", err)
+		return nil, errors.Wrapf(err, "cannot run pipeline step %q", fn.Step)
+	}
+	rf.timings.Since(start, timing.Record{
+		Phase:         timing.PhaseRun,
+		Function:      fn.FunctionRef.Name,
+		Step:          fn.Step,
+		RequestBytes:  proto.Size(req),
+		ResponseBytes: proto.Size(rsp),
+	})
+	log.Debug("Ran pipeline step", "duration", time.Since(start))
+	return rsp, nil
+}
+
+// Stop all running Functions, and close their connections. Every Function is
+// stopped even if stopping one of them fails.
+func (rf *Running) Stop(ctx context.Context) error {
+	rf.mx.Lock()
+	defer rf.mx.Unlock()
+
+	rf.log.Debug("Stopping Functions")
+	errs := make([]error, 0)
+
+	// Stop in reverse order, so that we close each connection before we stop
+	// the Function it's connected to.
+	for i := len(rf.stops) - 1; i >= 0; i-- {
+		errs = append(errs, rf.stops[i](ctx))
+	}
+	rf.stops = nil
+
+	return errors.Join(errs...)
+}
+
+// Cleanup stops all running Functions using a fresh context, bounded by
+// StopTimeout. The context used to start and call our Functions may have been
+// cancelled or timed out, which is often why we're cleaning up. Cleanup logs
+// any error, because there's nothing more a caller could do to recover from
+// it. If no logger was configured using WithLogger it writes the error to
+// stderr instead, so that Functions left running aren't silently ignored.
+func (rf *Running) Cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), StopTimeout)
+	defer cancel()
+	err := rf.Stop(ctx)
+	switch {
+	case err == nil:
+	case rf.logs:
+		rf.log.Info("Cannot cleanly stop Functions", "error", err)
+	default:
+		fmt.Fprintf(rf.stderr, "cannot cleanly stop Functions: %s\n", err)
+	}
+}
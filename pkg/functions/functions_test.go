@@ -0,0 +1,275 @@
+package functions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+	"github.com/crossplane-contrib/xrender/pkg/timing"
+)
+
+func TestReferenced(t *testing.T) {
+	fn := func(name string) pkgv1beta1.Function {
+		return pkgv1beta1.Function{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	step := func(step, fn string) apiextensionsv1.PipelineStep {
+		return apiextensionsv1.PipelineStep{Step: step, FunctionRef: apiextensionsv1.FunctionReference{Name: fn}}
+	}
+
+	type args struct {
+		fns      []pkgv1beta1.Function
+		pipeline []apiextensionsv1.PipelineStep
+	}
+	type want struct {
+		fns []pkgv1beta1.Function
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"OnlyReferenced": {
+			reason: "Only Functions referenced by the pipeline should be returned, once each, in the order they're first referenced.",
+			args: args{
+				fns: []pkgv1beta1.Function{fn("function-a"), fn("function-b"), fn("function-c")},
+				pipeline: []apiextensionsv1.PipelineStep{
+					step("one", "function-c"),
+					step("two", "function-a"),
+					step("three", "function-c"),
+				},
+			},
+			want: want{
+				fns: []pkgv1beta1.Function{fn("function-c"), fn("function-a")},
+			},
+		},
+		"UnknownFunction": {
+			reason: "We should return an error if the pipeline references a Function that wasn't supplied.",
+			args: args{
+				fns:      []pkgv1beta1.Function{fn("function-a")},
+				pipeline: []apiextensionsv1.PipelineStep{step("one", "function-b")},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fns, err := Referenced(tc.args.fns, tc.args.pipeline)
+
+			if diff := cmp.Diff(tc.want.fns, fns, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("%s\nReferenced(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nReferenced(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// A RuntimeFake runs a Function that's already running.
+type RuntimeFake struct {
+	Target  string
+	Stopped bool
+
+	// Err is returned by Start, if set.
+	Err error
+
+	// OnStart is called when the Function is started, if set.
+	OnStart func()
+}
+
+// Start returns the target of the already running Function.
+func (r *RuntimeFake) Start(_ context.Context) (RuntimeContext, error) {
+	if r.OnStart != nil {
+		r.OnStart()
+	}
+	if r.Err != nil {
+		return RuntimeContext{}, r.Err
+	}
+	stop := func(_ context.Context) error {
+		r.Stopped = true
+		return nil
+	}
+	return RuntimeContext{Target: r.Target, Credentials: insecure.NewCredentials(), Stop: stop}, nil
+}
+
+func TestStart(t *testing.T) {
+	rsp := &fnv1beta1.RunFunctionResponse{Meta: &fnv1beta1.ResponseMeta{Tag: "cool"}}
+	lis := xtest.NewFunction(t, rsp)
+	defer lis.Close()
+
+	fake := &RuntimeFake{Target: lis.Addr().String()}
+	fn := pkgv1beta1.Function{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "function-fake",
+			Annotations: map[string]string{AnnotationKeyRuntime: "Fake"},
+		},
+	}
+	withFake := WithRuntime("Fake", func(_ pkgv1beta1.Function, _ *FunctionCertificates) (Runtime, error) {
+		return fake, nil
+	})
+
+	tr := timing.NewRecorder()
+	rf, err := Start(context.Background(), []pkgv1beta1.Function{fn}, withFake, WithTimings(tr))
+	if err != nil {
+		t.Fatalf("Start(...): %v", err)
+	}
+
+	step := apiextensionsv1.PipelineStep{Step: "one", FunctionRef: apiextensionsv1.FunctionReference{Name: "function-fake"}}
+	req := &fnv1beta1.RunFunctionRequest{Meta: &fnv1beta1.RequestMeta{Tag: "cool"}}
+	got, err := rf.RunFunction(context.Background(), step, req)
+	if err != nil {
+		t.Errorf("RunFunction(...): %v", err)
+	}
+	if diff := cmp.Diff(rsp, got, protocmp.Transform()); diff != "" {
+		t.Errorf("RunFunction(...): -want, +got:\n%s", diff)
+	}
+
+	wantTimings := []timing.Record{
+		{Phase: timing.PhaseReady, Function: "function-fake"},
+		{Phase: timing.PhaseRun, Function: "function-fake", Step: "one", RequestBytes: proto.Size(req), ResponseBytes: proto.Size(rsp)},
+	}
+	if diff := cmp.Diff(wantTimings, tr.Records(), cmpopts.IgnoreFields(timing.Record{}, "Duration")); diff != "" {
+		t.Errorf("Records(): -want, +got:\n%s", diff)
+	}
+
+	rf.Cleanup()
+	if !fake.Stopped {
+		t.Errorf("Cleanup(): want Function stopped using the runtime supplied by WithRuntime")
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	boom := func(_ context.Context) error { return errors.New("boom") }
+
+	cases := map[string]struct {
+		reason string
+		rf     *Running
+		want   string
+	}{
+		"Stopped": {
+			reason: "Nothing should be written if the Functions stop cleanly.",
+			rf:     &Running{log: logging.NewNopLogger(), stops: []func(context.Context) error{func(_ context.Context) error { return nil }}},
+			want:   "",
+		},
+		"NoLogger": {
+			reason: "Errors should be written to stderr if no logger was configured.",
+			rf:     &Running{log: logging.NewNopLogger(), stops: []func(context.Context) error{boom}},
+			want:   "cannot cleanly stop Functions: boom\n",
+		},
+		"Logger": {
+			reason: "Errors should be logged, not written to stderr, if a logger was configured.",
+			rf:     &Running{log: logging.NewNopLogger(), logs: true, stops: []func(context.Context) error{boom}},
+			want:   "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			tc.rf.stderr = buf
+			tc.rf.Cleanup()
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("%s\nCleanup(): -want stderr, +got stderr:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestStartError(t *testing.T) {
+	lis := xtest.NewFunction(t, &fnv1beta1.RunFunctionResponse{})
+	defer lis.Close()
+
+	fakes := map[string]*RuntimeFake{
+		"function-ok":     {Target: lis.Addr().String()},
+		"function-broken": {Err: errors.New("boom")},
+	}
+	withFakes := WithRuntime("Fake", func(fn pkgv1beta1.Function, _ *FunctionCertificates) (Runtime, error) {
+		return fakes[fn.GetName()], nil
+	})
+	fn := func(name string) pkgv1beta1.Function {
+		return pkgv1beta1.Function{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{AnnotationKeyRuntime: "Fake"}}}
+	}
+
+	if _, err := Start(context.Background(), []pkgv1beta1.Function{fn("function-ok"), fn("function-broken")}, withFakes); err == nil {
+		t.Errorf("Start(...): want error when a Function fails to start, got nil")
+	}
+	if !fakes["function-ok"].Stopped {
+		t.Errorf("Start(...): want Functions that did start stopped when another fails to start")
+	}
+}
+
+func TestStartConcurrency(t *testing.T) {
+	lis := xtest.NewFunction(t, &fnv1beta1.RunFunctionResponse{})
+	defer lis.Close()
+
+	// The first MaxConcurrentFunctionStarts Functions to start wait for each
+	// other, so they must be started concurrently to start promptly.
+	var mx sync.Mutex
+	active, peak := 0, 0
+	arrived := make(chan struct{})
+	onStart := func() {
+		mx.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		if peak == MaxConcurrentFunctionStarts {
+			select {
+			case <-arrived:
+			default:
+				close(arrived)
+			}
+		}
+		mx.Unlock()
+
+		select {
+		case <-arrived:
+		case <-time.After(5 * time.Second):
+		}
+
+		mx.Lock()
+		active--
+		mx.Unlock()
+	}
+
+	fns := make([]pkgv1beta1.Function, MaxConcurrentFunctionStarts*2)
+	for i := range fns {
+		fns[i] = pkgv1beta1.Function{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("function-%d", i), Annotations: map[string]string{AnnotationKeyRuntime: "Fake"}}}
+	}
+	withFakes := WithRuntime("Fake", func(_ pkgv1beta1.Function, _ *FunctionCertificates) (Runtime, error) {
+		return &RuntimeFake{Target: lis.Addr().String(), OnStart: onStart}, nil
+	})
+
+	rf, err := Start(context.Background(), fns, withFakes)
+	if err != nil {
+		t.Fatalf("Start(...): %v", err)
+	}
+	rf.Cleanup()
+
+	if peak != MaxConcurrentFunctionStarts {
+		t.Errorf("Start(...): want %d Functions started at once, got %d", MaxConcurrentFunctionStarts, peak)
+	}
+}
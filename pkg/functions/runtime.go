@@ -1,11 +1,16 @@
-package main
+package functions
 
 import (
 	"context"
 
+	"google.golang.org/grpc/credentials"
+
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
 
 	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/timing"
 )
 
 // AnnotationKeyRuntime can be added to a Function to control what runtime is
@@ -23,8 +28,9 @@ const (
 	AnnotationValueRuntimeDocker RuntimeType = "Docker"
 
 	// The Development runtime expects you to deploy a Function locally. This is
-	// mostly useful when developing a Function. The Function must be running
-	// with the --insecure flag, i.e. without transport security.
+	// mostly useful when developing a Function. The Function must either be
+	// running with the --insecure flag, i.e. without transport security, or be
+	// configured with TLS certificates using annotations.
 	AnnotationValueRuntimeDevelopment RuntimeType = "Development"
 
 	AnnotationValueRuntimeDefault = AnnotationValueRuntimeDocker
@@ -41,15 +47,29 @@ type RuntimeContext struct {
 	// Target for RunFunctionRequest gRPCs.
 	Target string
 
+	// Credentials used to secure RunFunctionRequest gRPCs.
+	Credentials credentials.TransportCredentials
+
 	// Stop the running Function.
 	Stop func(context.Context) error
+
+	// Timings of the phases the runtime went through to start the Function,
+	// for example pulling an image. Optional.
+	Timings []timing.Record
 }
 
-// GetRuntime for the supplied Function, per its annotations.
-func GetRuntime(fn pkgv1beta1.Function) (Runtime, error) {
+// GetRuntime for the supplied Function, per its annotations. Runtimes that run
+// the Function themselves configure it to serve the supplied certificates, and
+// log using the supplied logger.
+func GetRuntime(fn pkgv1beta1.Function, certs *FunctionCertificates, log logging.Logger) (Runtime, error) {
 	switch r := RuntimeType(fn.GetAnnotations()[AnnotationKeyRuntime]); r {
 	case AnnotationValueRuntimeDocker, "":
-		return GetRuntimeDocker(fn)
+		d, err := GetRuntimeDocker(fn, certs)
+		if err != nil {
+			return nil, err
+		}
+		d.Log = log
+		return d, nil
 	case AnnotationValueRuntimeDevelopment:
 		return GetRuntimeDevelopment(fn), nil
 	default:
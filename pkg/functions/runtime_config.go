@@ -0,0 +1,163 @@
+package functions
+
+import (
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// RuntimeConfig API group, version and kind.
+const (
+	RuntimeConfigAPIVersion = "xrender.crossplane.io/v1beta1"
+	RuntimeConfigKind       = "RuntimeConfig"
+)
+
+// A RuntimeConfig configures how xrender runs Functions, without editing the
+// Function manifests. Settings in a RuntimeConfig take precedence over a
+// Function's annotations.
+type RuntimeConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Functions configures the runtime of Functions matching each entry.
+	// Entries are applied in order, so later entries take precedence over
+	// earlier ones.
+	Functions []FunctionRuntimeConfig `json:"functions"`
+}
+
+// FunctionRuntimeConfig configures the runtime of matching Functions.
+type FunctionRuntimeConfig struct {
+	// Name of the Function to configure.
+	Name string `json:"name,omitempty"`
+
+	// Package is a pattern matching the spec.package of Functions to
+	// configure, e.g. xpkg.upbound.io/crossplane-contrib/*. See path.Match
+	// for the pattern syntax.
+	Package string `json:"package,omitempty"`
+
+	// Runtime used to run matching Functions.
+	Runtime RuntimeType `json:"runtime,omitempty"`
+
+	// Docker runtime settings.
+	Docker *DockerRuntimeConfig `json:"docker,omitempty"`
+
+	// Development runtime settings.
+	Development *DevelopmentRuntimeConfig `json:"development,omitempty"`
+}
+
+// DockerRuntimeConfig configures the Docker runtime. Each field corresponds
+// to one of the Docker runtime's annotations.
+type DockerRuntimeConfig struct {
+	Image      string           `json:"image,omitempty"`
+	Cleanup    DockerCleanup    `json:"cleanup,omitempty"`
+	PullPolicy DockerPullPolicy `json:"pullPolicy,omitempty"`
+	Env        []string         `json:"env,omitempty"`
+	Mounts     []string         `json:"mounts,omitempty"`
+	Network    string           `json:"network,omitempty"`
+	CPUs       string           `json:"cpus,omitempty"`
+	Memory     string           `json:"memory,omitempty"`
+	User       string           `json:"user,omitempty"`
+	Args       []string         `json:"args,omitempty"`
+}
+
+// DevelopmentRuntimeConfig configures the Development runtime. Each field
+// corresponds to one of the Development runtime's annotations.
+type DevelopmentRuntimeConfig struct {
+	Target        string `json:"target,omitempty"`
+	TLSCABundle   string `json:"tlsCABundle,omitempty"`
+	TLSClientCert string `json:"tlsClientCert,omitempty"`
+	TLSClientKey  string `json:"tlsClientKey,omitempty"`
+}
+
+// Validate the RuntimeConfig.
+func (c *RuntimeConfig) Validate() error {
+	if c.APIVersion != RuntimeConfigAPIVersion || c.Kind != RuntimeConfigKind {
+		return errors.Errorf("must be apiVersion: %s, kind: %s", RuntimeConfigAPIVersion, RuntimeConfigKind)
+	}
+	for i, f := range c.Functions {
+		if f.Name == "" && f.Package == "" {
+			return errors.Errorf("functions[%d]: must specify a name or package", i)
+		}
+		if _, err := path.Match(f.Package, ""); err != nil {
+			return errors.Wrapf(err, "functions[%d]: invalid package pattern %q", i, f.Package)
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the configuration applies to the supplied Function.
+// When both a name and a package pattern are specified both must match.
+func (c FunctionRuntimeConfig) Matches(fn pkgv1beta1.Function) bool {
+	if c.Name != "" && c.Name != fn.GetName() {
+		return false
+	}
+	if c.Package != "" {
+		ok, _ := path.Match(c.Package, fn.Spec.Package)
+		return ok
+	}
+	return true
+}
+
+// Annotations returns the Function annotations equivalent to the
+// configuration. Unset fields don't produce an annotation.
+func (c FunctionRuntimeConfig) Annotations() map[string]string {
+	a := map[string]string{}
+	set := func(k, v string) {
+		if v != "" {
+			a[k] = v
+		}
+	}
+
+	set(AnnotationKeyRuntime, string(c.Runtime))
+	if d := c.Docker; d != nil {
+		set(AnnotationKeyRuntimeDockerImage, d.Image)
+		set(AnnotationKeyRuntimeDockerCleanup, string(d.Cleanup))
+		set(AnnotationKeyRuntimeDockerPullPolicy, string(d.PullPolicy))
+		set(AnnotationKeyRuntimeDockerEnv, strings.Join(d.Env, "\n"))
+		set(AnnotationKeyRuntimeDockerMounts, strings.Join(d.Mounts, "\n"))
+		set(AnnotationKeyRuntimeDockerNetwork, d.Network)
+		set(AnnotationKeyRuntimeDockerCPUs, d.CPUs)
+		set(AnnotationKeyRuntimeDockerMemory, d.Memory)
+		set(AnnotationKeyRuntimeDockerUser, d.User)
+		set(AnnotationKeyRuntimeDockerArgs, strings.Join(d.Args, "\n"))
+	}
+	if d := c.Development; d != nil {
+		set(AnnotationKeyRuntimeDevelopmentTarget, d.Target)
+		set(AnnotationKeyRuntimeDevelopmentTLSCABundle, d.TLSCABundle)
+		set(AnnotationKeyRuntimeDevelopmentTLSClientCert, d.TLSClientCert)
+		set(AnnotationKeyRuntimeDevelopmentTLSClientKey, d.TLSClientKey)
+	}
+	return a
+}
+
+// ApplyRuntimeConfigs returns a copy of the supplied Functions, annotated per
+// the supplied RuntimeConfigs. RuntimeConfigs are applied in order, so later
+// RuntimeConfigs (e.g. per-developer overrides) take precedence over earlier
+// ones.
+func ApplyRuntimeConfigs(fns []pkgv1beta1.Function, cfgs ...*RuntimeConfig) []pkgv1beta1.Function {
+	out := make([]pkgv1beta1.Function, len(fns))
+	for i := range fns {
+		fn := fns[i].DeepCopy()
+		for _, cfg := range cfgs {
+			for _, fc := range cfg.Functions {
+				if !fc.Matches(*fn) {
+					continue
+				}
+				a := fn.GetAnnotations()
+				if a == nil {
+					a = map[string]string{}
+				}
+				for k, v := range fc.Annotations() {
+					a[k] = v
+				}
+				fn.SetAnnotations(a)
+			}
+		}
+		out[i] = *fn
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pkgv1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestApplyRuntimeConfigs(t *testing.T) {
+	fn := func(name, pkg string, a map[string]string) pkgv1beta1.Function {
+		return pkgv1beta1.Function{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: a},
+			Spec:       pkgv1beta1.FunctionSpec{PackageSpec: pkgv1.PackageSpec{Package: pkg}},
+		}
+	}
+
+	shared := &RuntimeConfig{Functions: []FunctionRuntimeConfig{{
+		Package: "xpkg.upbound.io/crossplane-contrib/*",
+		Docker:  &DockerRuntimeConfig{PullPolicy: AnnotationValueRuntimeDockerPullPolicyAlways},
+	}}}
+	personal := &RuntimeConfig{Functions: []FunctionRuntimeConfig{{
+		Name:        "function-foo",
+		Runtime:     AnnotationValueRuntimeDevelopment,
+		Development: &DevelopmentRuntimeConfig{Target: "localhost:9444"},
+	}}}
+
+	in := []pkgv1beta1.Function{
+		fn("function-foo", "xpkg.upbound.io/crossplane-contrib/function-foo:v0.1.0", map[string]string{
+			AnnotationKeyRuntime: string(AnnotationValueRuntimeDocker),
+		}),
+		fn("function-bar", "xpkg.upbound.io/crossplane-contrib/function-bar:v0.1.0", nil),
+		fn("function-baz", "example.org/function-baz:v0.1.0", nil),
+	}
+
+	want := []pkgv1beta1.Function{
+		fn("function-foo", "xpkg.upbound.io/crossplane-contrib/function-foo:v0.1.0", map[string]string{
+			AnnotationKeyRuntime:                  string(AnnotationValueRuntimeDevelopment),
+			AnnotationKeyRuntimeDockerPullPolicy:  string(AnnotationValueRuntimeDockerPullPolicyAlways),
+			AnnotationKeyRuntimeDevelopmentTarget: "localhost:9444",
+		}),
+		fn("function-bar", "xpkg.upbound.io/crossplane-contrib/function-bar:v0.1.0", map[string]string{
+			AnnotationKeyRuntimeDockerPullPolicy: string(AnnotationValueRuntimeDockerPullPolicyAlways),
+		}),
+		fn("function-baz", "example.org/function-baz:v0.1.0", nil),
+	}
+
+	got := ApplyRuntimeConfigs(in, shared, personal)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ApplyRuntimeConfigs(...): -want, +got:\n%s", diff)
+	}
+
+	// The supplied Functions must not be mutated.
+	if diff := cmp.Diff(string(AnnotationValueRuntimeDocker), in[0].GetAnnotations()[AnnotationKeyRuntime]); diff != "" {
+		t.Errorf("ApplyRuntimeConfigs(...): supplied Function was mutated: -want, +got:\n%s", diff)
+	}
+}
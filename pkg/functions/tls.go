@@ -0,0 +1,144 @@
+package functions
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+// Paths at which function-sdk-go expects to find its server certificates, when
+// TLS_SERVER_CERTS_DIR is set to TLSServerCertsDir.
+const (
+	TLSServerCertsDir = "/tls/server"
+
+	TLSCertFile = "tls.crt"
+	TLSKeyFile  = "tls.key"
+	TLSCAFile   = "ca.crt"
+)
+
+// How long our ephemeral certificates are valid for.
+const tlsCertificateValidity = 24 * time.Hour
+
+// FunctionCertificates are ephemeral certificates used to secure connections to
+// Functions using mutual TLS, the same way Crossplane does.
+type FunctionCertificates struct {
+	// CA is the PEM encoded certificate of the CA that signed the server and
+	// client certificates.
+	CA []byte
+
+	// ServerCert and ServerKey are the PEM encoded certificate and private key
+	// a Function should serve.
+	ServerCert []byte
+	ServerKey  []byte
+
+	client tls.Certificate
+	pool   *x509.CertPool
+}
+
+// NewFunctionCertificates generates an ephemeral CA, and a server and client
+// certificate signed by it. The server certificate is valid for localhost.
+func NewFunctionCertificates() (*FunctionCertificates, error) {
+	now := time.Now()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot generate CA private key")
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "xrender-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(tlsCertificateValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create CA certificate")
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse CA certificate")
+	}
+
+	serverCert, serverKey, err := newSignedCertificate(ca, caKey, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "xrender-function"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(tlsCertificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create server certificate")
+	}
+
+	clientCert, clientKey, err := newSignedCertificate(ca, caKey, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "xrender"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(tlsCertificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create client certificate")
+	}
+	client, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load client certificate")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	return &FunctionCertificates{
+		CA:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		ServerCert: serverCert,
+		ServerKey:  serverKey,
+		client:     client,
+		pool:       pool,
+	}, nil
+}
+
+// ClientCredentials returns gRPC transport credentials that present the client
+// certificate, and trust only servers whose certificate is signed by the CA.
+func (c *FunctionCertificates) ClientCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		RootCAs:      c.pool,
+		Certificates: []tls.Certificate{c.client},
+	})
+}
+
+func newSignedCertificate(ca *x509.Certificate, caKey *ecdsa.PrivateKey, tmpl *x509.Certificate) (cert, key []byte, err error) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot generate private key")
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &k.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot sign certificate")
+	}
+	kder, err := x509.MarshalECPrivateKey(k)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot marshal private key")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}),
+		nil
+}
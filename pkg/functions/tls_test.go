@@ -0,0 +1,67 @@
+package functions
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+)
+
+func TestFunctionCertificates(t *testing.T) {
+	certs, err := NewFunctionCertificates()
+	if err != nil {
+		t.Fatalf("NewFunctionCertificates(): %v", err)
+	}
+
+	// Serve the way function-sdk-go does, requiring a client certificate
+	// signed by the CA.
+	cert, err := tls.X509KeyPair(certs.ServerCert, certs.ServerKey)
+	if err != nil {
+		t.Fatalf("tls.X509KeyPair(...): %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(certs.CA) {
+		t.Fatal("pool.AppendCertsFromPEM(...): no certificates appended")
+	}
+	creds := credentials.NewTLS(&tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	want := &fnv1beta1.RunFunctionResponse{Meta: &fnv1beta1.ResponseMeta{Tag: "mtls"}}
+	srv := grpc.NewServer(grpc.Creds(creds))
+	fnv1beta1.RegisterFunctionRunnerServiceServer(srv, &xtest.MockFunctionRunner{Response: want})
+	go srv.Serve(lis) //nolint:errcheck // This will stop when lis is closed.
+
+	conn, err := grpc.DialContext(context.Background(), lis.Addr().String(), grpc.WithTransportCredentials(certs.ClientCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.DialContext(...): %v", err)
+	}
+	defer conn.Close()
+
+	got, err := fnv1beta1.NewFunctionRunnerServiceClient(conn).RunFunction(context.Background(), &fnv1beta1.RunFunctionRequest{})
+	if err != nil {
+		t.Fatalf("RunFunction(...): %v", err)
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("RunFunction(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,72 @@
+package load
+
+import (
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// SharedInputFiles are the files from which to load the inputs used to render
+// XRs that don't depend on which XR is rendered.
+type SharedInputFiles struct {
+	// Compositions is a stream or directory of YAML manifests containing
+	// Compositions, and optionally CompositionRevisions.
+	Compositions string
+
+	// Functions is a stream or directory of YAML manifests containing
+	// Functions.
+	Functions string
+
+	// EnvironmentConfigs are optional streams or directories of YAML
+	// manifests containing EnvironmentConfigs.
+	EnvironmentConfigs []string
+
+	// XRD is an optional YAML manifest containing an XRD.
+	XRD string
+
+	// RuntimeConfigs are optional YAML manifests configuring how to run
+	// Functions. Later files take precedence.
+	RuntimeConfigs []string
+}
+
+// SharedInputs loads the inputs used to render XRs that don't depend on which
+// XR is rendered from the supplied files.
+func SharedInputs(f SharedInputFiles) (*render.SharedInputs, error) {
+	s := &render.SharedInputs{}
+
+	if f.XRD != "" {
+		xrd, err := CompositeResourceDefinition(f.XRD)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load composite resource definition from %q", f.XRD)
+		}
+		s.XRD = xrd
+	}
+
+	var err error
+	s.Compositions, s.CompositionRevisions, err = Compositions(f.Compositions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load Compositions from %q", f.Compositions)
+	}
+
+	s.Functions, err = Functions(f.Functions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load functions from %q", f.Functions)
+	}
+
+	s.RuntimeConfigs = make([]*functions.RuntimeConfig, 0, len(f.RuntimeConfigs))
+	for _, file := range f.RuntimeConfigs {
+		rc, err := RuntimeConfig(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load runtime config from %q", file)
+		}
+		s.RuntimeConfigs = append(s.RuntimeConfigs, rc)
+	}
+
+	s.EnvironmentConfigs, err = Each(f.EnvironmentConfigs, EnvironmentConfigs)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load EnvironmentConfigs")
+	}
+
+	return s, nil
+}
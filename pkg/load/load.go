@@ -0,0 +1,321 @@
+// Package load loads the inputs used to render XRs, such as XRs, Compositions
+// and Functions, from YAML manifests.
+package load
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// CompositeResource loads an XR from a YAML manifest.
+func CompositeResource(file string) (*composite.Unstructured, error) {
+	y, err := os.ReadFile(file) //nolint:gosec // Taking this input is intentional.
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read composite resource file")
+	}
+	xr := composite.New()
+	return xr, errors.Wrap(yaml.Unmarshal(y, xr), "cannot unmarshal composite resource YAML")
+}
+
+// CompositeResources loads XRs from a stream of YAML manifests.
+func CompositeResources(file string) ([]*composite.Unstructured, error) {
+	stream, err := YAMLStream(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load YAML stream from file")
+	}
+
+	xrs := make([]*composite.Unstructured, 0, len(stream))
+	for _, y := range stream {
+		xr := composite.New()
+		if err := yaml.Unmarshal(y, xr); err != nil {
+			return nil, errors.Wrap(err, "cannot parse YAML composite resource manifest")
+		}
+		xrs = append(xrs, xr)
+	}
+
+	return xrs, nil
+}
+
+// TODO(negz): Now that we load a YAML stream of Compositions we could render
+// out nested XRs too. What would that look like in our output?
+
+// Composition loads a Composition from a YAML manifest.
+func Composition(file string) (*apiextensionsv1.Composition, error) {
+	y, err := os.ReadFile(file) //nolint:gosec // Taking this as input is intentional.
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read composite resource file")
+	}
+	comp := &apiextensionsv1.Composition{}
+	return comp, errors.Wrap(yaml.Unmarshal(y, comp), "cannot unmarshal composite resource YAML")
+}
+
+// Compositions loads Compositions from a stream of YAML manifests. The stream
+// may contain Compositions, CompositionRevisions, or both.
+func Compositions(file string) ([]apiextensionsv1.Composition, []apiextensionsv1.CompositionRevision, error) {
+	stream, err := YAMLStream(file)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot load YAML stream from file")
+	}
+
+	comps := make([]apiextensionsv1.Composition, 0, len(stream))
+	revs := make([]apiextensionsv1.CompositionRevision, 0)
+	for _, y := range stream {
+		tm := &metav1.TypeMeta{}
+		if err := yaml.Unmarshal(y, tm); err != nil {
+			return nil, nil, errors.Wrap(err, "cannot parse YAML manifest")
+		}
+		switch tm.Kind {
+		case apiextensionsv1.CompositionRevisionKind:
+			rev := &apiextensionsv1.CompositionRevision{}
+			if err := yaml.Unmarshal(y, rev); err != nil {
+				return nil, nil, errors.Wrap(err, "cannot parse YAML CompositionRevision manifest")
+			}
+			revs = append(revs, *rev)
+		default:
+			comp := &apiextensionsv1.Composition{}
+			if err := yaml.Unmarshal(y, comp); err != nil {
+				return nil, nil, errors.Wrap(err, "cannot parse YAML Composition manifest")
+			}
+			comps = append(comps, *comp)
+		}
+	}
+
+	return comps, revs, nil
+}
+
+// CompositeResourceDefinition loads an XRD from a YAML manifest.
+func CompositeResourceDefinition(file string) (*apiextensionsv1.CompositeResourceDefinition, error) {
+	y, err := os.ReadFile(file) //nolint:gosec // Taking this as input is intentional.
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read composite resource definition file")
+	}
+	xrd := &apiextensionsv1.CompositeResourceDefinition{}
+	return xrd, errors.Wrap(yaml.Unmarshal(y, xrd), "cannot unmarshal composite resource definition YAML")
+}
+
+// TODO(negz): Support optionally loading functions and observed resources from
+// a directory of manifests instead of a single stream.
+
+// Functions loads Functions from a stream of YAML manifests.
+func Functions(file string) ([]pkgv1beta1.Function, error) {
+	stream, err := YAMLStream(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load YAML stream from file")
+	}
+
+	fns := make([]pkgv1beta1.Function, 0, len(stream))
+	for _, y := range stream {
+		f := &pkgv1beta1.Function{}
+		if err := yaml.Unmarshal(y, f); err != nil {
+			return nil, errors.Wrap(err, "cannot parse YAML Function manifest")
+		}
+		fns = append(fns, *f)
+	}
+
+	return fns, nil
+}
+
+// Each loads each of the supplied files using the supplied function, and
+// returns everything that was loaded.
+func Each[T any](files []string, load func(file string) ([]T, error)) ([]T, error) {
+	out := make([]T, 0)
+	for _, file := range files {
+		loaded, err := load(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load %q", file)
+		}
+		out = append(out, loaded...)
+	}
+	return out, nil
+}
+
+// EnvironmentConfigs loads EnvironmentConfigs from a stream of YAML manifests.
+func EnvironmentConfigs(file string) ([]v1alpha1.EnvironmentConfig, error) {
+	stream, err := YAMLStream(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load YAML stream from file")
+	}
+
+	ecs := make([]v1alpha1.EnvironmentConfig, 0, len(stream))
+	for _, y := range stream {
+		ec := &v1alpha1.EnvironmentConfig{}
+		if err := yaml.Unmarshal(y, ec); err != nil {
+			return nil, errors.Wrap(err, "cannot parse YAML EnvironmentConfig manifest")
+		}
+		ecs = append(ecs, *ec)
+	}
+
+	return ecs, nil
+}
+
+// ObservedResources loads observed composed resources from a stream of YAML
+// manifests.
+func ObservedResources(file string) ([]composed.Unstructured, error) {
+	stream, err := YAMLStream(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load YAML stream from file")
+	}
+
+	observed := make([]composed.Unstructured, 0, len(stream))
+	for _, y := range stream {
+		cd := composed.New()
+		if err := yaml.Unmarshal(y, cd); err != nil {
+			return nil, errors.Wrap(err, "cannot parse YAML composed resource manifest")
+		}
+		observed = append(observed, *cd)
+	}
+
+	return observed, nil
+}
+
+// RuntimeConfig loads a RuntimeConfig from a YAML manifest.
+func RuntimeConfig(file string) (*functions.RuntimeConfig, error) {
+	y, err := os.ReadFile(file) //nolint:gosec // Taking this input is intentional.
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read runtime config file")
+	}
+	cfg := &functions.RuntimeConfig{}
+	if err := yaml.UnmarshalStrict(y, cfg); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal runtime config YAML")
+	}
+	return cfg, errors.Wrap(cfg.Validate(), "invalid runtime config")
+}
+
+// Assertions loads assertions from a stream or directory of YAML manifests.
+func Assertions(file string) ([]render.Assertion, error) {
+	stream, err := YAMLStream(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load YAML stream from file")
+	}
+
+	as := make([]render.Assertion, 0)
+	for _, y := range stream {
+		a := &render.Assertions{}
+		if err := yaml.UnmarshalStrict(y, a); err != nil {
+			return nil, errors.Wrap(err, "cannot unmarshal assertions YAML")
+		}
+		if err := a.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid assertions")
+		}
+		as = append(as, a.Assertions...)
+	}
+	return as, nil
+}
+
+// PipelineState loads desired state and context from a YAML manifest. The
+// manifest may be either a RunFunctionRequest or a RunFunctionResponse, for
+// example as written by output.WriteTrace.
+func PipelineState(file string) (*fnv1beta1.State, *structpb.Struct, error) {
+	y, err := os.ReadFile(file) //nolint:gosec // Taking this input is intentional.
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot read pipeline state file")
+	}
+	j, err := yaml.ToJSON(y)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot convert pipeline state YAML to JSON")
+	}
+
+	// A RunFunctionResponse's desired state and context use the same fields as
+	// a RunFunctionRequest's, so we can load either as a response.
+	rsp := &fnv1beta1.RunFunctionResponse{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(j, rsp); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot unmarshal pipeline state")
+	}
+	return rsp.GetDesired(), rsp.GetContext(), nil
+}
+
+// YAMLStream loads a YAML stream from the supplied file or directory. Returns
+// an array of byte arrays, where each byte array is expected to be a YAML
+// manifest.
+func YAMLStream(fileOrDir string) ([][]byte, error) {
+	files, err := YAMLFiles(fileOrDir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]byte, 0)
+	for i := range files {
+		o, err := YAMLStreamFromFile(files[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load YAML stream from file")
+		}
+		out = append(out, o...)
+	}
+
+	return out, nil
+}
+
+// YAMLFiles returns the supplied file, or the YAML files in the supplied
+// directory sorted by name.
+func YAMLFiles(fileOrDir string) ([]string, error) {
+	info, err := os.Stat(fileOrDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot stat file")
+	}
+	if !info.IsDir() {
+		return []string{fileOrDir}, nil
+	}
+
+	// filepath.Glob doesn't support {yaml,yml} style alternates.
+	var files []string
+	for _, ext := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(fileOrDir, ext))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot glob YAML files")
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		return nil, errors.Errorf("no YAML files found in %q (.yaml or .yml)", fileOrDir)
+	}
+	return files, nil
+}
+
+// YAMLStreamFromFile loads a YAML stream from the supplied file. Returns an
+// array of byte arrays, where each byte array is expected to be a YAML
+// manifest.
+func YAMLStreamFromFile(file string) ([][]byte, error) {
+	out := make([][]byte, 0)
+	f, err := os.Open(file) //nolint:gosec // Taking this input is intentional.
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open file")
+	}
+	defer f.Close() //nolint:errcheck // Only open for reading.
+	yr := yaml.NewYAMLReader(bufio.NewReader(f))
+
+	for {
+		bytes, err := yr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse YAML stream")
+		}
+		if len(bytes) == 0 {
+			continue
+		}
+		out = append(out, bytes)
+	}
+	return out, nil
+}
@@ -0,0 +1,609 @@
+package load
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+func TestLoadCompositeResource(t *testing.T) {
+	type want struct {
+		xr  *composite.Unstructured
+		err error
+	}
+	cases := map[string]struct {
+		file string
+		want want
+	}{
+		"Success": {
+			file: "testdata/xr.yaml",
+			want: want{
+				xr: &composite.Unstructured{
+					Unstructured: unstructured.Unstructured{
+						Object: xtest.MustLoadJSON(`{
+							"apiVersion": "nop.example.org/v1alpha1",
+							"kind": "XNopResource",
+							"metadata": {
+								"name": "test-xrender"
+							},
+							"spec": {
+								"coolField": "I'm cool!"
+							}
+						}`),
+					},
+				},
+			},
+		},
+		"NoSuchFile": {
+			file: "testdata/nonexist.yaml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xr, err := CompositeResource(tc.file)
+
+			if diff := cmp.Diff(tc.want.xr, xr, test.EquateConditions()); diff != "" {
+				t.Errorf("CompositeResource(..), -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("CompositeResource(..), -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadCompositeResources(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml": "apiVersion: nop.example.org/v1alpha1\nkind: XNopResource\nmetadata:\n  name: a\n",
+		"b.yml":  "apiVersion: nop.example.org/v1alpha1\nkind: XNopResource\nmetadata:\n  name: b\n---\napiVersion: nop.example.org/v1alpha1\nkind: XNopResource\nmetadata:\n  name: c\n",
+		"d.txt":  "not: yaml we should load\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	xrs, err := CompositeResources(dir)
+	if err != nil {
+		t.Fatalf("CompositeResources(...): %s", err)
+	}
+
+	got := make([]string, 0, len(xrs))
+	for _, xr := range xrs {
+		got = append(got, xr.GetName())
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, got); diff != "" {
+		t.Errorf("CompositeResources(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestLoadComposition(t *testing.T) {
+	pipeline := apiextensionsv1.CompositionModePipeline
+
+	type want struct {
+		comp *apiextensionsv1.Composition
+		err  error
+	}
+	cases := map[string]struct {
+		file string
+		want want
+	}{
+		"Success": {
+			file: "testdata/composition.yaml",
+			want: want{
+				comp: &apiextensionsv1.Composition{
+					TypeMeta: metav1.TypeMeta{
+						Kind:       apiextensionsv1.CompositionKind,
+						APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+					},
+					ObjectMeta: metav1.ObjectMeta{Name: "xnopresources.nop.example.org"},
+					Spec: apiextensionsv1.CompositionSpec{
+						CompositeTypeRef: apiextensionsv1.TypeReference{
+							APIVersion: "nop.example.org/v1alpha1",
+							Kind:       "XNopResource",
+						},
+						Mode: &pipeline,
+						Pipeline: []apiextensionsv1.PipelineStep{{
+							Step:        "be-a-dummy",
+							FunctionRef: apiextensionsv1.FunctionReference{Name: "function-dummy"},
+						}},
+					},
+				},
+			},
+		},
+		"NoSuchFile": {
+			file: "testdata/nonexist.yaml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xr, err := Composition(tc.file)
+
+			if diff := cmp.Diff(tc.want.comp, xr, test.EquateConditions()); diff != "" {
+				t.Errorf("Composition(..), -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("Composition(..), -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadCompositeResourceDefinition(t *testing.T) {
+	type want struct {
+		xrd *apiextensionsv1.CompositeResourceDefinition
+		err error
+	}
+	cases := map[string]struct {
+		file string
+		want want
+	}{
+		"Success": {
+			file: "testdata/xrd.yaml",
+			want: want{
+				xrd: &apiextensionsv1.CompositeResourceDefinition{
+					TypeMeta: metav1.TypeMeta{
+						Kind:       apiextensionsv1.CompositeResourceDefinitionKind,
+						APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+					},
+					ObjectMeta: metav1.ObjectMeta{Name: "xnopresources.nop.example.org"},
+					Spec: apiextensionsv1.CompositeResourceDefinitionSpec{
+						Group: "nop.example.org",
+						Names: extv1.CustomResourceDefinitionNames{Kind: "XNopResource", Plural: "xnopresources"},
+						Versions: []apiextensionsv1.CompositeResourceDefinitionVersion{{
+							Name:          "v1alpha1",
+							Served:        true,
+							Referenceable: true,
+							Schema: &apiextensionsv1.CompositeResourceValidation{
+								OpenAPIV3Schema: runtime.RawExtension{Raw: []byte(`{
+									"type": "object",
+									"properties": {
+										"spec": {
+											"type": "object",
+											"properties": {
+												"coolField": {"type": "string"},
+												"region": {"type": "string", "default": "us-east-2"}
+											},
+											"required": ["coolField"]
+										}
+									}
+								}`)},
+							},
+						}},
+					},
+				},
+			},
+		},
+		"NoSuchFile": {
+			file: "testdata/nonexist.yaml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xrd, err := CompositeResourceDefinition(tc.file)
+
+			if diff := cmp.Diff(tc.want.xrd, xrd, xtest.EquateRawJSON); diff != "" {
+				t.Errorf("CompositeResourceDefinition(..), -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("CompositeResourceDefinition(..), -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadFunctions(t *testing.T) {
+
+	type want struct {
+		fns []pkgv1beta1.Function
+		err error
+	}
+	cases := map[string]struct {
+		file string
+		want want
+	}{
+		"Success": {
+			file: "testdata/functions.yaml",
+			want: want{
+				fns: []pkgv1beta1.Function{
+					{
+						TypeMeta: metav1.TypeMeta{
+							Kind:       pkgv1beta1.FunctionKind,
+							APIVersion: pkgv1beta1.SchemeGroupVersion.String(),
+						},
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "function-auto-ready",
+							Annotations: map[string]string{
+								functions.AnnotationKeyRuntime:              string(functions.AnnotationValueRuntimeDocker),
+								functions.AnnotationKeyRuntimeDockerCleanup: string(functions.AnnotationValueRuntimeDockerCleanupOrphan),
+							},
+						},
+						Spec: pkgv1beta1.FunctionSpec{
+							PackageSpec: pkgv1.PackageSpec{
+								Package: "xpkg.upbound.io/crossplane-contrib/function-auto-ready:v0.1.2",
+							},
+						},
+					},
+					{
+						TypeMeta: metav1.TypeMeta{
+							Kind:       pkgv1beta1.FunctionKind,
+							APIVersion: pkgv1beta1.SchemeGroupVersion.String(),
+						},
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "function-dummy",
+							Annotations: map[string]string{
+								functions.AnnotationKeyRuntime:                  string(functions.AnnotationValueRuntimeDevelopment),
+								functions.AnnotationKeyRuntimeDevelopmentTarget: "localhost:9444",
+							},
+						},
+						Spec: pkgv1beta1.FunctionSpec{
+							PackageSpec: pkgv1.PackageSpec{
+								Package: "xpkg.upbound.io/crossplane-contrib/function-dummy:v0.2.1",
+							},
+						},
+					},
+				},
+			},
+		},
+		"NoSuchFile": {
+			file: "testdata/nonexist.yaml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xr, err := Functions(tc.file)
+
+			if diff := cmp.Diff(tc.want.fns, xr, test.EquateConditions()); diff != "" {
+				t.Errorf("Functions(..), -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("Functions(..), -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadObservedResources(t *testing.T) {
+
+	type want struct {
+		ors []composed.Unstructured
+		err error
+	}
+	cases := map[string]struct {
+		file string
+		want want
+	}{
+		"Success": {
+			file: "testdata/observed.yaml",
+			want: want{
+				ors: []composed.Unstructured{
+					{
+						Unstructured: unstructured.Unstructured{Object: xtest.MustLoadJSON(`{
+							"apiVersion": "example.org/v1alpha1",
+							"kind": "ComposedResource",
+							"metadata": {
+								"name": "test-xrender-a",
+								"annotations": {
+									"crossplane.io/composition-resource-name": "resource-a"
+								}
+							},
+							"spec": {
+								"coolField": "I'm cool!"
+							}
+						}`)},
+					},
+					{
+						Unstructured: unstructured.Unstructured{Object: xtest.MustLoadJSON(`{
+							"apiVersion": "example.org/v1alpha1",
+							"kind": "ComposedResource",
+							"metadata": {
+								"name": "test-xrender-b",
+								"annotations": {
+									"crossplane.io/composition-resource-name": "resource-b"
+								}
+							},
+							"spec": {
+								"coolerField": "I'm cooler!"
+							}
+						}`)},
+					},
+				},
+			},
+		},
+		"NoSuchFile": {
+			file: "testdata/nonexist.yaml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xr, err := ObservedResources(tc.file)
+
+			if diff := cmp.Diff(tc.want.ors, xr, test.EquateConditions()); diff != "" {
+				t.Errorf("ObservedResources(..), -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("ObservedResources(..), -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadRuntimeConfig(t *testing.T) {
+	type want struct {
+		cfg *functions.RuntimeConfig
+		err error
+	}
+	cases := map[string]struct {
+		file string
+		want want
+	}{
+		"Success": {
+			file: "testdata/runtimeconfig.yaml",
+			want: want{
+				cfg: &functions.RuntimeConfig{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: functions.RuntimeConfigAPIVersion,
+						Kind:       functions.RuntimeConfigKind,
+					},
+					Functions: []functions.FunctionRuntimeConfig{
+						{
+							Package: "xpkg.upbound.io/crossplane-contrib/*",
+							Runtime: functions.AnnotationValueRuntimeDocker,
+							Docker:  &functions.DockerRuntimeConfig{Cleanup: functions.AnnotationValueRuntimeDockerCleanupOrphan},
+						},
+						{
+							Name:        "function-dummy",
+							Runtime:     functions.AnnotationValueRuntimeDevelopment,
+							Development: &functions.DevelopmentRuntimeConfig{Target: "localhost:9444"},
+						},
+					},
+				},
+			},
+		},
+		"NotARuntimeConfig": {
+			file: "testdata/xr.yaml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"NoSuchFile": {
+			file: "testdata/nonexist.yaml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := RuntimeConfig(tc.file)
+
+			if diff := cmp.Diff(tc.want.cfg, cfg, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("RuntimeConfig(..), -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("RuntimeConfig(..), -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadAssertions(t *testing.T) {
+	type want struct {
+		as  []render.Assertion
+		err error
+	}
+	cases := map[string]struct {
+		file string
+		want want
+	}{
+		"Success": {
+			file: "testdata/assertions.yaml",
+			want: want{
+				as: []render.Assertion{
+					{
+						Name:       "buckets-are-orphaned",
+						Expression: "composed.all(r, r.kind != 'Bucket' || r.spec.deletionPolicy == 'Orphan')",
+						Message:    "Buckets must have spec.deletionPolicy Orphan",
+					},
+					{
+						Name:       "team-tagged",
+						Expression: "composed.all(r, has(r.metadata.labels) && 'team' in r.metadata.labels)",
+						Severity:   render.AssertionSeverityWarning,
+					},
+				},
+			},
+		},
+		"NotAssertions": {
+			file: "testdata/runtimeconfig.yaml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			as, err := Assertions(tc.file)
+
+			if diff := cmp.Diff(tc.want.as, as); diff != "" {
+				t.Errorf("Assertions(..), -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("Assertions(..), -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadPipelineState(t *testing.T) {
+	type want struct {
+		desired *fnv1beta1.State
+		ctx     *structpb.Struct
+		err     error
+	}
+	cases := map[string]struct {
+		file string
+		want want
+	}{
+		"Success": {
+			file: "testdata/pipelinestate.yaml",
+			want: want{
+				desired: &fnv1beta1.State{
+					Resources: map[string]*fnv1beta1.Resource{
+						"cool-resource": {Resource: xtest.MustStructJSON(`{"apiVersion":"test.crossplane.io/v1","kind":"Composed"}`)},
+					},
+				},
+				ctx: xtest.MustStructJSON(`{"apiextensions.crossplane.io/environment":{"cool":true}}`),
+			},
+		},
+		"NoSuchFile": {
+			file: "testdata/nonexist.yaml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			desired, ctx, err := PipelineState(tc.file)
+
+			if diff := cmp.Diff(tc.want.desired, desired, protocmp.Transform()); diff != "" {
+				t.Errorf("PipelineState(..), -want desired, +got desired:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.ctx, ctx, protocmp.Transform()); diff != "" {
+				t.Errorf("PipelineState(..), -want context, +got context:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("PipelineState(..), -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadSharedInputs(t *testing.T) {
+	type want struct {
+		xrd          string
+		compositions []string
+		functions    []string
+		configs      int
+		err          error
+	}
+	cases := map[string]struct {
+		reason string
+		files  SharedInputFiles
+		want   want
+	}{
+		"Success": {
+			reason: "We should load every supplied file.",
+			files: SharedInputFiles{
+				Compositions:   "testdata/composition.yaml",
+				Functions:      "testdata/functions.yaml",
+				XRD:            "testdata/xrd.yaml",
+				RuntimeConfigs: []string{"testdata/runtimeconfig.yaml"},
+			},
+			want: want{
+				xrd:          "xnopresources.nop.example.org",
+				compositions: []string{"xnopresources.nop.example.org"},
+				functions:    []string{"function-auto-ready", "function-dummy"},
+				configs:      1,
+			},
+		},
+		"NoSuchXRD": {
+			reason: "We should return an error if we can't load the XRD.",
+			files: SharedInputFiles{
+				Compositions: "testdata/composition.yaml",
+				Functions:    "testdata/functions.yaml",
+				XRD:          "testdata/nonexist.yaml",
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"NoSuchRuntimeConfig": {
+			reason: "We should return an error if we can't load a runtime config.",
+			files: SharedInputFiles{
+				Compositions:   "testdata/composition.yaml",
+				Functions:      "testdata/functions.yaml",
+				RuntimeConfigs: []string{"testdata/nonexist.yaml"},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s, err := SharedInputs(tc.files)
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("\n%s\nSharedInputs(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+
+			got := want{xrd: s.XRD.GetName(), configs: len(s.RuntimeConfigs)}
+			for _, c := range s.Compositions {
+				got.compositions = append(got.compositions, c.GetName())
+			}
+			for _, fn := range s.Functions {
+				got.functions = append(got.functions, fn.GetName())
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}), cmpopts.IgnoreFields(want{}, "err")); diff != "" {
+				t.Errorf("\n%s\nSharedInputs(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
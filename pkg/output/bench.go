@@ -0,0 +1,116 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// Formats in which a benchmark can be written.
+const (
+	BenchmarkFormatTable = "table"
+	BenchmarkFormatJSON  = "json"
+)
+
+// A jsonLatency is a render.Distribution of latencies, as written by
+// WriteBenchmark.
+type jsonLatency struct {
+	Count       int     `json:"count"`
+	MinSeconds  float64 `json:"minSeconds"`
+	MeanSeconds float64 `json:"meanSeconds"`
+	P50Seconds  float64 `json:"p50Seconds"`
+	P95Seconds  float64 `json:"p95Seconds"`
+	P99Seconds  float64 `json:"p99Seconds"`
+	MaxSeconds  float64 `json:"maxSeconds"`
+}
+
+// A jsonSize is a render.Distribution of sizes, as written by WriteBenchmark.
+type jsonSize struct {
+	Count int `json:"count"`
+	Min   int `json:"min"`
+	Mean  int `json:"mean"`
+	P50   int `json:"p50"`
+	P95   int `json:"p95"`
+	P99   int `json:"p99"`
+	Max   int `json:"max"`
+}
+
+// A jsonStepBenchmark is a render.StepBenchmark, as written by WriteBenchmark.
+type jsonStepBenchmark struct {
+	Step          string      `json:"step"`
+	Function      string      `json:"function"`
+	Latency       jsonLatency `json:"latency"`
+	ResponseBytes jsonSize    `json:"responseBytes"`
+}
+
+// A jsonBenchmark is a render.Benchmark, as written by WriteBenchmark.
+type jsonBenchmark struct {
+	Renders jsonLatency         `json:"renders"`
+	Steps   []jsonStepBenchmark `json:"steps"`
+}
+
+// WriteBenchmark writes the supplied benchmark to the supplied writer, either as
+// a table or as a JSON object.
+func WriteBenchmark(w io.Writer, b render.Benchmark, format string) error {
+	switch format {
+	case BenchmarkFormatTable:
+		return writeBenchmarkTable(w, b)
+	case BenchmarkFormatJSON:
+		return writeBenchmarkJSON(w, b)
+	default:
+		return errors.Errorf("unknown benchmark format %q", format)
+	}
+}
+
+func writeBenchmarkTable(w io.Writer, b render.Benchmark) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tFUNCTION\tCOUNT\tP50\tP95\tP99\tMAX\tRESPONSE BYTES P50\tP95\tP99\tMAX")
+	l := b.Renders
+	fmt.Fprintf(tw, "(render)\t-\t%d\t%s\t%s\t%s\t%s\t-\t-\t-\t-\n", l.Count, round(l.P50), round(l.P95), round(l.P99), round(l.Max))
+	for _, s := range b.Steps {
+		l, r := s.Latency, s.ResponseBytes
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\n", s.Step, s.Function, l.Count, round(l.P50), round(l.P95), round(l.P99), round(l.Max), r.P50, r.P95, r.P99, r.Max)
+	}
+	return tw.Flush()
+}
+
+func writeBenchmarkJSON(w io.Writer, b render.Benchmark) error {
+	out := jsonBenchmark{Renders: latency(b.Renders), Steps: make([]jsonStepBenchmark, len(b.Steps))}
+	for i, s := range b.Steps {
+		r := s.ResponseBytes
+		out.Steps[i] = jsonStepBenchmark{
+			Step:          s.Step,
+			Function:      s.Function,
+			Latency:       latency(s.Latency),
+			ResponseBytes: jsonSize{Count: r.Count, Min: r.Min, Mean: r.Mean, P50: r.P50, P95: r.P95, P99: r.P99, Max: r.Max},
+		}
+	}
+	j, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal benchmark to JSON")
+	}
+	_, err = fmt.Fprintln(w, string(j))
+	return err
+}
+
+func latency(d render.Distribution[time.Duration]) jsonLatency {
+	return jsonLatency{
+		Count:       d.Count,
+		MinSeconds:  d.Min.Seconds(),
+		MeanSeconds: d.Mean.Seconds(),
+		P50Seconds:  d.P50.Seconds(),
+		P95Seconds:  d.P95.Seconds(),
+		P99Seconds:  d.P99.Seconds(),
+		MaxSeconds:  d.Max.Seconds(),
+	}
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,108 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+func TestWriteBenchmark(t *testing.T) {
+	b := render.Benchmark{
+		Renders: render.Distribution[time.Duration]{Count: 2, Min: time.Millisecond, Mean: 1500 * time.Microsecond, P50: time.Millisecond, P95: 2 * time.Millisecond, P99: 2 * time.Millisecond, Max: 2 * time.Millisecond},
+		Steps: []render.StepBenchmark{{
+			Step:          "one",
+			Function:      "function-a",
+			Latency:       render.Distribution[time.Duration]{Count: 2, Min: 500 * time.Microsecond, Mean: 750 * time.Microsecond, P50: 500 * time.Microsecond, P95: time.Millisecond, P99: time.Millisecond, Max: time.Millisecond},
+			ResponseBytes: render.Distribution[int]{Count: 2, Min: 100, Mean: 150, P50: 100, P95: 200, P99: 200, Max: 200},
+		}},
+	}
+
+	type want struct {
+		out string
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		format string
+		want   want
+	}{
+		"Table": {
+			reason: "A benchmark should be written as a table, with a row for full renders followed by a row for each step.",
+			format: BenchmarkFormatTable,
+			want: want{
+				out: "" +
+					"STEP       FUNCTION     COUNT   P50     P95   P99   MAX   RESPONSE BYTES P50   P95   P99   MAX\n" +
+					"(render)   -            2       1ms     2ms   2ms   2ms   -                    -     -     -\n" +
+					"one        function-a   2       500µs   1ms   1ms   1ms   100                  200   200   200\n",
+			},
+		},
+		"JSON": {
+			reason: "A benchmark should be written as a JSON object, with latencies in seconds.",
+			format: BenchmarkFormatJSON,
+			want: want{
+				out: `{
+  "renders": {
+    "count": 2,
+    "minSeconds": 0.001,
+    "meanSeconds": 0.0015,
+    "p50Seconds": 0.001,
+    "p95Seconds": 0.002,
+    "p99Seconds": 0.002,
+    "maxSeconds": 0.002
+  },
+  "steps": [
+    {
+      "step": "one",
+      "function": "function-a",
+      "latency": {
+        "count": 2,
+        "minSeconds": 0.0005,
+        "meanSeconds": 0.00075,
+        "p50Seconds": 0.0005,
+        "p95Seconds": 0.001,
+        "p99Seconds": 0.001,
+        "maxSeconds": 0.001
+      },
+      "responseBytes": {
+        "count": 2,
+        "min": 100,
+        "mean": 150,
+        "p50": 100,
+        "p95": 200,
+        "p99": 200,
+        "max": 200
+      }
+    }
+  ]
+}
+`,
+			},
+		},
+		"UnknownFormat": {
+			reason: "We should return an error if asked to write a benchmark in an unknown format.",
+			format: "xml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := WriteBenchmark(buf, b, tc.format)
+
+			if diff := cmp.Diff(tc.want.out, buf.String()); diff != "" {
+				t.Errorf("%s\nWriteBenchmark(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nWriteBenchmark(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
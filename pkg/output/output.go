@@ -0,0 +1,161 @@
+// Package output writes rendered XRs, traces and reports.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// WriteOutputs writes the supplied render outputs to the supplied writer as a
+// stream of YAML manifests. Composed resources that would be deleted are
+// emitted as 'fake' KRM-like objects of kind: Deletion. Results are emitted as
+// 'fake' KRM-like objects of kind: Result if includeResults is true.
+func WriteOutputs(w io.Writer, out render.Outputs, includeResults bool) error {
+	// TODO(negz): Right now we're just emitting the desired state, which is an
+	// overlay on the observed state. Would it be more useful to apply the
+	// overlay to show something more like what the final result would be? The
+	// challenge with that would be that we'd have to try emulate what
+	// server-side apply would do (e.g. merging vs atomically replacing arrays)
+	// and we don't have enough context (i.e. OpenAPI schemas) to do that.
+
+	s := kjson.NewSerializerWithOptions(kjson.DefaultMetaFactory, nil, nil, kjson.SerializerOptions{Yaml: true})
+
+	fmt.Fprintln(w, "---")
+	if err := s.Encode(out.CompositeResource, w); err != nil {
+		return errors.Wrapf(err, "cannot marshal composite resource %q to YAML", out.CompositeResource.GetName())
+	}
+
+	for i := range out.ComposedResources {
+		fmt.Fprintln(w, "---")
+		if err := s.Encode(&out.ComposedResources[i], w); err != nil {
+			// TODO(negz): Use composed name annotation instead.
+			return errors.Wrapf(err, "cannot marshal composed resource %q to YAML", out.ComposedResources[i].GetName())
+		}
+	}
+
+	for i := range out.Deletions {
+		fmt.Fprintln(w, "---")
+		if err := s.Encode(&out.Deletions[i], w); err != nil {
+			return errors.Wrap(err, "cannot marshal deletion to YAML")
+		}
+	}
+
+	if includeResults {
+		for i := range out.Results {
+			fmt.Fprintln(w, "---")
+			if err := s.Encode(&out.Results[i], w); err != nil {
+				return errors.Wrap(err, "cannot marshal result to YAML")
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteIterations writes the supplied render outputs of each simulated
+// reconcile to the supplied writer as a stream of YAML manifests. Each
+// iteration's manifests are preceded by a comment identifying the iteration.
+func WriteIterations(w io.Writer, outs []render.Outputs, includeResults bool) error {
+	for i, out := range outs {
+		fmt.Fprintf(w, "# Iteration %d of %d\n", i+1, len(outs))
+		if err := WriteOutputs(w, out, includeResults); err != nil {
+			return errors.Wrapf(err, "cannot write iteration %d", i+1)
+		}
+	}
+	return nil
+}
+
+// WriteComposition writes the supplied Composition to the supplied writer as a
+// YAML manifest. Fields the API server would set, like the creation timestamp
+// and status, are omitted.
+func WriteComposition(w io.Writer, comp *apiextensionsv1.Composition) error {
+	j, err := json.Marshal(comp)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal Composition to JSON")
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(j); err != nil {
+		return errors.Wrap(err, "cannot unmarshal Composition JSON")
+	}
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "status")
+
+	y, err := yaml.Marshal(u.Object)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal Composition to YAML")
+	}
+	fmt.Fprintln(w, "---")
+	_, err = w.Write(y)
+	return errors.Wrap(err, "cannot write Composition")
+}
+
+// WriteComparison writes a human readable description of the supplied
+// Comparison to the supplied writer. Unchanged composed resources are omitted
+// unless includeUnchanged is true.
+func WriteComparison(w io.Writer, c render.Comparison, includeUnchanged bool) error {
+	rcs := append([]render.ResourceComparison{c.CompositeResource}, c.ComposedResources...)
+	for i, rc := range rcs {
+		if rc.Change == render.ChangeUnchanged && !includeUnchanged {
+			continue
+		}
+		what := fmt.Sprintf("Composed resource %q", rc.Name)
+		if i == 0 {
+			what = fmt.Sprintf("Composite resource %q", rc.Name)
+		}
+		if _, err := fmt.Fprintf(w, "%s (%s %s): %s\n", what, rc.APIVersion, rc.Kind, rc.Change); err != nil {
+			return errors.Wrapf(err, "cannot write comparison of %s", what)
+		}
+		if rc.Diff == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "-base, +head:\n%s\n", rc.Diff); err != nil {
+			return errors.Wrapf(err, "cannot write diff of %s", what)
+		}
+	}
+
+	for _, r := range c.ResultsAdded {
+		if _, err := fmt.Fprintf(w, "Result added: step %q returned %s %q\n", r.Object["step"], r.Object["severity"], r.Object["message"]); err != nil {
+			return errors.Wrap(err, "cannot write added result")
+		}
+	}
+	for _, r := range c.ResultsRemoved {
+		if _, err := fmt.Fprintf(w, "Result removed: step %q returned %s %q\n", r.Object["step"], r.Object["severity"], r.Object["message"]); err != nil {
+			return errors.Wrap(err, "cannot write removed result")
+		}
+	}
+
+	if c.Changed() {
+		return nil
+	}
+	_, err := fmt.Fprintln(w, "No changes.")
+	return errors.Wrap(err, "cannot write comparison")
+}
+
+// WriteBatchSummary writes a summary of the supplied batch results to the
+// supplied writer, including why each failed XR failed.
+func WriteBatchSummary(w io.Writer, results []render.BatchResult) {
+	failed := 0
+	for _, r := range results {
+		if r.Error != nil {
+			failed++
+		}
+	}
+
+	fmt.Fprintf(w, "Rendered %d XRs: %d succeeded, %d failed.\n", len(results), len(results)-failed, failed)
+	for _, r := range results {
+		if r.Error != nil {
+			fmt.Fprintf(w, "  %s: %s\n", r.Name, r.Error)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+func TestWriteComposition(t *testing.T) {
+	comp := &apiextensionsv1.Composition{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.crossplane.io/v1", Kind: "Composition"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cool-composition"},
+		Spec: apiextensionsv1.CompositionSpec{
+			CompositeTypeRef: apiextensionsv1.TypeReference{APIVersion: "example.org/v1", Kind: "XR"},
+			Mode:             ptr.To(apiextensionsv1.CompositionModePipeline),
+			Pipeline: []apiextensionsv1.PipelineStep{{
+				Step:        "test",
+				FunctionRef: apiextensionsv1.FunctionReference{Name: "function-test"},
+			}},
+		},
+	}
+
+	want := `---
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: cool-composition
+spec:
+  compositeTypeRef:
+    apiVersion: example.org/v1
+    kind: XR
+  mode: Pipeline
+  pipeline:
+  - functionRef:
+      name: function-test
+    step: test
+`
+
+	b := &strings.Builder{}
+	if err := WriteComposition(b, comp); err != nil {
+		t.Fatalf("WriteComposition(...): %v", err)
+	}
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("WriteComposition(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestWriteBatchSummary(t *testing.T) {
+	results := []render.BatchResult{
+		{Name: "xr-a"},
+		{Name: "xr-b", Error: errors.New("boom")},
+		{Name: "xr-c"},
+	}
+
+	buf := &bytes.Buffer{}
+	WriteBatchSummary(buf, results)
+
+	want := "Rendered 3 XRs: 2 succeeded, 1 failed.\n  xr-b: boom\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("WriteBatchSummary(...): -want, +got:\n%s", diff)
+	}
+}
+
+type errWriter struct{ err error }
+
+func (w errWriter) Write(_ []byte) (int, error) { return 0, w.err }
+
+func TestWriteComparison(t *testing.T) {
+	c := render.Comparison{
+		CompositeResource: render.ResourceComparison{Name: "cool-xr", APIVersion: "example.org/v1", Kind: "XR", Change: render.ChangeUnchanged},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteComparison(buf, c, false); err != nil {
+		t.Fatalf("WriteComparison(...): %v", err)
+	}
+	if diff := cmp.Diff("No changes.\n", buf.String()); diff != "" {
+		t.Errorf("WriteComparison(...): -want, +got:\n%s", diff)
+	}
+
+	boom := errors.New("boom")
+	if err := WriteComparison(errWriter{err: boom}, c, true); !errors.Is(err, boom) {
+		t.Errorf("WriteComparison(...): want error %v, got %v", boom, err)
+	}
+}
@@ -0,0 +1,87 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane-contrib/xrender/pkg/timing"
+)
+
+// Formats in which timings can be written.
+const (
+	TimingsFormatTable = "table"
+	TimingsFormatJSON  = "json"
+)
+
+// A jsonTiming is a timing.Record, as written by WriteTimings.
+type jsonTiming struct {
+	Phase           timing.Phase `json:"phase"`
+	Function        string       `json:"function,omitempty"`
+	Step            string       `json:"step,omitempty"`
+	DurationSeconds float64      `json:"durationSeconds"`
+	RequestBytes    int          `json:"requestBytes,omitempty"`
+	ResponseBytes   int          `json:"responseBytes,omitempty"`
+}
+
+// WriteTimings writes the supplied timings to the supplied writer, either as a
+// table or as a JSON array.
+func WriteTimings(w io.Writer, recs []timing.Record, format string) error {
+	switch format {
+	case TimingsFormatTable:
+		return writeTimingsTable(w, recs)
+	case TimingsFormatJSON:
+		return writeTimingsJSON(w, recs)
+	default:
+		return errors.Errorf("unknown timings format %q", format)
+	}
+}
+
+func writeTimingsTable(w io.Writer, recs []timing.Record) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tFUNCTION\tSTEP\tDURATION\tREQUEST BYTES\tRESPONSE BYTES")
+	for _, r := range recs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Phase, orDash(r.Function), orDash(r.Step), r.Duration.Round(time.Microsecond), bytesOrDash(r.Phase, r.RequestBytes), bytesOrDash(r.Phase, r.ResponseBytes))
+	}
+	return tw.Flush()
+}
+
+func writeTimingsJSON(w io.Writer, recs []timing.Record) error {
+	out := make([]jsonTiming, len(recs))
+	for i, r := range recs {
+		out[i] = jsonTiming{
+			Phase:           r.Phase,
+			Function:        r.Function,
+			Step:            r.Step,
+			DurationSeconds: r.Duration.Seconds(),
+			RequestBytes:    r.RequestBytes,
+			ResponseBytes:   r.ResponseBytes,
+		}
+	}
+	j, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal timings to JSON")
+	}
+	_, err = fmt.Fprintln(w, string(j))
+	return err
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Only running a pipeline step sends a request and receives a response.
+func bytesOrDash(p timing.Phase, b int) string {
+	if p != timing.PhaseRun {
+		return "-"
+	}
+	return strconv.Itoa(b)
+}
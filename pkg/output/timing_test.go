@@ -0,0 +1,89 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/crossplane-contrib/xrender/pkg/timing"
+)
+
+func TestWriteTimings(t *testing.T) {
+	recs := []timing.Record{
+		{Phase: timing.PhasePull, Function: "function-a", Duration: 2 * time.Second},
+		{Phase: timing.PhaseRun, Function: "function-a", Step: "one", Duration: 1500 * time.Microsecond, RequestBytes: 120, ResponseBytes: 340},
+		{Phase: timing.PhaseEncode, Duration: time.Millisecond},
+	}
+
+	type want struct {
+		out string
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		format string
+		want   want
+	}{
+		"Table": {
+			reason: "Timings should be written as a table, with a dash for anything a phase doesn't concern.",
+			format: TimingsFormatTable,
+			want: want{
+				out: "" +
+					"PHASE    FUNCTION     STEP   DURATION   REQUEST BYTES   RESPONSE BYTES\n" +
+					"Pull     function-a   -      2s         -               -\n" +
+					"Run      function-a   one    1.5ms      120             340\n" +
+					"Encode   -            -      1ms        -               -\n",
+			},
+		},
+		"JSON": {
+			reason: "Timings should be written as a JSON array, with durations in seconds.",
+			format: TimingsFormatJSON,
+			want: want{
+				out: `[
+  {
+    "phase": "Pull",
+    "function": "function-a",
+    "durationSeconds": 2
+  },
+  {
+    "phase": "Run",
+    "function": "function-a",
+    "step": "one",
+    "durationSeconds": 0.0015,
+    "requestBytes": 120,
+    "responseBytes": 340
+  },
+  {
+    "phase": "Encode",
+    "durationSeconds": 0.001
+  }
+]
+`,
+			},
+		},
+		"UnknownFormat": {
+			reason: "We should return an error if asked to write timings in an unknown format.",
+			format: "xml",
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := WriteTimings(buf, recs, tc.format)
+
+			if diff := cmp.Diff(tc.want.out, buf.String()); diff != "" {
+				t.Errorf("%s\nWriteTimings(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nWriteTimings(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// Files written for each pipeline step by WriteTrace.
+const (
+	TraceFileRequest  = "request.yaml"
+	TraceFileResponse = "response.yaml"
+	TraceFileDiff     = "desired.diff"
+)
+
+// WriteTrace writes a trace of the supplied pipeline steps to the supplied
+// directory. Each step gets a subdirectory named for its position in the
+// Composition's pipeline and its name (e.g. 01-patch-and-transform)
+// containing:
+//
+//   - request.yaml - The RunFunctionRequest sent to the step's Function.
+//   - response.yaml - The RunFunctionResponse it returned.
+//   - desired.diff - How the step changed the desired state it was sent.
+//
+// Only request.yaml is written for a step that failed, and so returned no
+// response.
+func WriteTrace(dir string, steps []render.Step) error {
+	for _, s := range steps {
+		sdir := filepath.Join(dir, fmt.Sprintf("%02d-%s", s.Index+1, s.Step))
+		if err := os.MkdirAll(sdir, 0o750); err != nil {
+			return errors.Wrapf(err, "cannot create trace directory for pipeline step %q", s.Step)
+		}
+
+		req, err := ProtoToYAML(s.Request)
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal RunFunctionRequest for pipeline step %q", s.Step)
+		}
+		if err := os.WriteFile(filepath.Join(sdir, TraceFileRequest), req, 0o600); err != nil {
+			return errors.Wrapf(err, "cannot write RunFunctionRequest for pipeline step %q", s.Step)
+		}
+
+		if s.Response == nil {
+			continue
+		}
+
+		rsp, err := ProtoToYAML(s.Response)
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal RunFunctionResponse for pipeline step %q", s.Step)
+		}
+		if err := os.WriteFile(filepath.Join(sdir, TraceFileResponse), rsp, 0o600); err != nil {
+			return errors.Wrapf(err, "cannot write RunFunctionResponse for pipeline step %q", s.Step)
+		}
+
+		diff := cmp.Diff(s.Request.GetDesired(), s.Response.GetDesired(), protocmp.Transform())
+		if diff == "" {
+			diff = "No changes to desired state.\n"
+		} else {
+			diff = "Desired state: -sent to step, +returned by step\n" + diff
+		}
+		if err := os.WriteFile(filepath.Join(sdir, TraceFileDiff), []byte(diff), 0o600); err != nil {
+			return errors.Wrapf(err, "cannot write desired state diff for pipeline step %q", s.Step)
+		}
+	}
+	return nil
+}
+
+// ProtoToYAML marshals the supplied protocol buffer message to YAML.
+func ProtoToYAML(m proto.Message) ([]byte, error) {
+	j, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal protobuf message to JSON")
+	}
+	y, err := yaml.JSONToYAML(j)
+	return y, errors.Wrap(err, "cannot convert JSON to YAML")
+}
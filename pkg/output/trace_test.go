@@ -0,0 +1,84 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+func TestWriteTrace(t *testing.T) {
+	dir := t.TempDir()
+
+	steps := []render.Step{
+		{
+			Step:    "add-bucket",
+			Request: &fnv1beta1.RunFunctionRequest{Desired: &fnv1beta1.State{}},
+			Response: &fnv1beta1.RunFunctionResponse{Desired: &fnv1beta1.State{
+				Resources: map[string]*fnv1beta1.Resource{
+					"bucket": {Resource: xtest.MustStructJSON(`{"apiVersion":"s3.aws.upbound.io/v1beta1","kind":"Bucket"}`)},
+				},
+			}},
+		},
+		{
+			Step:     "no-op",
+			Index:    1,
+			Request:  &fnv1beta1.RunFunctionRequest{},
+			Response: &fnv1beta1.RunFunctionResponse{},
+		},
+		{
+			// A step that failed, after steps that weren't run.
+			Step:    "broken",
+			Index:   4,
+			Request: &fnv1beta1.RunFunctionRequest{},
+		},
+	}
+
+	if err := WriteTrace(dir, steps); err != nil {
+		t.Fatalf("WriteTrace(...): %v", err)
+	}
+
+	for _, f := range []string{TraceFileRequest, TraceFileResponse, TraceFileDiff} {
+		for _, s := range []string{"01-add-bucket", "02-no-op"} {
+			if _, err := os.Stat(filepath.Join(dir, s, f)); err != nil {
+				t.Errorf("WriteTrace(...): want file %s/%s: %v", s, f, err)
+			}
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "05-broken", TraceFileRequest)); err != nil {
+		t.Errorf("WriteTrace(...): want file 05-broken/%s: %v", TraceFileRequest, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "05-broken", TraceFileResponse)); !os.IsNotExist(err) {
+		t.Errorf("WriteTrace(...): want no %s for a failed step, got err %v", TraceFileResponse, err)
+	}
+
+	rsp, err := os.ReadFile(filepath.Join(dir, "01-add-bucket", TraceFileResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rsp), "kind: Bucket") {
+		t.Errorf("WriteTrace(...): want response.yaml to contain desired Bucket, got:\n%s", rsp)
+	}
+
+	diff, err := os.ReadFile(filepath.Join(dir, "01-add-bucket", TraceFileDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(diff), "Bucket") {
+		t.Errorf("WriteTrace(...): want desired.diff to show the added Bucket, got:\n%s", diff)
+	}
+
+	diff, err = os.ReadFile(filepath.Join(dir, "02-no-op", TraceFileDiff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(diff), "Bucket") {
+		t.Errorf("WriteTrace(...): want desired.diff to show no changes, got:\n%s", diff)
+	}
+}
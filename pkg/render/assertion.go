@@ -0,0 +1,212 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+)
+
+// Assertions API group, version and kind.
+const (
+	AssertionsAPIVersion = "xrender.crossplane.io/v1beta1"
+	AssertionsKind       = "Assertions"
+)
+
+// Assertions are CEL expressions that rendered output must satisfy, for example
+// to enforce organisational guardrails.
+type Assertions struct {
+	metav1.TypeMeta `json:",inline"`
+
+	Assertions []Assertion `json:"assertions"`
+}
+
+// An Assertion is a CEL expression that must evaluate to true for rendered
+// output. The expression may use the variables xr (the rendered XR), composed
+// (a list of rendered composed resources) and results (a list of Results).
+type Assertion struct {
+	// Name of the assertion.
+	Name string `json:"name"`
+
+	// Expression that must evaluate to true, e.g.
+	// composed.all(r, r.kind != 'Bucket' || r.spec.deletionPolicy == 'Orphan').
+	Expression string `json:"expression"`
+
+	// Severity of the Result returned if the assertion is violated. Defaults
+	// to Fatal.
+	Severity AssertionSeverity `json:"severity,omitempty"`
+
+	// Message of the Result returned if the assertion is violated. Defaults
+	// to a message that includes the expression.
+	Message string `json:"message,omitempty"`
+}
+
+// An AssertionSeverity is the severity of a violated assertion.
+type AssertionSeverity string
+
+// Assertion severities.
+const (
+	AssertionSeverityFatal   AssertionSeverity = "Fatal"
+	AssertionSeverityWarning AssertionSeverity = "Warning"
+	AssertionSeverityNormal  AssertionSeverity = "Normal"
+)
+
+// Validate the Assertions.
+func (a *Assertions) Validate() error {
+	if a.APIVersion != AssertionsAPIVersion || a.Kind != AssertionsKind {
+		return errors.Errorf("must be apiVersion: %s, kind: %s", AssertionsAPIVersion, AssertionsKind)
+	}
+	return ValidateAssertions(a.Assertions)
+}
+
+// ValidateAssertions returns an error if any of the supplied assertions are
+// invalid, including if their expressions don't compile to a boolean.
+func ValidateAssertions(as []Assertion) error {
+	env, err := newAssertionEnv()
+	if err != nil {
+		return err
+	}
+	for i, a := range as {
+		if a.Name == "" {
+			return errors.Errorf("assertions[%d]: must specify a name", i)
+		}
+		switch a.Severity {
+		case "", AssertionSeverityFatal, AssertionSeverityWarning, AssertionSeverityNormal:
+		default:
+			return errors.Errorf("assertions[%d]: severity must be one of %s, %s or %s", i, AssertionSeverityFatal, AssertionSeverityWarning, AssertionSeverityNormal)
+		}
+		if _, err := compileAssertion(env, a); err != nil {
+			return errors.Wrapf(err, "assertions[%d]", i)
+		}
+	}
+	return nil
+}
+
+func newAssertionEnv() (*cel.Env, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("xr", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("composed", cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+		cel.Variable("results", cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+	)
+	return env, errors.Wrap(err, "cannot create CEL environment")
+}
+
+func compileAssertion(env *cel.Env, a Assertion) (cel.Program, error) {
+	ast, iss := env.Compile(a.Expression)
+	if iss.Err() != nil {
+		return nil, errors.Wrapf(iss.Err(), "cannot compile expression of assertion %q", a.Name)
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, errors.Errorf("expression of assertion %q must return a bool, not %s", a.Name, ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	return prg, errors.Wrapf(err, "cannot create program for assertion %q", a.Name)
+}
+
+// CheckAssertions evaluates the supplied assertions against the supplied
+// rendered output. It returns a Result for each violated assertion. An
+// assertion that can't be evaluated, for example because it references a field
+// that doesn't exist, is violated.
+func CheckAssertions(as []Assertion, out Outputs) ([]unstructured.Unstructured, error) {
+	if len(as) == 0 {
+		return nil, nil
+	}
+	env, err := newAssertionEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]any{
+		"xr":       map[string]any{},
+		"composed": objects(out.ComposedResources, func(cd composed.Unstructured) map[string]any { return cd.Object }),
+		"results":  objects(out.Results, func(r unstructured.Unstructured) map[string]any { return r.Object }),
+	}
+	if out.CompositeResource != nil {
+		vars["xr"] = out.CompositeResource.Object
+	}
+
+	violations := make([]unstructured.Unstructured, 0)
+	for _, a := range as {
+		prg, err := compileAssertion(env, a)
+		if err != nil {
+			return nil, err
+		}
+		msg := a.Message
+		if msg == "" {
+			msg = fmt.Sprintf("assertion %q is violated: %s", a.Name, a.Expression)
+		}
+		val, _, err := prg.Eval(vars)
+		if err != nil {
+			violations = append(violations, assertionResult(a, fmt.Sprintf("%s (cannot evaluate: %s)", msg, err)))
+			continue
+		}
+		if ok, _ := val.Value().(bool); !ok {
+			violations = append(violations, assertionResult(a, msg))
+		}
+	}
+	return violations, nil
+}
+
+func objects[T any](in []T, obj func(T) map[string]any) []map[string]any {
+	out := make([]map[string]any, len(in))
+	for i := range in {
+		out[i] = obj(in[i])
+	}
+	return out
+}
+
+func assertionResult(a Assertion, msg string) unstructured.Unstructured {
+	sev := a.Severity
+	if sev == "" {
+		sev = AssertionSeverityFatal
+	}
+	return unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "xrender.crossplane.io/v1beta1",
+		"kind":       "Result",
+		"assertion":  a.Name,
+		"severity":   "SEVERITY_" + strings.ToUpper(string(sev)),
+		"message":    msg,
+	}}
+}
+
+// WithAssertionResults returns the supplied outputs, with a Result for each of
+// the supplied assertions that they violate.
+func WithAssertionResults(out Outputs, as []Assertion) (Outputs, error) {
+	violations, err := CheckAssertions(as, out)
+	if err != nil {
+		return out, errors.Wrap(err, "cannot check assertions")
+	}
+	out.Results = append(out.Results, violations...)
+	return out, nil
+}
+
+// AssertionResults returns the supplied Results that are violated assertions.
+func AssertionResults(results []unstructured.Unstructured) []unstructured.Unstructured {
+	out := make([]unstructured.Unstructured, 0)
+	for _, r := range results {
+		if _, ok := r.Object["assertion"]; ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// ViolationsError returns an error describing the supplied violations of Fatal
+// assertions, if there are any.
+func ViolationsError(violations []unstructured.Unstructured) error {
+	msgs := make([]string, 0)
+	for _, v := range violations {
+		if v.Object["severity"] == "SEVERITY_FATAL" {
+			msgs = append(msgs, fmt.Sprintf("%v", v.Object["message"]))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.Errorf("fatal assertions violated: %s", strings.Join(msgs, "; "))
+}
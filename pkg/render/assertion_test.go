@@ -0,0 +1,157 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+)
+
+func TestCheckAssertions(t *testing.T) {
+	cd := func(j string) composed.Unstructured {
+		return composed.Unstructured{Unstructured: unstructured.Unstructured{Object: xtest.MustLoadJSON(j)}}
+	}
+	out := Outputs{
+		CompositeResource: &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: xtest.MustLoadJSON(`{
+			"apiVersion": "example.org/v1",
+			"kind": "XBucket",
+			"metadata": {"name": "cool-xr"},
+			"spec": {"size": 2}
+		}`)}},
+		ComposedResources: []composed.Unstructured{
+			cd(`{"apiVersion":"example.org/v1","kind":"Bucket","metadata":{"labels":{"team":"a"}},"spec":{"deletionPolicy":"Orphan"}}`),
+			cd(`{"apiVersion":"example.org/v1","kind":"Bucket","spec":{"deletionPolicy":"Delete"}}`),
+		},
+		Results: []unstructured.Unstructured{{Object: map[string]any{"step": "a", "severity": "SEVERITY_WARNING", "message": "cool warning"}}},
+	}
+
+	type want struct {
+		violations []unstructured.Unstructured
+		err        error
+	}
+	cases := map[string]struct {
+		reason string
+		as     []Assertion
+		want   want
+	}{
+		"Satisfied": {
+			reason: "Assertions that evaluate to true shouldn't return any violations.",
+			as: []Assertion{
+				{Name: "xr-size", Expression: "xr.spec.size == 2"},
+				{Name: "two-buckets", Expression: "composed.filter(r, r.kind == 'Bucket').size() == 2"},
+				{Name: "warned", Expression: "results.exists(r, r.severity == 'SEVERITY_WARNING')"},
+			},
+			want: want{
+				violations: []unstructured.Unstructured{},
+			},
+		},
+		"Violated": {
+			reason: "An assertion that evaluates to false should return a violation with its severity and message.",
+			as: []Assertion{
+				{Name: "orphan", Expression: "composed.all(r, r.kind != 'Bucket' || r.spec.deletionPolicy == 'Orphan')", Severity: AssertionSeverityWarning, Message: "Buckets must be orphaned"},
+			},
+			want: want{
+				violations: []unstructured.Unstructured{{Object: map[string]any{
+					"apiVersion": "xrender.crossplane.io/v1beta1",
+					"kind":       "Result",
+					"assertion":  "orphan",
+					"severity":   "SEVERITY_WARNING",
+					"message":    "Buckets must be orphaned",
+				}}},
+			},
+		},
+		"CannotEvaluate": {
+			reason: "An assertion that references a field that doesn't exist should be violated.",
+			as: []Assertion{
+				{Name: "team", Expression: "composed.all(r, r.metadata.labels.team != '')"},
+			},
+			want: want{
+				violations: []unstructured.Unstructured{{Object: map[string]any{
+					"apiVersion": "xrender.crossplane.io/v1beta1",
+					"kind":       "Result",
+					"assertion":  "team",
+					"severity":   "SEVERITY_FATAL",
+					"message":    `assertion "team" is violated: composed.all(r, r.metadata.labels.team != '') (cannot evaluate: no such key: metadata)`,
+				}}},
+			},
+		},
+		"NotBool": {
+			reason: "We should return an error if an assertion doesn't return a bool.",
+			as: []Assertion{
+				{Name: "size", Expression: "xr.spec.size + 1"},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			violations, err := CheckAssertions(tc.as, out)
+
+			if diff := cmp.Diff(tc.want.violations, violations); diff != "" {
+				t.Errorf("%s\nCheckAssertions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nCheckAssertions(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateAssertions(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		as     []Assertion
+		want   error
+	}{
+		"Valid": {
+			reason: "A named assertion with a valid severity and boolean expression should be valid.",
+			as:     []Assertion{{Name: "ok", Expression: "size(composed) > 0", Severity: AssertionSeverityNormal}},
+		},
+		"NoName": {
+			reason: "An assertion must have a name.",
+			as:     []Assertion{{Expression: "true"}},
+			want:   cmpopts.AnyError,
+		},
+		"InvalidSeverity": {
+			reason: "An assertion's severity must be Fatal, Warning or Normal.",
+			as:     []Assertion{{Name: "bad", Expression: "true", Severity: "Critical"}},
+			want:   cmpopts.AnyError,
+		},
+		"InvalidExpression": {
+			reason: "An assertion's expression must compile.",
+			as:     []Assertion{{Name: "bad", Expression: "composed.all(r,"}},
+			want:   cmpopts.AnyError,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateAssertions(tc.as)
+			if diff := cmp.Diff(tc.want, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nValidateAssertions(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestViolationsError(t *testing.T) {
+	result := func(sev string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]any{"assertion": "a", "severity": sev, "message": "boom"}}
+	}
+
+	if err := ViolationsError([]unstructured.Unstructured{result("SEVERITY_WARNING")}); err != nil {
+		t.Errorf("ViolationsError(warning): want nil, got %s", err)
+	}
+	if err := ViolationsError([]unstructured.Unstructured{result("SEVERITY_WARNING"), result("SEVERITY_FATAL")}); err == nil {
+		t.Errorf("ViolationsError(fatal): want error, got nil")
+	}
+}
@@ -0,0 +1,150 @@
+package render
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+// DefaultBatchConcurrency is the number of XRs in a batch that are rendered at
+// once if no concurrency is specified.
+const DefaultBatchConcurrency = 8
+
+// A BatchResult is the result of rendering one XR in a batch.
+type BatchResult struct {
+	// Name of the XR.
+	Name string
+
+	Outputs Outputs
+	Error   error
+}
+
+// RenderBatch renders each of the supplied inputs, rendering up to the supplied
+// number of inputs at once. The Functions referenced by every input's pipeline
+// are started once, and shared by all renders. It returns a result for each
+// input, in order. It only returns an error if it can't start the Functions.
+// Resources mode Compositions are rendered using function-patch-and-transform -
+// see AsPipeline.
+func RenderBatch(ctx context.Context, ins []Inputs, concurrency int, o ...Option) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ins))
+	for i := range ins {
+		results[i].Name = ins[i].CompositeResource.GetName()
+	}
+
+	// Inputs with a pipeline we can't run fail individually.
+	ins = append([]Inputs{}, ins...)
+	fns := make([]pkgv1beta1.Function, 0)
+	for i := range ins {
+		in, err := AsPipeline(ins[i])
+		if err != nil {
+			results[i].Error = err
+			continue
+		}
+		ins[i] = in
+		rfns, err := pipelineFunctions(ins[i])
+		if err != nil {
+			results[i].Error = err
+			continue
+		}
+		fns = append(fns, rfns...)
+	}
+
+	rf, err := startFunctions(ctx, uniqueFunctions(fns), o...)
+	if err != nil {
+		return nil, err
+	}
+	defer rf.Cleanup()
+
+	if concurrency < 1 {
+		concurrency = DefaultBatchConcurrency
+	}
+	g := &errgroup.Group{}
+	g.SetLimit(concurrency)
+	for i := range ins {
+		if results[i].Error != nil {
+			continue
+		}
+		i := i // Pin range variable so we can use it in our goroutine.
+		g.Go(func() error {
+			results[i].Outputs, results[i].Error = RunPipeline(ctx, rf, ins[i])
+			return nil
+		})
+	}
+	_ = g.Wait() // Our goroutines never return an error.
+
+	return results, nil
+}
+
+// StartSharedFunctions starts the Functions referenced by the pipelines of the
+// supplied inputs once, so that renders of those inputs can share them using
+// WithStepRunner. It also returns whether each input can use the shared
+// Functions. An input can't if its pipeline can't be run, or if it references
+// a Function with the same name as a different Function referenced by an
+// earlier input. Render such inputs without WithStepRunner. Resources mode
+// Compositions use function-patch-and-transform - see AsPipeline.
+func StartSharedFunctions(ctx context.Context, ins []Inputs, o ...Option) (*functions.Running, []bool, error) {
+	fns, shared := sharedFunctions(ins)
+	rf, err := startFunctions(ctx, fns, o...)
+	return rf, shared, err
+}
+
+// sharedFunctions returns the Functions referenced by the pipelines of the
+// supplied inputs, and whether each input can use them.
+func sharedFunctions(ins []Inputs) ([]pkgv1beta1.Function, []bool) {
+	byName := map[string]pkgv1beta1.Function{}
+	fns := make([]pkgv1beta1.Function, 0)
+	shared := make([]bool, len(ins))
+	for i := range ins {
+		in, err := AsPipeline(ins[i])
+		if err != nil {
+			continue
+		}
+		rfns, err := pipelineFunctions(in)
+		if err != nil {
+			continue
+		}
+		shared[i] = !conflicts(byName, rfns)
+		if !shared[i] {
+			continue
+		}
+		for _, fn := range rfns {
+			if _, ok := byName[fn.GetName()]; ok {
+				continue
+			}
+			byName[fn.GetName()] = fn
+			fns = append(fns, fn)
+		}
+	}
+	return fns, shared
+}
+
+// conflicts returns true if any of the supplied Functions has the same name as
+// a different Function in the supplied map.
+func conflicts(byName map[string]pkgv1beta1.Function, fns []pkgv1beta1.Function) bool {
+	for _, fn := range fns {
+		if existing, ok := byName[fn.GetName()]; ok && !equality.Semantic.DeepEqual(existing, fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueFunctions returns the supplied Functions, omitting any Function with
+// the same name as one before it.
+func uniqueFunctions(fns []pkgv1beta1.Function) []pkgv1beta1.Function {
+	seen := map[string]bool{}
+	out := make([]pkgv1beta1.Function, 0, len(fns))
+	for _, fn := range fns {
+		if seen[fn.GetName()] {
+			continue
+		}
+		seen[fn.GetName()] = true
+		out = append(out, fn)
+	}
+	return out
+}
@@ -0,0 +1,179 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+func TestRenderBatch(t *testing.T) {
+	pipeline := apiextensionsv1.CompositionModePipeline
+
+	lis := xtest.NewFunction(t, &fnv1beta1.RunFunctionResponse{
+		Desired: &fnv1beta1.State{
+			Composite: &fnv1beta1.Resource{Resource: xtest.MustStructJSON(`{"status":{"widgets":9001}}`)},
+		},
+	})
+	defer lis.Close()
+
+	fns := []pkgv1beta1.Function{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "function-test",
+			Annotations: map[string]string{
+				functions.AnnotationKeyRuntime:                  string(functions.AnnotationValueRuntimeDevelopment),
+				functions.AnnotationKeyRuntimeDevelopmentTarget: lis.Addr().String(),
+			},
+		},
+	}}
+
+	in := func(name, fn string) Inputs {
+		return Inputs{
+			CompositeResource: &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "nop.example.org/v1alpha1",
+				"kind":       "XNopResource",
+				"metadata":   map[string]any{"name": name},
+			}}},
+			Composition: &apiextensionsv1.Composition{
+				Spec: apiextensionsv1.CompositionSpec{
+					Mode:     &pipeline,
+					Pipeline: []apiextensionsv1.PipelineStep{{Step: "test", FunctionRef: apiextensionsv1.FunctionReference{Name: fn}}},
+				},
+			},
+			Functions: fns,
+		}
+	}
+
+	results, err := RenderBatch(context.Background(), []Inputs{
+		in("xr-a", "function-test"),
+		in("xr-b", "function-missing"),
+		in("xr-c", "function-test"),
+	}, 2)
+	if err != nil {
+		t.Fatalf("RenderBatch(...): %s", err)
+	}
+
+	type result struct {
+		Name    string
+		Widgets any
+		Failed  bool
+	}
+	got := make([]result, 0, len(results))
+	for _, r := range results {
+		res := result{Name: r.Name, Failed: r.Error != nil}
+		if r.Outputs.CompositeResource != nil {
+			res.Widgets = r.Outputs.CompositeResource.Object["status"].(map[string]any)["widgets"]
+		}
+		got = append(got, res)
+	}
+
+	want := []result{
+		{Name: "xr-a", Widgets: float64(9001)},
+		{Name: "xr-b", Failed: true},
+		{Name: "xr-c", Widgets: float64(9001)},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RenderBatch(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestSharedFunctions(t *testing.T) {
+	pipeline := apiextensionsv1.CompositionModePipeline
+
+	fn := func(name, pkg string) pkgv1beta1.Function {
+		return pkgv1beta1.Function{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       pkgv1beta1.FunctionSpec{PackageSpec: pkgv1.PackageSpec{Package: pkg}},
+		}
+	}
+	in := func(ref string, fns ...pkgv1beta1.Function) Inputs {
+		return Inputs{
+			Composition: &apiextensionsv1.Composition{
+				Spec: apiextensionsv1.CompositionSpec{
+					Mode:     &pipeline,
+					Pipeline: []apiextensionsv1.PipelineStep{{Step: "test", FunctionRef: apiextensionsv1.FunctionReference{Name: ref}}},
+				},
+			},
+			Functions: fns,
+		}
+	}
+
+	type want struct {
+		fns    []pkgv1beta1.Function
+		shared []bool
+	}
+	cases := map[string]struct {
+		reason string
+		ins    []Inputs
+		want   want
+	}{
+		"SameFunctions": {
+			reason: "Inputs that reference the same Function should share it.",
+			ins: []Inputs{
+				in("function-a", fn("function-a", "a:v1")),
+				in("function-a", fn("function-a", "a:v1"), fn("function-b", "b:v1")),
+			},
+			want: want{
+				fns:    []pkgv1beta1.Function{fn("function-a", "a:v1")},
+				shared: []bool{true, true},
+			},
+		},
+		"DifferentFunctions": {
+			reason: "Inputs that reference different Functions should share the union of them.",
+			ins: []Inputs{
+				in("function-a", fn("function-a", "a:v1")),
+				in("function-b", fn("function-b", "b:v1")),
+			},
+			want: want{
+				fns:    []pkgv1beta1.Function{fn("function-a", "a:v1"), fn("function-b", "b:v1")},
+				shared: []bool{true, true},
+			},
+		},
+		"NameClash": {
+			reason: "An input that references a different Function with the same name as an earlier input's shouldn't share Functions.",
+			ins: []Inputs{
+				in("function-a", fn("function-a", "a:v1")),
+				in("function-a", fn("function-a", "a:v2")),
+			},
+			want: want{
+				fns:    []pkgv1beta1.Function{fn("function-a", "a:v1")},
+				shared: []bool{true, false},
+			},
+		},
+		"MissingFunction": {
+			reason: "An input whose pipeline references a missing Function shouldn't share Functions.",
+			ins: []Inputs{
+				in("function-missing", fn("function-a", "a:v1")),
+			},
+			want: want{
+				fns:    []pkgv1beta1.Function{},
+				shared: []bool{false},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fns, shared := sharedFunctions(tc.ins)
+
+			if diff := cmp.Diff(tc.want.fns, fns); diff != "" {
+				t.Errorf("%s\nsharedFunctions(...): -want Functions, +got Functions:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.shared, shared); diff != "" {
+				t.Errorf("%s\nsharedFunctions(...): -want shared, +got shared:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package render
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/timing"
+)
+
+// DefaultBenchmarkRenders is the number of renders a benchmark runs if no
+// number is specified.
+const DefaultBenchmarkRenders = 100
+
+// A Distribution summarises a set of latencies or sizes.
+type Distribution[T time.Duration | int] struct {
+	Count int
+	Min   T
+	Mean  T
+	P50   T
+	P95   T
+	P99   T
+	Max   T
+}
+
+// NewDistribution summarises the supplied values. Percentiles are calculated
+// using the nearest-rank method.
+func NewDistribution[T time.Duration | int](vs []T) Distribution[T] {
+	if len(vs) == 0 {
+		return Distribution[T]{}
+	}
+
+	sorted := append([]T{}, vs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum T
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return Distribution[T]{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Mean:  sum / T(len(sorted)),
+		P50:   percentile(sorted, 50),
+		P95:   percentile(sorted, 95),
+		P99:   percentile(sorted, 99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the pth percentile of the supplied sorted values, using
+// the nearest-rank method.
+func percentile[T time.Duration | int](sorted []T, p float64) T {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// A StepBenchmark is the result of benchmarking a pipeline step.
+type StepBenchmark struct {
+	// Step is the name of the pipeline step.
+	Step string
+
+	// Function the step ran.
+	Function string
+
+	// Latency of each time the step was run.
+	Latency Distribution[time.Duration]
+
+	// ResponseBytes is the size of each response the step returned.
+	ResponseBytes Distribution[int]
+}
+
+// A Benchmark is the result of benchmarking rendering.
+type Benchmark struct {
+	// Renders is the latency of each full render.
+	Renders Distribution[time.Duration]
+
+	// Steps are the results of benchmarking each pipeline step, in the order
+	// they were first run.
+	Steps []StepBenchmark
+}
+
+// RenderBenchmark renders the supplied inputs the supplied number of times,
+// rendering up to the supplied number of inputs at once. Renders cycle through
+// the supplied inputs. The Functions referenced by every input's pipeline are
+// started once, and shared by all renders. Each render runs the pipeline the
+// same way Render does. It returns an error if any render fails.
+func RenderBenchmark(ctx context.Context, ins []Inputs, renders, concurrency int, o ...Option) (Benchmark, error) {
+	if len(ins) == 0 {
+		return Benchmark{}, errors.New("no inputs to render")
+	}
+	if renders < 1 {
+		renders = DefaultBenchmarkRenders
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ins = append([]Inputs{}, ins...)
+	fns := make([]pkgv1beta1.Function, 0)
+	for i := range ins {
+		in, err := AsPipeline(ins[i])
+		if err != nil {
+			return Benchmark{}, errors.Wrapf(err, "cannot render composite resource %q", ins[i].CompositeResource.GetName())
+		}
+		ins[i] = in
+		rfns, err := pipelineFunctions(ins[i])
+		if err != nil {
+			return Benchmark{}, errors.Wrapf(err, "cannot render composite resource %q", ins[i].CompositeResource.GetName())
+		}
+		fns = append(fns, rfns...)
+	}
+
+	// Record how long each pipeline step takes. This overrides any timings
+	// Recorder supplied using WithFunctionOptions.
+	tr := timing.NewRecorder()
+	bo := append(append([]Option{}, o...), WithFunctionOptions(functions.WithTimings(tr)))
+	rf, err := startFunctions(ctx, uniqueFunctions(fns), bo...)
+	if err != nil {
+		return Benchmark{}, err
+	}
+	defer rf.Cleanup()
+
+	mx := &sync.Mutex{}
+	latencies := make([]time.Duration, 0, renders)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	for i := 0; i < renders; i++ {
+		in := ins[i%len(ins)]
+		g.Go(func() error {
+			start := time.Now()
+			if _, err := RunPipeline(gctx, rf, in); err != nil {
+				return errors.Wrapf(err, "cannot render composite resource %q", in.CompositeResource.GetName())
+			}
+			d := time.Since(start)
+
+			mx.Lock()
+			defer mx.Unlock()
+			latencies = append(latencies, d)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return Benchmark{}, err
+	}
+
+	return Benchmark{Renders: NewDistribution(latencies), Steps: stepBenchmarks(tr.Records())}, nil
+}
+
+// stepBenchmarks summarises the supplied timings of each pipeline step.
+func stepBenchmarks(recs []timing.Record) []StepBenchmark {
+	type key struct{ step, fn string }
+
+	order := make([]key, 0)
+	latencies := map[key][]time.Duration{}
+	sizes := map[key][]int{}
+	for _, r := range recs {
+		if r.Phase != timing.PhaseRun {
+			continue
+		}
+		k := key{step: r.Step, fn: r.Function}
+		if _, ok := latencies[k]; !ok {
+			order = append(order, k)
+		}
+		latencies[k] = append(latencies[k], r.Duration)
+		sizes[k] = append(sizes[k], r.ResponseBytes)
+	}
+
+	out := make([]StepBenchmark, 0, len(order))
+	for _, k := range order {
+		out = append(out, StepBenchmark{
+			Step:          k.step,
+			Function:      k.fn,
+			Latency:       NewDistribution(latencies[k]),
+			ResponseBytes: NewDistribution(sizes[k]),
+		})
+	}
+	return out
+}
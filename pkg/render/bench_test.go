@@ -0,0 +1,115 @@
+package render
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+func TestNewDistribution(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		vs     []int
+		want   Distribution[int]
+	}{
+		"Empty": {
+			reason: "The distribution of no values should be the zero value.",
+			want:   Distribution[int]{},
+		},
+		"OneValue": {
+			reason: "Every percentile of a single value should be that value.",
+			vs:     []int{42},
+			want:   Distribution[int]{Count: 1, Min: 42, Mean: 42, P50: 42, P95: 42, P99: 42, Max: 42},
+		},
+		"Unsorted": {
+			reason: "Values should be sorted before percentiles are calculated using the nearest-rank method.",
+			vs:     []int{10, 1, 9, 2, 8, 3, 7, 4, 6, 5},
+			want:   Distribution[int]{Count: 10, Min: 1, Mean: 5, P50: 5, P95: 10, P99: 10, Max: 10},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := NewDistribution(tc.vs)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("%s\nNewDistribution(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRenderBenchmark(t *testing.T) {
+	pipeline := apiextensionsv1.CompositionModePipeline
+
+	rsp := &fnv1beta1.RunFunctionResponse{
+		Desired: &fnv1beta1.State{
+			Composite: &fnv1beta1.Resource{Resource: xtest.MustStructJSON(`{"status":{"widgets":9001}}`)},
+		},
+	}
+	lis := xtest.NewFunction(t, rsp)
+	defer lis.Close()
+
+	in := func(name string) Inputs {
+		return Inputs{
+			CompositeResource: &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "nop.example.org/v1alpha1",
+				"kind":       "XNopResource",
+				"metadata":   map[string]any{"name": name},
+			}}},
+			Composition: &apiextensionsv1.Composition{
+				Spec: apiextensionsv1.CompositionSpec{
+					Mode:     &pipeline,
+					Pipeline: []apiextensionsv1.PipelineStep{{Step: "test", FunctionRef: apiextensionsv1.FunctionReference{Name: "function-test"}}},
+				},
+			},
+			Functions: []pkgv1beta1.Function{{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "function-test",
+					Annotations: map[string]string{
+						functions.AnnotationKeyRuntime:                  string(functions.AnnotationValueRuntimeDevelopment),
+						functions.AnnotationKeyRuntimeDevelopmentTarget: lis.Addr().String(),
+					},
+				},
+			}},
+		}
+	}
+
+	b, err := RenderBenchmark(context.Background(), []Inputs{in("xr-a"), in("xr-b")}, 10, 3)
+	if err != nil {
+		t.Fatalf("RenderBenchmark(...): %s", err)
+	}
+
+	if b.Renders.Count != 10 {
+		t.Errorf("RenderBenchmark(...): want 10 renders, got %d", b.Renders.Count)
+	}
+
+	size := proto.Size(rsp)
+	want := []StepBenchmark{{
+		Step:          "test",
+		Function:      "function-test",
+		Latency:       Distribution[time.Duration]{Count: 10},
+		ResponseBytes: Distribution[int]{Count: 10, Min: size, Mean: size, P50: size, P95: size, P99: size, Max: size},
+	}}
+
+	// Latencies vary, so we only check how many were recorded.
+	ignoreLatency := cmp.Transformer("Latency", func(d Distribution[time.Duration]) Distribution[time.Duration] {
+		return Distribution[time.Duration]{Count: d.Count}
+	})
+	if diff := cmp.Diff(want, b.Steps, ignoreLatency); diff != "" {
+		t.Errorf("RenderBenchmark(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,258 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+// A Change describes how a resource changed between two renders.
+type Change string
+
+// Changes.
+const (
+	ChangeAdded     Change = "added"
+	ChangeRemoved   Change = "removed"
+	ChangeChanged   Change = "changed"
+	ChangeUnchanged Change = "unchanged"
+)
+
+// A ResourceComparison describes how a rendered resource changed between two
+// renders.
+type ResourceComparison struct {
+	// Name of the resource. This is the XR's name, or the composition resource
+	// name of a composed resource.
+	Name string
+
+	// APIVersion and Kind of the resource.
+	APIVersion string
+	Kind       string
+
+	// Change is how the resource changed.
+	Change Change
+
+	// Diff is a semantic diff of the resource, if it changed.
+	Diff string
+}
+
+// A Comparison describes how the outputs of two renders differ.
+type Comparison struct {
+	CompositeResource ResourceComparison
+	ComposedResources []ResourceComparison
+
+	// Results that only the head render returned (ResultsAdded), or that only
+	// the base render returned (ResultsRemoved).
+	ResultsAdded   []unstructured.Unstructured
+	ResultsRemoved []unstructured.Unstructured
+}
+
+// Changed returns true if anything changed between the two renders.
+func (c Comparison) Changed() bool {
+	if c.CompositeResource.Change != ChangeUnchanged || len(c.ResultsAdded) > 0 || len(c.ResultsRemoved) > 0 {
+		return true
+	}
+	for _, r := range c.ComposedResources {
+		if r.Change != ChangeUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderBoth renders the supplied base and head inputs. The Functions their
+// pipelines reference are started once. A Function that's referenced by both
+// pipelines is shared, unless the base and head versions of it differ.
+// Resources mode Compositions are rendered using function-patch-and-transform -
+// see AsPipeline.
+func RenderBoth(ctx context.Context, base, head Inputs, o ...Option) (Outputs, Outputs, error) {
+	base, err := AsPipeline(base)
+	if err != nil {
+		return Outputs{}, Outputs{}, errors.Wrap(err, "base")
+	}
+	head, err = AsPipeline(head)
+	if err != nil {
+		return Outputs{}, Outputs{}, errors.Wrap(err, "head")
+	}
+
+	bfns, err := pipelineFunctions(base)
+	if err != nil {
+		return Outputs{}, Outputs{}, errors.Wrap(err, "base")
+	}
+	hfns, err := pipelineFunctions(head)
+	if err != nil {
+		return Outputs{}, Outputs{}, errors.Wrap(err, "head")
+	}
+
+	fns, renamed := MergeFunctions(bfns, hfns)
+	rf, err := startFunctions(ctx, fns, o...)
+	if err != nil {
+		return Outputs{}, Outputs{}, err
+	}
+	defer rf.Cleanup()
+
+	bout, err := RunPipeline(ctx, rf, base)
+	if err != nil {
+		return bout, Outputs{}, errors.Wrap(err, "cannot render base")
+	}
+	hout, err := RunPipeline(ctx, &RenamedFunctions{StepRunner: rf, Names: renamed}, head)
+	return bout, hout, errors.Wrap(err, "cannot render head")
+}
+
+func pipelineFunctions(in Inputs) ([]pkgv1beta1.Function, error) {
+	pipeline, err := PipelineRange(in.Composition.Spec.Pipeline, in.FromStep, in.UntilStep)
+	if err != nil {
+		return nil, err
+	}
+	return functions.Referenced(in.Functions, pipeline)
+}
+
+// MergeFunctions returns the supplied base Functions, plus any supplied head
+// Functions that aren't the same as a base Function. Head Functions that have
+// the same name as a different base Function are renamed to a name no other
+// Function uses, e.g. function-a-head. It returns a map of head Function names
+// to the names they were renamed to.
+func MergeFunctions(base, head []pkgv1beta1.Function) ([]pkgv1beta1.Function, map[string]string) {
+	byName := make(map[string]pkgv1beta1.Function, len(base))
+	taken := make(map[string]bool, len(base)+len(head))
+	for _, fn := range base {
+		byName[fn.GetName()] = fn
+		taken[fn.GetName()] = true
+	}
+	for _, fn := range head {
+		taken[fn.GetName()] = true
+	}
+
+	out := append([]pkgv1beta1.Function{}, base...)
+	renamed := map[string]string{}
+	for _, fn := range head {
+		bfn, ok := byName[fn.GetName()]
+		if !ok {
+			out = append(out, fn)
+			continue
+		}
+		if equality.Semantic.DeepEqual(bfn.Spec, fn.Spec) && equality.Semantic.DeepEqual(bfn.GetAnnotations(), fn.GetAnnotations()) {
+			continue
+		}
+		name := fn.GetName() + "-head"
+		for i := 2; taken[name]; i++ {
+			name = fmt.Sprintf("%s-head-%d", fn.GetName(), i)
+		}
+		taken[name] = true
+		renamed[fn.GetName()] = name
+		rfn := fn.DeepCopy()
+		rfn.SetName(name)
+		out = append(out, *rfn)
+	}
+	return out, renamed
+}
+
+// RenamedFunctions is a StepRunner that runs steps using renamed Functions.
+type RenamedFunctions struct {
+	StepRunner
+
+	// Names maps the Function names referenced by pipeline steps to the names
+	// of the Functions that should run them.
+	Names map[string]string
+}
+
+// RunFunction runs the supplied pipeline step using the Function it references,
+// or the Function that was renamed from it.
+func (r *RenamedFunctions) RunFunction(ctx context.Context, fn apiextensionsv1.PipelineStep, req *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+	if name, ok := r.Names[fn.FunctionRef.Name]; ok {
+		fn = *fn.DeepCopy()
+		fn.FunctionRef.Name = name
+	}
+	return r.StepRunner.RunFunction(ctx, fn, req)
+}
+
+// Compare the supplied base and head render outputs. Resources are compared
+// semantically; composed resources are matched by composition resource name.
+func Compare(base, head Outputs) Comparison {
+	var bxr, hxr *unstructured.Unstructured
+	name := ""
+	if base.CompositeResource != nil {
+		bxr = &base.CompositeResource.Unstructured
+		name = bxr.GetName()
+	}
+	if head.CompositeResource != nil {
+		hxr = &head.CompositeResource.Unstructured
+		name = hxr.GetName()
+	}
+
+	c := Comparison{
+		CompositeResource: compareResources(name, bxr, hxr),
+		ComposedResources: make([]ResourceComparison, 0),
+	}
+
+	bcds := map[string]*unstructured.Unstructured{}
+	for i := range base.ComposedResources {
+		bcds[base.ComposedResources[i].GetAnnotations()[AnnotationKeyCompositionResourceName]] = &base.ComposedResources[i].Unstructured
+	}
+	hcds := map[string]*unstructured.Unstructured{}
+	for i := range head.ComposedResources {
+		hcds[head.ComposedResources[i].GetAnnotations()[AnnotationKeyCompositionResourceName]] = &head.ComposedResources[i].Unstructured
+	}
+
+	names := make([]string, 0, len(bcds)+len(hcds))
+	for name := range bcds {
+		names = append(names, name)
+	}
+	for name := range hcds {
+		if _, ok := bcds[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.ComposedResources = append(c.ComposedResources, compareResources(name, bcds[name], hcds[name]))
+	}
+
+	c.ResultsAdded = resultsNotIn(head.Results, base.Results)
+	c.ResultsRemoved = resultsNotIn(base.Results, head.Results)
+	return c
+}
+
+func compareResources(name string, base, head *unstructured.Unstructured) ResourceComparison {
+	switch {
+	case base == nil:
+		return ResourceComparison{Name: name, APIVersion: head.GetAPIVersion(), Kind: head.GetKind(), Change: ChangeAdded}
+	case head == nil:
+		return ResourceComparison{Name: name, APIVersion: base.GetAPIVersion(), Kind: base.GetKind(), Change: ChangeRemoved}
+	}
+	rc := ResourceComparison{Name: name, APIVersion: head.GetAPIVersion(), Kind: head.GetKind(), Change: ChangeUnchanged}
+	if diff := cmp.Diff(base.Object, head.Object); diff != "" {
+		rc.Change = ChangeChanged
+		rc.Diff = diff
+	}
+	return rc
+}
+
+// resultsNotIn returns the results in a that aren't in b.
+func resultsNotIn(a, b []unstructured.Unstructured) []unstructured.Unstructured {
+	out := make([]unstructured.Unstructured, 0)
+	for _, ra := range a {
+		found := false
+		for _, rb := range b {
+			if equality.Semantic.DeepEqual(ra.Object, rb.Object) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, ra)
+		}
+	}
+	return out
+}
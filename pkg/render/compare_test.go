@@ -0,0 +1,175 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+)
+
+func TestMergeFunctions(t *testing.T) {
+	fn := func(name, pkg string) pkgv1beta1.Function {
+		return pkgv1beta1.Function{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       pkgv1beta1.FunctionSpec{PackageSpec: pkgv1.PackageSpec{Package: pkg}},
+		}
+	}
+
+	type args struct {
+		base []pkgv1beta1.Function
+		head []pkgv1beta1.Function
+	}
+	type want struct {
+		fns     []pkgv1beta1.Function
+		renamed map[string]string
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Shared": {
+			reason: "Functions that are the same in base and head should only be included once.",
+			args: args{
+				base: []pkgv1beta1.Function{fn("function-a", "a:v1"), fn("function-b", "b:v1")},
+				head: []pkgv1beta1.Function{fn("function-b", "b:v1"), fn("function-c", "c:v1")},
+			},
+			want: want{
+				fns:     []pkgv1beta1.Function{fn("function-a", "a:v1"), fn("function-b", "b:v1"), fn("function-c", "c:v1")},
+				renamed: map[string]string{},
+			},
+		},
+		"Different": {
+			reason: "Head Functions with the same name as a different base Function should be renamed.",
+			args: args{
+				base: []pkgv1beta1.Function{fn("function-a", "a:v1")},
+				head: []pkgv1beta1.Function{fn("function-a", "a:v2")},
+			},
+			want: want{
+				fns:     []pkgv1beta1.Function{fn("function-a", "a:v1"), fn("function-a-head", "a:v2")},
+				renamed: map[string]string{"function-a": "function-a-head"},
+			},
+		},
+		"RenamedNameTaken": {
+			reason: "Renamed head Functions shouldn't clash with the name of another Function.",
+			args: args{
+				base: []pkgv1beta1.Function{fn("function-a", "a:v1"), fn("function-a-head", "b:v1")},
+				head: []pkgv1beta1.Function{fn("function-a", "a:v2"), fn("function-a-head", "b:v1")},
+			},
+			want: want{
+				fns:     []pkgv1beta1.Function{fn("function-a", "a:v1"), fn("function-a-head", "b:v1"), fn("function-a-head-2", "a:v2")},
+				renamed: map[string]string{"function-a": "function-a-head-2"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fns, renamed := MergeFunctions(tc.args.base, tc.args.head)
+
+			if diff := cmp.Diff(tc.want.fns, fns); diff != "" {
+				t.Errorf("%s\nMergeFunctions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.renamed, renamed); diff != "" {
+				t.Errorf("%s\nMergeFunctions(...): -want renamed, +got renamed:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRenamedFunctions(t *testing.T) {
+	var ran []string
+	r := &RenamedFunctions{
+		StepRunner: StepRunnerFn(func(_ context.Context, fn apiextensionsv1.PipelineStep, _ *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+			ran = append(ran, fn.FunctionRef.Name)
+			return &fnv1beta1.RunFunctionResponse{}, nil
+		}),
+		Names: map[string]string{"function-a": "function-a-head"},
+	}
+
+	for _, name := range []string{"function-a", "function-b"} {
+		if _, err := r.RunFunction(context.Background(), apiextensionsv1.PipelineStep{FunctionRef: apiextensionsv1.FunctionReference{Name: name}}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if diff := cmp.Diff([]string{"function-a-head", "function-b"}, ran); diff != "" {
+		t.Errorf("RunFunction(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	xr := func(j string) *composite.Unstructured {
+		return &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: xtest.MustLoadJSON(j)}}
+	}
+	cd := func(j string) composed.Unstructured {
+		return composed.Unstructured{Unstructured: unstructured.Unstructured{Object: xtest.MustLoadJSON(j)}}
+	}
+	result := func(step, msg string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "xrender.crossplane.io/v1beta1",
+			"kind":       "Result",
+			"step":       step,
+			"severity":   "SEVERITY_NORMAL",
+			"message":    msg,
+		}}
+	}
+
+	base := Outputs{
+		CompositeResource: xr(`{"apiVersion":"example.org/v1","kind":"XBucket","metadata":{"name":"cool-xr"}}`),
+		ComposedResources: []composed.Unstructured{
+			cd(`{"apiVersion":"example.org/v1","kind":"Bucket","metadata":{"annotations":{"crossplane.io/composition-resource-name":"bucket"}},"spec":{"size":1}}`),
+			cd(`{"apiVersion":"example.org/v1","kind":"Policy","metadata":{"annotations":{"crossplane.io/composition-resource-name":"policy"}}}`),
+			cd(`{"apiVersion":"example.org/v1","kind":"Tag","metadata":{"annotations":{"crossplane.io/composition-resource-name":"tag"}}}`),
+		},
+		Results: []unstructured.Unstructured{result("a", "same"), result("a", "old")},
+	}
+	head := Outputs{
+		CompositeResource: xr(`{"apiVersion":"example.org/v1","kind":"XBucket","metadata":{"name":"cool-xr"}}`),
+		ComposedResources: []composed.Unstructured{
+			cd(`{"apiVersion":"example.org/v1","kind":"Bucket","metadata":{"annotations":{"crossplane.io/composition-resource-name":"bucket"}},"spec":{"size":2}}`),
+			cd(`{"apiVersion":"example.org/v1","kind":"Role","metadata":{"annotations":{"crossplane.io/composition-resource-name":"role"}}}`),
+			cd(`{"apiVersion":"example.org/v1","kind":"Tag","metadata":{"annotations":{"crossplane.io/composition-resource-name":"tag"}}}`),
+		},
+		Results: []unstructured.Unstructured{result("a", "same"), result("b", "new")},
+	}
+
+	want := Comparison{
+		CompositeResource: ResourceComparison{Name: "cool-xr", APIVersion: "example.org/v1", Kind: "XBucket", Change: ChangeUnchanged},
+		ComposedResources: []ResourceComparison{
+			{Name: "bucket", APIVersion: "example.org/v1", Kind: "Bucket", Change: ChangeChanged},
+			{Name: "policy", APIVersion: "example.org/v1", Kind: "Policy", Change: ChangeRemoved},
+			{Name: "role", APIVersion: "example.org/v1", Kind: "Role", Change: ChangeAdded},
+			{Name: "tag", APIVersion: "example.org/v1", Kind: "Tag", Change: ChangeUnchanged},
+		},
+		ResultsAdded:   []unstructured.Unstructured{result("b", "new")},
+		ResultsRemoved: []unstructured.Unstructured{result("a", "old")},
+	}
+
+	got := Compare(base, head)
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(ResourceComparison{}, "Diff")); diff != "" {
+		t.Errorf("Compare(...): -want, +got:\n%s", diff)
+	}
+	if got.ComposedResources[0].Diff == "" {
+		t.Errorf("Compare(...): want a diff of changed composed resource %q", got.ComposedResources[0].Name)
+	}
+	if !got.Changed() {
+		t.Errorf("Compare(...).Changed(): want true, got false")
+	}
+	if Compare(base, base).Changed() {
+		t.Errorf("Compare(base, base).Changed(): want false, got true")
+	}
+}
@@ -0,0 +1,119 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+)
+
+// A CompositionSelection is a Composition selected for an XR, and why it was
+// selected.
+type CompositionSelection struct {
+	Composition *apiextensionsv1.Composition
+	Reason      string
+}
+
+// SelectComposition returns the Composition Crossplane would use for the
+// supplied XR. In order of precedence that's:
+//
+//  1. The XRD's enforced Composition.
+//  2. The Composition referenced by the XR's spec.compositionRef.
+//  3. The Composition whose labels match the XR's spec.compositionSelector.
+//  4. The XRD's default Composition.
+//
+// The XRD is optional. If none of the above apply and only one of the supplied
+// Compositions is for the XR's type, that Composition is selected. It returns
+// an error if the selection is ambiguous.
+func SelectComposition(xr *composite.Unstructured, xrd *apiextensionsv1.CompositeResourceDefinition, comps []apiextensionsv1.Composition) (CompositionSelection, error) {
+	gk := schema.FromAPIVersionAndKind(xr.GetAPIVersion(), xr.GetKind()).GroupKind()
+
+	if xrd != nil && xrd.Spec.EnforcedCompositionRef != nil {
+		return selectByName(gk, comps, xrd.Spec.EnforcedCompositionRef.Name, fmt.Sprintf("it's the enforced Composition of XRD %q", xrd.GetName()))
+	}
+	if ref := xr.GetCompositionReference(); ref != nil && ref.Name != "" {
+		return selectByName(gk, comps, ref.Name, "it's referenced by the XR's spec.compositionRef")
+	}
+
+	candidates := make([]apiextensionsv1.Composition, 0, len(comps))
+	for _, c := range comps {
+		if compositeGroupKind(c) == gk {
+			candidates = append(candidates, c)
+		}
+	}
+
+	if sel := xr.GetCompositionSelector(); sel != nil {
+		return selectByLabels(candidates, sel)
+	}
+	if xrd != nil && xrd.Spec.DefaultCompositionRef != nil {
+		return selectByName(gk, comps, xrd.Spec.DefaultCompositionRef.Name, fmt.Sprintf("it's the default Composition of XRD %q", xrd.GetName()))
+	}
+
+	switch len(candidates) {
+	case 0:
+		return CompositionSelection{}, errors.Errorf("no Composition is for XRs of type %s", gk)
+	case 1:
+		return CompositionSelection{Composition: &candidates[0], Reason: fmt.Sprintf("it's the only Composition for XRs of type %s", gk)}, nil
+	default:
+		return CompositionSelection{}, errors.Errorf("%d Compositions are for XRs of type %s (%s) - set the XR's spec.compositionRef or spec.compositionSelector to select one", len(candidates), gk, compositionNames(candidates))
+	}
+}
+
+func selectByName(gk schema.GroupKind, comps []apiextensionsv1.Composition, name, reason string) (CompositionSelection, error) {
+	for i := range comps {
+		c := &comps[i]
+		if c.GetName() != name {
+			continue
+		}
+		if got := compositeGroupKind(*c); got != gk {
+			return CompositionSelection{}, errors.Errorf("Composition %q is for XRs of type %s, not %s", name, got, gk)
+		}
+		return CompositionSelection{Composition: c, Reason: reason}, nil
+	}
+	return CompositionSelection{}, errors.Errorf("Composition %q doesn't exist, but %s", name, reason)
+}
+
+func selectByLabels(candidates []apiextensionsv1.Composition, sel *metav1.LabelSelector) (CompositionSelection, error) {
+	s, err := metav1.LabelSelectorAsSelector(sel)
+	if err != nil {
+		return CompositionSelection{}, errors.Wrap(err, "invalid spec.compositionSelector")
+	}
+
+	matched := make([]apiextensionsv1.Composition, 0)
+	for _, c := range candidates {
+		if s.Matches(labels.Set(c.GetLabels())) {
+			matched = append(matched, c)
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		return CompositionSelection{}, errors.Errorf("no Composition matches the XR's spec.compositionSelector (%s)", s)
+	case 1:
+		return CompositionSelection{Composition: &matched[0], Reason: fmt.Sprintf("its labels match the XR's spec.compositionSelector (%s)", s)}, nil
+	default:
+		// Crossplane would pick one of these at random.
+		return CompositionSelection{}, errors.Errorf("%d Compositions match the XR's spec.compositionSelector (%s): %s", len(matched), s, compositionNames(matched))
+	}
+}
+
+func compositeGroupKind(c apiextensionsv1.Composition) schema.GroupKind {
+	return schema.FromAPIVersionAndKind(c.Spec.CompositeTypeRef.APIVersion, c.Spec.CompositeTypeRef.Kind).GroupKind()
+}
+
+func compositionNames(comps []apiextensionsv1.Composition) string {
+	names := make([]string, len(comps))
+	for i := range comps {
+		names[i] = comps[i].GetName()
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,202 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+)
+
+func TestSelectComposition(t *testing.T) {
+	comp := func(name, kind string, labels map[string]string) apiextensionsv1.Composition {
+		return apiextensionsv1.Composition{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Spec: apiextensionsv1.CompositionSpec{
+				CompositeTypeRef: apiextensionsv1.TypeReference{APIVersion: "example.org/v1", Kind: kind},
+			},
+		}
+	}
+	xr := func(fns ...func(xr *composite.Unstructured)) *composite.Unstructured {
+		xr := composite.New()
+		xr.SetAPIVersion("example.org/v1alpha1")
+		xr.SetKind("XBucket")
+		for _, fn := range fns {
+			fn(xr)
+		}
+		return xr
+	}
+	xrd := func(enforced, def string) *apiextensionsv1.CompositeResourceDefinition {
+		xrd := &apiextensionsv1.CompositeResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "xbuckets.example.org"}}
+		if enforced != "" {
+			xrd.Spec.EnforcedCompositionRef = &apiextensionsv1.CompositionReference{Name: enforced}
+		}
+		if def != "" {
+			xrd.Spec.DefaultCompositionRef = &apiextensionsv1.CompositionReference{Name: def}
+		}
+		return xrd
+	}
+
+	comps := []apiextensionsv1.Composition{
+		comp("aws", "XBucket", map[string]string{"provider": "aws", "tier": "prod"}),
+		comp("aws-dev", "XBucket", map[string]string{"provider": "aws", "tier": "dev"}),
+		comp("gcp", "XBucket", map[string]string{"provider": "gcp"}),
+		comp("database", "XDatabase", map[string]string{"provider": "aws", "tier": "prod"}),
+	}
+
+	type args struct {
+		xr    *composite.Unstructured
+		xrd   *apiextensionsv1.CompositeResourceDefinition
+		comps []apiextensionsv1.Composition
+	}
+	type want struct {
+		name string
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Enforced": {
+			reason: "The XRD's enforced Composition should take precedence over the XR's reference.",
+			args: args{
+				xr: xr(func(xr *composite.Unstructured) {
+					xr.SetCompositionReference(&corev1.ObjectReference{Name: "gcp"})
+				}),
+				xrd:   xrd("aws", ""),
+				comps: comps,
+			},
+			want: want{
+				name: "aws",
+			},
+		},
+		"Reference": {
+			reason: "The Composition referenced by the XR should be selected.",
+			args: args{
+				xr: xr(func(xr *composite.Unstructured) {
+					xr.SetCompositionReference(&corev1.ObjectReference{Name: "gcp"})
+				}),
+				xrd:   xrd("", "aws"),
+				comps: comps,
+			},
+			want: want{
+				name: "gcp",
+			},
+		},
+		"ReferenceMissing": {
+			reason: "We should return an error if the XR references a Composition that doesn't exist.",
+			args: args{
+				xr: xr(func(xr *composite.Unstructured) {
+					xr.SetCompositionReference(&corev1.ObjectReference{Name: "azure"})
+				}),
+				comps: comps,
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"ReferenceWrongType": {
+			reason: "We should return an error if the XR references a Composition for another type of XR.",
+			args: args{
+				xr: xr(func(xr *composite.Unstructured) {
+					xr.SetCompositionReference(&corev1.ObjectReference{Name: "database"})
+				}),
+				comps: comps,
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"Selector": {
+			reason: "The only Composition for the XR's type with labels matching the XR's selector should be selected.",
+			args: args{
+				xr: xr(func(xr *composite.Unstructured) {
+					xr.SetCompositionSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"provider": "aws", "tier": "prod"}})
+				}),
+				xrd:   xrd("", "gcp"),
+				comps: comps,
+			},
+			want: want{
+				name: "aws",
+			},
+		},
+		"SelectorAmbiguous": {
+			reason: "We should return an error if more than one Composition matches the XR's selector.",
+			args: args{
+				xr: xr(func(xr *composite.Unstructured) {
+					xr.SetCompositionSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"provider": "aws"}})
+				}),
+				comps: comps,
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"SelectorNoMatch": {
+			reason: "We should return an error if no Composition matches the XR's selector.",
+			args: args{
+				xr: xr(func(xr *composite.Unstructured) {
+					xr.SetCompositionSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"provider": "azure"}})
+				}),
+				comps: comps,
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"Default": {
+			reason: "The XRD's default Composition should be selected if the XR doesn't reference or select one.",
+			args: args{
+				xr:    xr(),
+				xrd:   xrd("", "aws-dev"),
+				comps: comps,
+			},
+			want: want{
+				name: "aws-dev",
+			},
+		},
+		"OnlyCandidate": {
+			reason: "The only Composition for the XR's type should be selected if the XR doesn't reference or select one.",
+			args: args{
+				xr:    xr(),
+				comps: []apiextensionsv1.Composition{comps[0], comps[3]},
+			},
+			want: want{
+				name: "aws",
+			},
+		},
+		"Ambiguous": {
+			reason: "We should return an error if several Compositions are for the XR's type and nothing selects one.",
+			args: args{
+				xr:    xr(),
+				comps: comps,
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s, err := SelectComposition(tc.args.xr, tc.args.xrd, tc.args.comps)
+
+			got := ""
+			if s.Composition != nil {
+				got = s.Composition.GetName()
+			}
+			if diff := cmp.Diff(tc.want.name, got); diff != "" {
+				t.Errorf("%s\nSelectComposition(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nSelectComposition(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package render
+
+import (
+	"encoding/json"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// Defaults used when converting a Resources mode Composition to use
+// function-patch-and-transform.
+const (
+	DefaultPatchAndTransformFunctionName    = "function-patch-and-transform"
+	DefaultPatchAndTransformFunctionPackage = "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.2.1"
+	DefaultPatchAndTransformStepName        = "patch-and-transform"
+)
+
+// PatchAndTransformInput API group, version and kind.
+const (
+	PatchAndTransformInputAPIVersion = "pt.fn.crossplane.io/v1beta1"
+	PatchAndTransformInputKind       = "Resources"
+)
+
+// PatchAndTransformInput is the input of function-patch-and-transform. Its
+// schema mirrors the patch and transform fields of a Resources mode
+// Composition.
+type PatchAndTransformInput struct {
+	metav1.TypeMeta `json:",inline"`
+
+	PatchSets   []apiextensionsv1.PatchSet         `json:"patchSets,omitempty"`
+	Environment *PatchAndTransformEnvironment      `json:"environment,omitempty"`
+	Resources   []apiextensionsv1.ComposedTemplate `json:"resources"`
+}
+
+// PatchAndTransformEnvironment configures environment patches.
+type PatchAndTransformEnvironment struct {
+	Patches []apiextensionsv1.EnvironmentPatch `json:"patches,omitempty"`
+}
+
+// IsPatchAndTransformFunction returns true if the supplied Function appears to
+// be function-patch-and-transform.
+func IsPatchAndTransformFunction(fn pkgv1beta1.Function) bool {
+	return fn.GetName() == DefaultPatchAndTransformFunctionName || strings.Contains(fn.Spec.Package, "/"+DefaultPatchAndTransformFunctionName)
+}
+
+// PatchAndTransformFunction returns the name of function-patch-and-transform
+// in the supplied Functions. If it isn't there it returns the supplied
+// Functions with function-patch-and-transform added.
+func PatchAndTransformFunction(fns []pkgv1beta1.Function) (string, []pkgv1beta1.Function) {
+	for _, fn := range fns {
+		if IsPatchAndTransformFunction(fn) {
+			return fn.GetName(), fns
+		}
+	}
+	fn := pkgv1beta1.Function{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: pkgv1beta1.SchemeGroupVersion.String(),
+			Kind:       pkgv1beta1.FunctionKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: DefaultPatchAndTransformFunctionName},
+		Spec: pkgv1beta1.FunctionSpec{
+			PackageSpec: pkgv1.PackageSpec{Package: DefaultPatchAndTransformFunctionPackage},
+		},
+	}
+	return fn.GetName(), append(fns, fn)
+}
+
+// ConvertToPipeline returns a copy of the supplied Resources mode Composition,
+// converted to an equivalent Pipeline mode Composition. The converted
+// Composition has one pipeline step, which uses the named Function to run
+// function-patch-and-transform. The Composition's resources, patch sets and
+// environment patches become the step's input.
+//
+// Fields that Crossplane's API server would default are set explicitly, given
+// the supplied Composition was probably read from a file rather than the API
+// server.
+func ConvertToPipeline(comp *apiextensionsv1.Composition, fnName string) (*apiextensionsv1.Composition, error) {
+	if m := comp.Spec.Mode; m != nil && *m != apiextensionsv1.CompositionModeResources {
+		return nil, errors.Errorf("Composition %q must use spec.mode: Resources", comp.GetName())
+	}
+
+	in := &PatchAndTransformInput{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: PatchAndTransformInputAPIVersion,
+			Kind:       PatchAndTransformInputKind,
+		},
+		PatchSets: make([]apiextensionsv1.PatchSet, len(comp.Spec.PatchSets)),
+		Resources: make([]apiextensionsv1.ComposedTemplate, len(comp.Spec.Resources)),
+	}
+
+	for i := range comp.Spec.PatchSets {
+		ps := comp.Spec.PatchSets[i].DeepCopy()
+		for j := range ps.Patches {
+			defaultPatch(&ps.Patches[j])
+		}
+		in.PatchSets[i] = *ps
+	}
+
+	for i := range comp.Spec.Resources {
+		t := comp.Spec.Resources[i].DeepCopy()
+
+		// function-patch-and-transform requires every resource to be named.
+		// Crossplane doesn't annotate resources composed from an unnamed
+		// template with a composition resource name, so no name we chose
+		// would match the template's observed composed resources.
+		if t.Name == nil || *t.Name == "" {
+			return nil, errors.Errorf("Composition %q spec.resources[%d] has no name - function-patch-and-transform requires every resource to be named", comp.GetName(), i)
+		}
+		defaultComposedTemplate(t)
+		in.Resources[i] = *t
+	}
+
+	out := comp.DeepCopy()
+	out.Spec.Mode = ptr.To(apiextensionsv1.CompositionModePipeline)
+	out.Spec.Resources = nil
+	out.Spec.PatchSets = nil
+
+	// Crossplane still selects EnvironmentConfigs for Pipeline mode
+	// Compositions, but function-patch-and-transform applies the patches.
+	if e := out.Spec.Environment; e != nil && len(e.Patches) > 0 {
+		in.Environment = &PatchAndTransformEnvironment{Patches: e.Patches}
+		for i := range in.Environment.Patches {
+			p := &in.Environment.Patches[i]
+			if p.Type == "" {
+				p.Type = apiextensionsv1.PatchTypeFromCompositeFieldPath
+			}
+			defaultTransforms(p.Transforms)
+		}
+		e.Patches = nil
+		if len(e.EnvironmentConfigs) == 0 && len(e.DefaultData) == 0 && e.Policy == nil {
+			out.Spec.Environment = nil
+		}
+	}
+
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal function-patch-and-transform input")
+	}
+	out.Spec.Pipeline = []apiextensionsv1.PipelineStep{{
+		Step:        DefaultPatchAndTransformStepName,
+		FunctionRef: apiextensionsv1.FunctionReference{Name: fnName},
+		Input:       &runtime.RawExtension{Raw: raw},
+	}}
+
+	return out, nil
+}
+
+// AsPipeline returns the supplied inputs with a Resources mode Composition
+// converted to an equivalent Pipeline mode Composition that uses
+// function-patch-and-transform. Crossplane defaults a Composition with no mode
+// to Resources mode. function-patch-and-transform is added to the Functions if
+// it's missing. Inputs with any other mode of Composition are returned
+// unchanged.
+func AsPipeline(in Inputs) (Inputs, error) {
+	if m := in.Composition.Spec.Mode; m != nil && *m != apiextensionsv1.CompositionModeResources {
+		return in, nil
+	}
+
+	// Limit capacity so adding function-patch-and-transform copies the
+	// Functions rather than appending to them.
+	name, fns := PatchAndTransformFunction(in.Functions[:len(in.Functions):len(in.Functions)])
+	comp, err := ConvertToPipeline(in.Composition, name)
+	if err != nil {
+		return Inputs{}, errors.Wrapf(err, "cannot convert Composition %q to use function-patch-and-transform", in.Composition.GetName())
+	}
+	in.Composition = comp
+	in.Functions = fns
+	return in, nil
+}
+
+func defaultComposedTemplate(t *apiextensionsv1.ComposedTemplate) {
+	for j := range t.Patches {
+		defaultPatch(&t.Patches[j])
+	}
+	for j := range t.ConnectionDetails {
+		defaultConnectionDetail(&t.ConnectionDetails[j])
+	}
+	if len(t.ReadinessChecks) == 0 {
+		t.ReadinessChecks = []apiextensionsv1.ReadinessCheck{{
+			Type: apiextensionsv1.ReadinessCheckTypeMatchCondition,
+			MatchCondition: &apiextensionsv1.MatchConditionReadinessCheck{
+				Type:   "Ready",
+				Status: "True",
+			},
+		}}
+	}
+}
+
+func defaultPatch(p *apiextensionsv1.Patch) {
+	p.Type = p.GetType()
+	defaultTransforms(p.Transforms)
+}
+
+func defaultTransforms(ts []apiextensionsv1.Transform) {
+	for i := range ts {
+		t := &ts[i]
+		if t.Math != nil {
+			t.Math.Type = t.Math.GetType()
+		}
+		if t.String != nil && t.String.Type == "" {
+			t.String.Type = apiextensionsv1.StringTransformTypeFormat
+		}
+	}
+}
+
+func defaultConnectionDetail(cd *apiextensionsv1.ConnectionDetail) {
+	if cd.Type == nil {
+		switch {
+		case cd.Value != nil:
+			cd.Type = ptr.To(apiextensionsv1.ConnectionDetailTypeFromValue)
+		case cd.FromFieldPath != nil:
+			cd.Type = ptr.To(apiextensionsv1.ConnectionDetailTypeFromFieldPath)
+		case cd.FromConnectionSecretKey != nil:
+			cd.Type = ptr.To(apiextensionsv1.ConnectionDetailTypeFromConnectionSecretKey)
+		}
+	}
+	if cd.Name == nil && cd.FromConnectionSecretKey != nil {
+		cd.Name = ptr.To(*cd.FromConnectionSecretKey)
+	}
+}
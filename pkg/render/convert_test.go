@@ -0,0 +1,220 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+)
+
+func TestConvertToPipeline(t *testing.T) {
+	type args struct {
+		comp   *apiextensionsv1.Composition
+		fnName string
+	}
+	type want struct {
+		comp *apiextensionsv1.Composition
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"AlreadyPipeline": {
+			reason: "We should return an error if the Composition is already in Pipeline mode.",
+			args: args{
+				comp: &apiextensionsv1.Composition{
+					Spec: apiextensionsv1.CompositionSpec{Mode: ptr.To(apiextensionsv1.CompositionModePipeline)},
+				},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"UnnamedResource": {
+			reason: "We should return an error if a resource has no name, because function-patch-and-transform requires one.",
+			args: args{
+				comp: &apiextensionsv1.Composition{
+					Spec: apiextensionsv1.CompositionSpec{
+						Resources: []apiextensionsv1.ComposedTemplate{{}},
+					},
+				},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"Convert": {
+			reason: "Resources, patch sets and environment patches should become function-patch-and-transform input, with defaults set.",
+			args: args{
+				fnName: "function-patch-and-transform",
+				comp: &apiextensionsv1.Composition{
+					ObjectMeta: metav1.ObjectMeta{Name: "cool-composition"},
+					Spec: apiextensionsv1.CompositionSpec{
+						CompositeTypeRef:                  apiextensionsv1.TypeReference{APIVersion: "example.org/v1", Kind: "XR"},
+						WriteConnectionSecretsToNamespace: ptr.To("crossplane-system"),
+						Environment: &apiextensionsv1.EnvironmentConfiguration{
+							Policy: &xpv1.Policy{Resolution: ptr.To(xpv1.ResolutionPolicyOptional)},
+							Patches: []apiextensionsv1.EnvironmentPatch{{
+								FromFieldPath: ptr.To("spec.region"),
+								ToFieldPath:   ptr.To("region"),
+							}},
+						},
+						PatchSets: []apiextensionsv1.PatchSet{{
+							Name: "common",
+							Patches: []apiextensionsv1.Patch{{
+								FromFieldPath: ptr.To("spec.size"),
+								ToFieldPath:   ptr.To("spec.forProvider.size"),
+								Transforms: []apiextensionsv1.Transform{{
+									Type: apiextensionsv1.TransformTypeMath,
+									Math: &apiextensionsv1.MathTransform{Multiply: ptr.To[int64](2)},
+								}},
+							}},
+						}},
+						Resources: []apiextensionsv1.ComposedTemplate{
+							{
+								Name: ptr.To("bucket"),
+								Base: runtime.RawExtension{Raw: []byte(`{"apiVersion":"example.org/v1","kind":"Bucket"}`)},
+								Patches: []apiextensionsv1.Patch{{
+									Type:         apiextensionsv1.PatchTypePatchSet,
+									PatchSetName: ptr.To("common"),
+								}},
+								ConnectionDetails: []apiextensionsv1.ConnectionDetail{{
+									FromConnectionSecretKey: ptr.To("password"),
+								}},
+							},
+							{
+								Name: ptr.To("table"),
+								Base: runtime.RawExtension{Raw: []byte(`{"apiVersion":"example.org/v1","kind":"Table"}`)},
+								ReadinessChecks: []apiextensionsv1.ReadinessCheck{{
+									Type: apiextensionsv1.ReadinessCheckTypeNone,
+								}},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				comp: &apiextensionsv1.Composition{
+					ObjectMeta: metav1.ObjectMeta{Name: "cool-composition"},
+					Spec: apiextensionsv1.CompositionSpec{
+						CompositeTypeRef:                  apiextensionsv1.TypeReference{APIVersion: "example.org/v1", Kind: "XR"},
+						WriteConnectionSecretsToNamespace: ptr.To("crossplane-system"),
+						Mode:                              ptr.To(apiextensionsv1.CompositionModePipeline),
+						Environment: &apiextensionsv1.EnvironmentConfiguration{
+							Policy: &xpv1.Policy{Resolution: ptr.To(xpv1.ResolutionPolicyOptional)},
+						},
+						Pipeline: []apiextensionsv1.PipelineStep{{
+							Step:        "patch-and-transform",
+							FunctionRef: apiextensionsv1.FunctionReference{Name: "function-patch-and-transform"},
+							Input: &runtime.RawExtension{Raw: []byte(`{
+								"apiVersion": "pt.fn.crossplane.io/v1beta1",
+								"kind": "Resources",
+								"environment": {
+									"patches": [{"type": "FromCompositeFieldPath", "fromFieldPath": "spec.region", "toFieldPath": "region"}]
+								},
+								"patchSets": [{
+									"name": "common",
+									"patches": [{
+										"type": "FromCompositeFieldPath",
+										"fromFieldPath": "spec.size",
+										"toFieldPath": "spec.forProvider.size",
+										"transforms": [{"type": "math", "math": {"type": "Multiply", "multiply": 2}}]
+									}]
+								}],
+								"resources": [
+									{
+										"name": "bucket",
+										"base": {"apiVersion": "example.org/v1", "kind": "Bucket"},
+										"patches": [{"type": "PatchSet", "patchSetName": "common"}],
+										"connectionDetails": [{"name": "password", "type": "FromConnectionSecretKey", "fromConnectionSecretKey": "password"}],
+										"readinessChecks": [{"type": "MatchCondition", "matchCondition": {"type": "Ready", "status": "True"}}]
+									},
+									{
+										"name": "table",
+										"base": {"apiVersion": "example.org/v1", "kind": "Table"},
+										"readinessChecks": [{"type": "None"}]
+									}
+								]
+							}`)},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ConvertToPipeline(tc.args.comp, tc.args.fnName)
+
+			if diff := cmp.Diff(tc.want.comp, got, xtest.EquateRawJSON); diff != "" {
+				t.Errorf("%s\nConvertToPipeline(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nConvertToPipeline(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPatchAndTransformFunction(t *testing.T) {
+	fn := func(name, pkg string) pkgv1beta1.Function {
+		return pkgv1beta1.Function{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       pkgv1beta1.FunctionSpec{PackageSpec: pkgv1.PackageSpec{Package: pkg}},
+		}
+	}
+
+	type want struct {
+		name string
+		fns  []pkgv1beta1.Function
+	}
+	cases := map[string]struct {
+		reason string
+		fns    []pkgv1beta1.Function
+		want   want
+	}{
+		"ByPackage": {
+			reason: "We should find function-patch-and-transform by its package, even if it has another name.",
+			fns:    []pkgv1beta1.Function{fn("function-dummy", "xpkg.upbound.io/crossplane-contrib/function-dummy:v0.2.1"), fn("pt", "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.3.0")},
+			want: want{
+				name: "pt",
+				fns:  []pkgv1beta1.Function{fn("function-dummy", "xpkg.upbound.io/crossplane-contrib/function-dummy:v0.2.1"), fn("pt", "xpkg.upbound.io/crossplane-contrib/function-patch-and-transform:v0.3.0")},
+			},
+		},
+		"Missing": {
+			reason: "We should add function-patch-and-transform if it's missing.",
+			fns:    []pkgv1beta1.Function{fn("function-dummy", "xpkg.upbound.io/crossplane-contrib/function-dummy:v0.2.1")},
+			want: want{
+				name: "function-patch-and-transform",
+				fns:  []pkgv1beta1.Function{fn("function-dummy", "xpkg.upbound.io/crossplane-contrib/function-dummy:v0.2.1"), fn("function-patch-and-transform", DefaultPatchAndTransformFunctionPackage)},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			name, fns := PatchAndTransformFunction(tc.fns)
+
+			if diff := cmp.Diff(tc.want.name, name); diff != "" {
+				t.Errorf("%s\nPatchAndTransformFunction(...): -want name, +got name:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.fns, fns, cmpopts.IgnoreFields(pkgv1beta1.Function{}, "TypeMeta")); diff != "" {
+				t.Errorf("%s\nPatchAndTransformFunction(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
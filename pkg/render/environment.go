@@ -0,0 +1,285 @@
+package render
+
+import (
+	"encoding/json"
+	"sort"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1"
+)
+
+// FunctionContextKeyEnvironment is the Function pipeline context key under
+// which Crossplane supplies the XR's environment.
+const FunctionContextKeyEnvironment = "apiextensions.crossplane.io/environment"
+
+// The API version and kind of an environment, as supplied to Functions.
+const (
+	EnvironmentAPIVersion = "internal.crossplane.io/v1alpha1"
+	EnvironmentKind       = "Environment"
+)
+
+// SelectEnvironmentConfigs returns the EnvironmentConfigs the supplied
+// Composition selects for the supplied XR, in order. It selects them the way
+// Crossplane does: by reference, or by matching their labels to values from
+// the XR. If the XR already references EnvironmentConfigs and the
+// Composition's environment policy isn't to always resolve them, the XR's
+// references are used.
+func SelectEnvironmentConfigs(xr *composite.Unstructured, env *apiextensionsv1.EnvironmentConfiguration, ecs []v1alpha1.EnvironmentConfig) ([]v1alpha1.EnvironmentConfig, error) {
+	if env == nil {
+		return nil, nil
+	}
+
+	byName := make(map[string]v1alpha1.EnvironmentConfig, len(ecs))
+	for _, ec := range ecs {
+		byName[ec.GetName()] = ec
+	}
+
+	// Use the XR's existing references, if any.
+	if refs := xr.GetEnvironmentConfigReferences(); !env.ShouldResolve(refs) {
+		selected := make([]v1alpha1.EnvironmentConfig, 0, len(refs))
+		for _, ref := range refs {
+			ec, ok := byName[ref.Name]
+			if !ok {
+				if env.IsRequired() {
+					return nil, errors.Errorf("XR references EnvironmentConfig %q, which doesn't exist", ref.Name)
+				}
+				continue
+			}
+			selected = append(selected, ec)
+		}
+		return selected, nil
+	}
+
+	selected := make([]v1alpha1.EnvironmentConfig, 0, len(env.EnvironmentConfigs))
+	for i, src := range env.EnvironmentConfigs {
+		switch src.Type {
+		case apiextensionsv1.EnvironmentSourceTypeReference, "":
+			if src.Ref == nil {
+				return nil, errors.Errorf("spec.environment.environmentConfigs[%d]: ref is required", i)
+			}
+			ec, ok := byName[src.Ref.Name]
+			if !ok {
+				if env.IsRequired() {
+					return nil, errors.Errorf("spec.environment.environmentConfigs[%d]: EnvironmentConfig %q doesn't exist", i, src.Ref.Name)
+				}
+				continue
+			}
+			selected = append(selected, ec)
+		case apiextensionsv1.EnvironmentSourceTypeSelector:
+			if src.Selector == nil {
+				return nil, errors.Errorf("spec.environment.environmentConfigs[%d]: selector is required", i)
+			}
+			matched, err := SelectByLabels(xr, src.Selector, ecs)
+			if err != nil {
+				return nil, errors.Wrapf(err, "spec.environment.environmentConfigs[%d]", i)
+			}
+			if len(matched) == 0 && env.IsRequired() {
+				return nil, errors.Errorf("spec.environment.environmentConfigs[%d]: no EnvironmentConfigs match selector", i)
+			}
+			selected = append(selected, matched...)
+		default:
+			return nil, errors.Errorf("spec.environment.environmentConfigs[%d]: unknown type %q", i, src.Type)
+		}
+	}
+	return selected, nil
+}
+
+// SelectByLabels returns the supplied EnvironmentConfigs that match the
+// supplied selector. Label values are taken from the supplied XR.
+func SelectByLabels(xr *composite.Unstructured, s *apiextensionsv1.EnvironmentSourceSelector, ecs []v1alpha1.EnvironmentConfig) ([]v1alpha1.EnvironmentConfig, error) {
+	want := map[string]string{}
+	for i, m := range s.MatchLabels {
+		switch m.GetType() {
+		case apiextensionsv1.EnvironmentSourceSelectorLabelMatcherTypeValue:
+			if m.Value == nil {
+				return nil, errors.Errorf("matchLabels[%d]: value is required", i)
+			}
+			want[m.Key] = *m.Value
+		case apiextensionsv1.EnvironmentSourceSelectorLabelMatcherTypeFromCompositeFieldPath, "":
+			if m.ValueFromFieldPath == nil {
+				return nil, errors.Errorf("matchLabels[%d]: valueFromFieldPath is required", i)
+			}
+			v, err := fieldpath.Pave(xr.Object).GetString(*m.ValueFromFieldPath)
+			if fieldpath.IsNotFound(err) && m.FromFieldPathIsOptional() {
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "matchLabels[%d]: cannot get label value from XR", i)
+			}
+			want[m.Key] = v
+		default:
+			return nil, errors.Errorf("matchLabels[%d]: unknown type %q", i, m.Type)
+		}
+	}
+
+	matched := make([]v1alpha1.EnvironmentConfig, 0)
+	for _, ec := range ecs {
+		if hasLabels(ec.GetLabels(), want) {
+			matched = append(matched, ec)
+		}
+	}
+
+	switch s.Mode {
+	case apiextensionsv1.EnvironmentSourceSelectorSingleMode, "":
+		if len(matched) > 1 {
+			return nil, errors.Errorf("%d EnvironmentConfigs match selector, but mode is Single", len(matched))
+		}
+		return matched, nil
+	case apiextensionsv1.EnvironmentSourceSelectorMultiMode:
+		if err := sortEnvironmentConfigs(matched, s.SortByFieldPath); err != nil {
+			return nil, err
+		}
+		if s.MaxMatch != nil && uint64(len(matched)) > *s.MaxMatch {
+			matched = matched[:*s.MaxMatch]
+		}
+		return matched, nil
+	default:
+		return nil, errors.Errorf("unknown mode %q", s.Mode)
+	}
+}
+
+func hasLabels(have, want map[string]string) bool {
+	for k, v := range want {
+		if hv, ok := have[k]; !ok || hv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// sortEnvironmentConfigs sorts the supplied EnvironmentConfigs by the value at
+// the supplied field path, which must be a string or a number.
+func sortEnvironmentConfigs(ecs []v1alpha1.EnvironmentConfig, path string) error {
+	if path == "" {
+		path = "metadata.name"
+	}
+
+	values := make(map[string]any, len(ecs))
+	for i := range ecs {
+		name := ecs[i].GetName()
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&ecs[i])
+		if err != nil {
+			return errors.Wrapf(err, "cannot convert EnvironmentConfig %q", name)
+		}
+		v, err := fieldpath.Pave(u).GetValue(path)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get %q of EnvironmentConfig %q", path, name)
+		}
+		// Numbers may decode as int64 or float64 depending on whether they
+		// have a fractional part. Compare them all as float64, like Crossplane
+		// does.
+		if n, ok := v.(int64); ok {
+			v = float64(n)
+		}
+		values[name] = v
+	}
+
+	var err error
+	sort.SliceStable(ecs, func(i, j int) bool {
+		switch a := values[ecs[i].GetName()].(type) {
+		case string:
+			if b, ok := values[ecs[j].GetName()].(string); ok {
+				return a < b
+			}
+		case float64:
+			if b, ok := values[ecs[j].GetName()].(float64); ok {
+				return a < b
+			}
+		}
+		err = errors.Errorf("cannot sort EnvironmentConfigs by %q: values must all be strings or numbers", path)
+		return false
+	})
+	return err
+}
+
+// MergeEnvironment returns the environment produced by merging the supplied
+// default data and the data of the supplied EnvironmentConfigs, in order. Later
+// data takes precedence; objects are merged recursively.
+func MergeEnvironment(defaults map[string]any, ecs []v1alpha1.EnvironmentConfig) (map[string]any, error) {
+	env := map[string]any{}
+	merge(env, defaults)
+	for _, ec := range ecs {
+		data, err := jsonData(ec.Data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read data of EnvironmentConfig %q", ec.GetName())
+		}
+		merge(env, data)
+	}
+	env["apiVersion"] = EnvironmentAPIVersion
+	env["kind"] = EnvironmentKind
+	return env, nil
+}
+
+// WithEnvironment returns a copy of the supplied Function pipeline context,
+// with the environment the supplied Composition selects for the supplied XR.
+// The context is returned unchanged if the Composition doesn't configure an
+// environment, or if the context already contains one - e.g. when starting
+// mid-pipeline.
+func WithEnvironment(fctx *structpb.Struct, xr *composite.Unstructured, comp *apiextensionsv1.Composition, ecs []v1alpha1.EnvironmentConfig) (*structpb.Struct, error) {
+	env := comp.Spec.Environment
+	if env == nil {
+		return fctx, nil
+	}
+	if _, ok := fctx.GetFields()[FunctionContextKeyEnvironment]; ok {
+		return fctx, nil
+	}
+
+	selected, err := SelectEnvironmentConfigs(xr, env, ecs)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot select EnvironmentConfigs")
+	}
+	defaults, err := jsonData(env.DefaultData)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read spec.environment.defaultData")
+	}
+	merged, err := MergeEnvironment(defaults, selected)
+	if err != nil {
+		return nil, err
+	}
+	v, err := structpb.NewValue(merged)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot convert environment to protobuf")
+	}
+
+	out := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	for k, f := range fctx.GetFields() {
+		out.Fields[k] = f
+	}
+	out.Fields[FunctionContextKeyEnvironment] = v
+	return out, nil
+}
+
+// jsonData converts the supplied EnvironmentConfig data to a map.
+func jsonData(in map[string]extv1.JSON) (map[string]any, error) {
+	out := map[string]any{}
+	if len(in) == 0 {
+		return out, nil
+	}
+	j, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	return out, json.Unmarshal(j, &out)
+}
+
+// merge src into dst. Objects are merged recursively; anything else in src
+// replaces what's in dst.
+func merge(dst, src map[string]any) {
+	for k, sv := range src {
+		sm, sok := sv.(map[string]any)
+		dm, dok := dst[k].(map[string]any)
+		if sok && dok {
+			merge(dm, sm)
+			continue
+		}
+		dst[k] = runtime.DeepCopyJSONValue(sv)
+	}
+}
@@ -0,0 +1,399 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+)
+
+func NewEnvironmentConfig(name string, labels map[string]string, data map[string]string) v1alpha1.EnvironmentConfig {
+	ec := v1alpha1.EnvironmentConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Data:       map[string]extv1.JSON{},
+	}
+	for k, v := range data {
+		ec.Data[k] = extv1.JSON{Raw: []byte(v)}
+	}
+	return ec
+}
+
+func TestSelectEnvironmentConfigs(t *testing.T) {
+	xr := composite.New()
+	xr.Object["spec"] = map[string]any{"region": "us-east-2"}
+
+	ecs := []v1alpha1.EnvironmentConfig{
+		NewEnvironmentConfig("b-us-east-2", map[string]string{"region": "us-east-2", "tier": "prod"}, nil),
+		NewEnvironmentConfig("a-us-east-2", map[string]string{"region": "us-east-2", "tier": "dev"}, nil),
+		NewEnvironmentConfig("eu-west-1", map[string]string{"region": "eu-west-1"}, nil),
+	}
+
+	type args struct {
+		xr  *composite.Unstructured
+		env *apiextensionsv1.EnvironmentConfiguration
+	}
+	type want struct {
+		names []string
+		err   error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoEnvironment": {
+			reason: "Nothing should be selected if the Composition has no environment.",
+			args: args{
+				xr: xr,
+			},
+			want: want{},
+		},
+		"Reference": {
+			reason: "EnvironmentConfigs should be selected by reference.",
+			args: args{
+				xr: xr,
+				env: &apiextensionsv1.EnvironmentConfiguration{
+					EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{
+						{Type: apiextensionsv1.EnvironmentSourceTypeReference, Ref: &apiextensionsv1.EnvironmentSourceReference{Name: "eu-west-1"}},
+					},
+				},
+			},
+			want: want{
+				names: []string{"eu-west-1"},
+			},
+		},
+		"MissingRequiredReference": {
+			reason: "A required reference to an EnvironmentConfig that doesn't exist should return an error.",
+			args: args{
+				xr: xr,
+				env: &apiextensionsv1.EnvironmentConfiguration{
+					EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{
+						{Type: apiextensionsv1.EnvironmentSourceTypeReference, Ref: &apiextensionsv1.EnvironmentSourceReference{Name: "nope"}},
+					},
+				},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"MissingOptionalReference": {
+			reason: "An optional reference to an EnvironmentConfig that doesn't exist should be skipped.",
+			args: args{
+				xr: xr,
+				env: &apiextensionsv1.EnvironmentConfiguration{
+					Policy: &xpv1.Policy{Resolution: ptr.To(xpv1.ResolutionPolicyOptional)},
+					EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{
+						{Type: apiextensionsv1.EnvironmentSourceTypeReference, Ref: &apiextensionsv1.EnvironmentSourceReference{Name: "nope"}},
+					},
+				},
+			},
+			want: want{
+				names: []string{},
+			},
+		},
+		"SelectorMultiple": {
+			reason: "EnvironmentConfigs matching labels from the XR should be selected, sorted by name.",
+			args: args{
+				xr: xr,
+				env: &apiextensionsv1.EnvironmentConfiguration{
+					EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{{
+						Type: apiextensionsv1.EnvironmentSourceTypeSelector,
+						Selector: &apiextensionsv1.EnvironmentSourceSelector{
+							Mode: apiextensionsv1.EnvironmentSourceSelectorMultiMode,
+							MatchLabels: []apiextensionsv1.EnvironmentSourceSelectorLabelMatcher{
+								{Key: "region", Type: apiextensionsv1.EnvironmentSourceSelectorLabelMatcherTypeFromCompositeFieldPath, ValueFromFieldPath: ptr.To("spec.region")},
+							},
+						},
+					}},
+				},
+			},
+			want: want{
+				names: []string{"a-us-east-2", "b-us-east-2"},
+			},
+		},
+		"SelectorMaxMatch": {
+			reason: "No more than maxMatch EnvironmentConfigs should be selected.",
+			args: args{
+				xr: xr,
+				env: &apiextensionsv1.EnvironmentConfiguration{
+					EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{{
+						Type: apiextensionsv1.EnvironmentSourceTypeSelector,
+						Selector: &apiextensionsv1.EnvironmentSourceSelector{
+							Mode:     apiextensionsv1.EnvironmentSourceSelectorMultiMode,
+							MaxMatch: ptr.To[uint64](1),
+							MatchLabels: []apiextensionsv1.EnvironmentSourceSelectorLabelMatcher{
+								{Key: "region", Type: apiextensionsv1.EnvironmentSourceSelectorLabelMatcherTypeFromCompositeFieldPath, ValueFromFieldPath: ptr.To("spec.region")},
+							},
+						},
+					}},
+				},
+			},
+			want: want{
+				names: []string{"a-us-east-2"},
+			},
+		},
+		"SelectorSingleTooMany": {
+			reason: "Selecting more than one EnvironmentConfig in Single mode should return an error.",
+			args: args{
+				xr: xr,
+				env: &apiextensionsv1.EnvironmentConfiguration{
+					EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{{
+						Type: apiextensionsv1.EnvironmentSourceTypeSelector,
+						Selector: &apiextensionsv1.EnvironmentSourceSelector{
+							Mode: apiextensionsv1.EnvironmentSourceSelectorSingleMode,
+							MatchLabels: []apiextensionsv1.EnvironmentSourceSelectorLabelMatcher{
+								{Key: "region", Type: apiextensionsv1.EnvironmentSourceSelectorLabelMatcherTypeFromCompositeFieldPath, ValueFromFieldPath: ptr.To("spec.region")},
+							},
+						},
+					}},
+				},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"SelectorSingleValue": {
+			reason: "Label values may be literal values.",
+			args: args{
+				xr: xr,
+				env: &apiextensionsv1.EnvironmentConfiguration{
+					EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{{
+						Type: apiextensionsv1.EnvironmentSourceTypeSelector,
+						Selector: &apiextensionsv1.EnvironmentSourceSelector{
+							Mode: apiextensionsv1.EnvironmentSourceSelectorSingleMode,
+							MatchLabels: []apiextensionsv1.EnvironmentSourceSelectorLabelMatcher{
+								{Key: "region", Type: apiextensionsv1.EnvironmentSourceSelectorLabelMatcherTypeFromCompositeFieldPath, ValueFromFieldPath: ptr.To("spec.region")},
+								{Key: "tier", Type: apiextensionsv1.EnvironmentSourceSelectorLabelMatcherTypeValue, Value: ptr.To("prod")},
+							},
+						},
+					}},
+				},
+			},
+			want: want{
+				names: []string{"b-us-east-2"},
+			},
+		},
+		"XRReferences": {
+			reason: "The XR's existing references should be used unless the resolve policy is Always.",
+			args: args{
+				xr: func() *composite.Unstructured {
+					xr := composite.New()
+					xr.SetEnvironmentConfigReferences([]corev1.ObjectReference{{Name: "eu-west-1"}})
+					return xr
+				}(),
+				env: &apiextensionsv1.EnvironmentConfiguration{
+					EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{
+						{Type: apiextensionsv1.EnvironmentSourceTypeReference, Ref: &apiextensionsv1.EnvironmentSourceReference{Name: "a-us-east-2"}},
+					},
+				},
+			},
+			want: want{
+				names: []string{"eu-west-1"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			selected, err := SelectEnvironmentConfigs(tc.args.xr, tc.args.env, ecs)
+
+			var names []string
+			if selected != nil {
+				names = make([]string, 0, len(selected))
+			}
+			for _, ec := range selected {
+				names = append(names, ec.GetName())
+			}
+
+			if diff := cmp.Diff(tc.want.names, names); diff != "" {
+				t.Errorf("%s\nSelectEnvironmentConfigs(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nSelectEnvironmentConfigs(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSortEnvironmentConfigs(t *testing.T) {
+	type args struct {
+		ecs  []v1alpha1.EnvironmentConfig
+		path string
+	}
+	type want struct {
+		names []string
+		err   error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"ByName": {
+			reason: "EnvironmentConfigs should be sorted by name if no field path is supplied.",
+			args: args{
+				ecs: []v1alpha1.EnvironmentConfig{
+					NewEnvironmentConfig("b", nil, nil),
+					NewEnvironmentConfig("a", nil, nil),
+				},
+			},
+			want: want{
+				names: []string{"a", "b"},
+			},
+		},
+		"IntegersAndFloats": {
+			reason: "Integers and floats should be compared as numbers.",
+			args: args{
+				ecs: []v1alpha1.EnvironmentConfig{
+					NewEnvironmentConfig("two", nil, map[string]string{"priority": "2"}),
+					NewEnvironmentConfig("one-and-a-half", nil, map[string]string{"priority": "1.5"}),
+					NewEnvironmentConfig("one", nil, map[string]string{"priority": "1"}),
+				},
+				path: "data.priority",
+			},
+			want: want{
+				names: []string{"one", "one-and-a-half", "two"},
+			},
+		},
+		"StringsAndNumbers": {
+			reason: "We should return an error if some values are strings and some are numbers.",
+			args: args{
+				ecs: []v1alpha1.EnvironmentConfig{
+					NewEnvironmentConfig("a", nil, map[string]string{"priority": `"high"`}),
+					NewEnvironmentConfig("b", nil, map[string]string{"priority": "1"}),
+				},
+				path: "data.priority",
+			},
+			want: want{
+				names: []string{"a", "b"},
+				err:   cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := sortEnvironmentConfigs(tc.args.ecs, tc.args.path)
+
+			names := make([]string, 0, len(tc.args.ecs))
+			for _, ec := range tc.args.ecs {
+				names = append(names, ec.GetName())
+			}
+			if diff := cmp.Diff(tc.want.names, names); diff != "" {
+				t.Errorf("%s\nsortEnvironmentConfigs(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nsortEnvironmentConfigs(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestWithEnvironment(t *testing.T) {
+	ecs := []v1alpha1.EnvironmentConfig{
+		NewEnvironmentConfig("a", nil, map[string]string{"region": `"us-east-2"`, "tags": `{"team":"a","cost":"1"}`}),
+		NewEnvironmentConfig("b", nil, map[string]string{"tags": `{"team":"b"}`}),
+	}
+
+	type args struct {
+		fctx *structpb.Struct
+		comp *apiextensionsv1.Composition
+	}
+	type want struct {
+		fctx *structpb.Struct
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoEnvironment": {
+			reason: "The context should be unchanged if the Composition has no environment.",
+			args: args{
+				fctx: xtest.MustStructJSON(`{"foo":"bar"}`),
+				comp: &apiextensionsv1.Composition{},
+			},
+			want: want{
+				fctx: xtest.MustStructJSON(`{"foo":"bar"}`),
+			},
+		},
+		"AlreadyInContext": {
+			reason: "An environment already in the context should be unchanged.",
+			args: args{
+				fctx: xtest.MustStructJSON(`{"apiextensions.crossplane.io/environment":{"cool":true}}`),
+				comp: &apiextensionsv1.Composition{
+					Spec: apiextensionsv1.CompositionSpec{
+						Environment: &apiextensionsv1.EnvironmentConfiguration{
+							EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{
+								{Type: apiextensionsv1.EnvironmentSourceTypeReference, Ref: &apiextensionsv1.EnvironmentSourceReference{Name: "a"}},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				fctx: xtest.MustStructJSON(`{"apiextensions.crossplane.io/environment":{"cool":true}}`),
+			},
+		},
+		"MergeEnvironment": {
+			reason: "Default data and selected EnvironmentConfigs should be merged in order and injected into the context.",
+			args: args{
+				fctx: xtest.MustStructJSON(`{"foo":"bar"}`),
+				comp: &apiextensionsv1.Composition{
+					Spec: apiextensionsv1.CompositionSpec{
+						Environment: &apiextensionsv1.EnvironmentConfiguration{
+							DefaultData: map[string]extv1.JSON{
+								"region": {Raw: []byte(`"eu-west-1"`)},
+								"size":   {Raw: []byte(`"large"`)},
+							},
+							EnvironmentConfigs: []apiextensionsv1.EnvironmentSource{
+								{Type: apiextensionsv1.EnvironmentSourceTypeReference, Ref: &apiextensionsv1.EnvironmentSourceReference{Name: "a"}},
+								{Type: apiextensionsv1.EnvironmentSourceTypeReference, Ref: &apiextensionsv1.EnvironmentSourceReference{Name: "b"}},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				fctx: xtest.MustStructJSON(`{
+					"foo": "bar",
+					"apiextensions.crossplane.io/environment": {
+						"apiVersion": "internal.crossplane.io/v1alpha1",
+						"kind": "Environment",
+						"region": "us-east-2",
+						"size": "large",
+						"tags": {"team": "b", "cost": "1"}
+					}
+				}`),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fctx, err := WithEnvironment(tc.args.fctx, composite.New(), tc.args.comp, ecs)
+
+			if diff := cmp.Diff(tc.want.fctx, fctx, protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nWithEnvironment(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nWithEnvironment(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+)
+
+// The characters used to generate names, per the API server's name generator.
+const nameAlphabet = "bcdfghjklmnpqrstvwxz2456789"
+
+// A FakeProvider is an Observer that simulates a provider successfully
+// creating and reconciling composed resources. It fills in what a provider
+// (and the API server) would:
+//
+//   - A name, for composed resources that only have a generateName.
+//   - A crossplane.io/external-name annotation, defaulting to the name.
+//   - Ready and Synced status conditions.
+//   - A status.atProvider, copied from spec.forProvider.
+//
+// It also fills in the status of any status template matching the composed
+// resource's apiVersion and kind. A FakeProvider never overwrites anything a
+// composed resource already has, so it can be used to complete hand-written
+// observed composed resources. Template values take precedence over
+// spec.forProvider.
+type FakeProvider struct {
+	templates map[schema.GroupVersionKind]map[string]any
+	now       metav1.Time
+}
+
+// NewFakeProvider returns a FakeProvider that uses the supplied status
+// templates. Only the apiVersion, kind and status of each template are used.
+func NewFakeProvider(templates []composed.Unstructured) *FakeProvider {
+	fp := &FakeProvider{
+		templates: make(map[schema.GroupVersionKind]map[string]any, len(templates)),
+
+		// Conditions transition at the same time in every observation, so
+		// that observing the same desired state always produces the same
+		// observed state.
+		now: metav1.NewTime(time.Now().Truncate(time.Second)),
+	}
+	for _, t := range templates {
+		if s, ok := t.Object["status"].(map[string]any); ok {
+			fp.templates[t.GroupVersionKind()] = s
+		}
+	}
+	return fp
+}
+
+// Observe the supplied desired composed resources.
+func (fp *FakeProvider) Observe(_ context.Context, desired []composed.Unstructured) ([]composed.Unstructured, error) {
+	observed := make([]composed.Unstructured, 0, len(desired))
+	for i := range desired {
+		cd := &composed.Unstructured{Unstructured: *desired[i].Unstructured.DeepCopy()}
+
+		if cd.GetName() == "" && cd.GetGenerateName() != "" {
+			cd.SetName(GenerateName(cd.GetGenerateName(), cd.GetAnnotations()[AnnotationKeyCompositionResourceName]))
+		}
+		if meta.GetExternalName(cd) == "" && cd.GetName() != "" {
+			meta.SetExternalName(cd, cd.GetName())
+		}
+
+		s, ok := cd.Object["status"].(map[string]any)
+		if !ok {
+			s = map[string]any{}
+		}
+		if t, ok := fp.templates[cd.GroupVersionKind()]; ok {
+			fill(s, t)
+		}
+		fill(s, fp.status(cd))
+		cd.Object["status"] = s
+
+		observed = append(observed, *cd)
+	}
+	return observed, nil
+}
+
+// status returns the status the FakeProvider gives the supplied resource.
+func (fp *FakeProvider) status(cd *composed.Unstructured) map[string]any {
+	scratch := composed.New()
+	ready, synced := xpv1.Available(), xpv1.ReconcileSuccess()
+	ready.LastTransitionTime, synced.LastTransitionTime = fp.now, fp.now
+	scratch.SetConditions(ready, synced)
+
+	s, _ := scratch.Object["status"].(map[string]any)
+	if v, ok, _ := unstructured.NestedFieldNoCopy(cd.Object, "spec", "forProvider"); ok {
+		s["atProvider"] = runtime.DeepCopyJSONValue(v)
+	}
+	return s
+}
+
+// GenerateName returns a name with the supplied prefix, like the API server
+// would for a resource with that generateName. Unlike the API server the name
+// is derived from the supplied seed, so that it's the same every time.
+func GenerateName(prefix, seed string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(prefix + seed))
+	n := h.Sum64()
+
+	suffix := make([]byte, 5)
+	for i := range suffix {
+		suffix[i] = nameAlphabet[n%uint64(len(nameAlphabet))]
+		n /= uint64(len(nameAlphabet))
+	}
+	return prefix + string(suffix)
+}
+
+// fill sets any fields of dst that are unset to the value of the corresponding
+// field of src. Objects are filled recursively.
+func fill(dst, src map[string]any) {
+	for k, sv := range src {
+		dv, ok := dst[k]
+		if !ok {
+			dst[k] = runtime.DeepCopyJSONValue(sv)
+			continue
+		}
+		dm, dok := dv.(map[string]any)
+		sm, sok := sv.(map[string]any)
+		if dok && sok {
+			fill(dm, sm)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package render
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+)
+
+func TestFakeProviderObserve(t *testing.T) {
+	now := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	conditions := []any{
+		map[string]any{"type": "Ready", "status": "True", "reason": "Available", "lastTransitionTime": now},
+		map[string]any{"type": "Synced", "status": "True", "reason": "ReconcileSuccess", "lastTransitionTime": now},
+	}
+
+	type args struct {
+		templates []composed.Unstructured
+		desired   map[string]any
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   map[string]any
+	}{
+		"Synthesize": {
+			reason: "A name, external name, conditions and atProvider should be filled in.",
+			args: args{
+				desired: map[string]any{
+					"apiVersion": "example.org/v1",
+					"kind":       "Bucket",
+					"metadata": map[string]any{
+						"generateName": "test-",
+						"annotations":  map[string]any{AnnotationKeyCompositionResourceName: "bucket"},
+					},
+					"spec": map[string]any{
+						"forProvider": map[string]any{"region": "us-east-2"},
+					},
+				},
+			},
+			want: map[string]any{
+				"apiVersion": "example.org/v1",
+				"kind":       "Bucket",
+				"metadata": map[string]any{
+					"name":         GenerateName("test-", "bucket"),
+					"generateName": "test-",
+					"annotations": map[string]any{
+						AnnotationKeyCompositionResourceName: "bucket",
+						"crossplane.io/external-name":        GenerateName("test-", "bucket"),
+					},
+				},
+				"spec": map[string]any{
+					"forProvider": map[string]any{"region": "us-east-2"},
+				},
+				"status": map[string]any{
+					"atProvider": map[string]any{"region": "us-east-2"},
+					"conditions": conditions,
+				},
+			},
+		},
+		"PreserveExisting": {
+			reason: "Existing names, annotations and status should not be overwritten.",
+			args: args{
+				desired: map[string]any{
+					"apiVersion": "example.org/v1",
+					"kind":       "Bucket",
+					"metadata": map[string]any{
+						"name":         "cool-bucket",
+						"generateName": "test-",
+						"annotations":  map[string]any{"crossplane.io/external-name": "cool-external-bucket"},
+					},
+					"status": map[string]any{
+						"conditions": []any{map[string]any{"type": "Ready", "status": "False"}},
+					},
+				},
+			},
+			want: map[string]any{
+				"apiVersion": "example.org/v1",
+				"kind":       "Bucket",
+				"metadata": map[string]any{
+					"name":         "cool-bucket",
+					"generateName": "test-",
+					"annotations":  map[string]any{"crossplane.io/external-name": "cool-external-bucket"},
+				},
+				"status": map[string]any{
+					"conditions": []any{map[string]any{"type": "Ready", "status": "False"}},
+				},
+			},
+		},
+		"Template": {
+			reason: "Status templates matching the composed resource's kind should take precedence over spec.forProvider.",
+			args: args{
+				templates: []composed.Unstructured{
+					{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "example.org/v1",
+						"kind":       "Bucket",
+						"status": map[string]any{
+							"atProvider": map[string]any{"arn": "arn:aws:s3:::cool-bucket", "region": "eu-west-1"},
+						},
+					}}},
+					{Unstructured: unstructured.Unstructured{Object: map[string]any{
+						"apiVersion": "example.org/v1",
+						"kind":       "Other",
+						"status":     map[string]any{"other": true},
+					}}},
+				},
+				desired: map[string]any{
+					"apiVersion": "example.org/v1",
+					"kind":       "Bucket",
+					"metadata":   map[string]any{"name": "cool-bucket"},
+					"spec": map[string]any{
+						"forProvider": map[string]any{"region": "us-east-2", "acl": "private"},
+					},
+				},
+			},
+			want: map[string]any{
+				"apiVersion": "example.org/v1",
+				"kind":       "Bucket",
+				"metadata": map[string]any{
+					"name":        "cool-bucket",
+					"annotations": map[string]any{"crossplane.io/external-name": "cool-bucket"},
+				},
+				"spec": map[string]any{
+					"forProvider": map[string]any{"region": "us-east-2", "acl": "private"},
+				},
+				"status": map[string]any{
+					"atProvider": map[string]any{"arn": "arn:aws:s3:::cool-bucket", "region": "eu-west-1", "acl": "private"},
+					"conditions": conditions,
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fp := NewFakeProvider(tc.args.templates)
+			fp.now.Time = time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+
+			got, err := fp.Observe(context.Background(), []composed.Unstructured{{Unstructured: unstructured.Unstructured{Object: tc.args.desired}}})
+			if err != nil {
+				t.Fatalf("Observe(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got[0].Object); diff != "" {
+				t.Errorf("%s\nObserve(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package render
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+// SharedInputs are the inputs used to render XRs that don't depend on which XR
+// is rendered. Use InputsFor to get the Inputs needed to render an XR, like
+// Crossplane would. Load them once to render many XRs.
+type SharedInputs struct {
+	// XRD that defines the XRs. Optional. Its OpenAPI schema is used to
+	// default and validate each XR, like the API server would.
+	XRD *apiextensionsv1.CompositeResourceDefinition
+
+	// Compositions from which each XR's Composition is selected.
+	Compositions []apiextensionsv1.Composition
+
+	// CompositionRevisions from which each XR's CompositionRevision is
+	// selected. Optional. A Composition that only has revisions can still be
+	// selected.
+	CompositionRevisions []apiextensionsv1.CompositionRevision
+
+	// Functions the Compositions may use.
+	Functions []pkgv1beta1.Function
+
+	// EnvironmentConfigs the Compositions may select.
+	EnvironmentConfigs []v1alpha1.EnvironmentConfig
+
+	// RuntimeConfigs configure how to run the Functions. They take precedence
+	// over Function annotations. Later configs take precedence.
+	RuntimeConfigs []*functions.RuntimeConfig
+
+	// Revision number of the selected Composition to use. Zero means the
+	// revision each XR would use.
+	Revision int64
+}
+
+// A Selection is the Composition, and CompositionRevision if any, selected for
+// an XR, and why they were selected.
+type Selection struct {
+	Composition CompositionSelection
+
+	// Revision is nil if no CompositionRevision was selected.
+	Revision *CompositionRevisionSelection
+}
+
+// InputsFor returns the Inputs needed to render the supplied XR, with the
+// supplied observed composed resources. Like Crossplane it defaults the XR
+// using the XRD, if any, then selects its Composition and CompositionRevision
+// and records them in the XR's spec. A Resources mode Composition is converted
+// using AsPipeline, and the RuntimeConfigs are applied to the Functions. It also
+// returns what was selected, and why.
+func (s *SharedInputs) InputsFor(xr *composite.Unstructured, observed []composed.Unstructured) (Inputs, Selection, error) {
+	if s.XRD != nil {
+		if err := DefaultCompositeResource(xr, s.XRD); err != nil {
+			return Inputs{}, Selection{}, errors.Wrap(err, "cannot default composite resource")
+		}
+	}
+
+	sel, err := s.selectComposition(xr)
+	if err != nil {
+		return Inputs{}, Selection{}, err
+	}
+
+	comp := sel.Composition.Composition
+	if sel.Revision != nil {
+		comp = CompositionFromRevision(sel.Revision.Revision)
+	}
+
+	in, err := AsPipeline(Inputs{
+		CompositeResource:  xr,
+		Composition:        comp,
+		Functions:          s.Functions,
+		ObservedResources:  observed,
+		EnvironmentConfigs: s.EnvironmentConfigs,
+	})
+	if err != nil {
+		return Inputs{}, sel, err
+	}
+	in.Functions = functions.ApplyRuntimeConfigs(in.Functions, s.RuntimeConfigs...)
+	return in, sel, nil
+}
+
+// selectComposition selects the Composition to use for the supplied XR. If
+// there are CompositionRevisions, or a revision number was requested, it also
+// selects the CompositionRevision to use.
+func (s *SharedInputs) selectComposition(xr *composite.Unstructured) (Selection, error) {
+	cs, err := SelectComposition(xr, s.XRD, WithRevisionCompositions(s.Compositions, s.CompositionRevisions))
+	if err != nil {
+		return Selection{}, errors.Wrap(err, "cannot select a Composition")
+	}
+
+	// Crossplane records the Composition it selected.
+	xr.SetCompositionReference(&corev1.ObjectReference{Name: cs.Composition.GetName()})
+
+	if len(s.CompositionRevisions) == 0 && s.Revision == 0 {
+		return Selection{Composition: cs}, nil
+	}
+
+	rs, err := SelectCompositionRevision(xr, cs.Composition.GetName(), s.CompositionRevisions, s.Revision)
+	if err != nil {
+		return Selection{Composition: cs}, errors.Wrap(err, "cannot select a CompositionRevision")
+	}
+
+	// Crossplane records the CompositionRevision it selected, too.
+	xr.SetCompositionRevisionReference(&corev1.ObjectReference{Name: rs.Revision.GetName()})
+	return Selection{Composition: cs, Revision: &rs}, nil
+}
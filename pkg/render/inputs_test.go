@@ -0,0 +1,179 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+func TestInputsFor(t *testing.T) {
+	comp := func(name string, mode *apiextensionsv1.CompositionMode) apiextensionsv1.Composition {
+		return apiextensionsv1.Composition{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: apiextensionsv1.CompositionSpec{
+				CompositeTypeRef: apiextensionsv1.TypeReference{APIVersion: "example.org/v1", Kind: "XBucket"},
+				Mode:             mode,
+				Resources: []apiextensionsv1.ComposedTemplate{{
+					Name: ptr.To("bucket"),
+				}},
+			},
+		}
+	}
+	xr := func() *composite.Unstructured {
+		xr := composite.New()
+		xr.SetAPIVersion("example.org/v1")
+		xr.SetKind("XBucket")
+		xr.SetName("cool-xr")
+		return xr
+	}
+	fns := []pkgv1beta1.Function{{ObjectMeta: metav1.ObjectMeta{Name: "function-cool"}}}
+	rc := &functions.RuntimeConfig{Functions: []functions.FunctionRuntimeConfig{{
+		Name:    DefaultPatchAndTransformFunctionName,
+		Runtime: functions.AnnotationValueRuntimeDevelopment,
+	}}}
+
+	type want struct {
+		composition    string
+		mode           apiextensionsv1.CompositionMode
+		functions      []string
+		runtime        string
+		compositionRef *corev1.ObjectReference
+		revisionRef    *corev1.ObjectReference
+		selected       string
+		revision       string
+		err            error
+	}
+	cases := map[string]struct {
+		reason string
+		shared *SharedInputs
+		want   want
+	}{
+		"PipelineMode": {
+			reason: "The only Composition for the XR's type should be selected and referenced by the XR, and used as is.",
+			shared: &SharedInputs{
+				Compositions: []apiextensionsv1.Composition{comp("cool-comp", ptr.To(apiextensionsv1.CompositionModePipeline))},
+				Functions:    fns,
+			},
+			want: want{
+				composition:    "cool-comp",
+				mode:           apiextensionsv1.CompositionModePipeline,
+				functions:      []string{"function-cool"},
+				compositionRef: &corev1.ObjectReference{Name: "cool-comp"},
+				selected:       "cool-comp",
+			},
+		},
+		"ResourcesMode": {
+			reason: "A Composition with no mode should be converted to use function-patch-and-transform, then RuntimeConfigs should apply to the Functions it uses.",
+			shared: &SharedInputs{
+				Compositions:   []apiextensionsv1.Composition{comp("cool-comp", nil)},
+				Functions:      fns,
+				RuntimeConfigs: []*functions.RuntimeConfig{rc},
+			},
+			want: want{
+				composition:    "cool-comp",
+				mode:           apiextensionsv1.CompositionModePipeline,
+				functions:      []string{"function-cool", DefaultPatchAndTransformFunctionName},
+				runtime:        string(functions.AnnotationValueRuntimeDevelopment),
+				compositionRef: &corev1.ObjectReference{Name: "cool-comp"},
+				selected:       "cool-comp",
+			},
+		},
+		"Revision": {
+			reason: "The requested revision of the selected Composition should be selected and referenced by the XR, and used.",
+			shared: &SharedInputs{
+				Compositions: []apiextensionsv1.Composition{comp("aws", ptr.To(apiextensionsv1.CompositionModePipeline))},
+				CompositionRevisions: []apiextensionsv1.CompositionRevision{
+					NewCompositionRevision("aws", 1, nil),
+					NewCompositionRevision("aws", 2, nil),
+				},
+				Functions: fns,
+				Revision:  1,
+			},
+			want: want{
+				composition:    "aws",
+				mode:           apiextensionsv1.CompositionModePipeline,
+				functions:      []string{"function-cool"},
+				compositionRef: &corev1.ObjectReference{Name: "aws"},
+				revisionRef:    &corev1.ObjectReference{Name: "aws-rev1"},
+				selected:       "aws",
+				revision:       "aws-rev1",
+			},
+		},
+		"OnlyRevisions": {
+			reason: "A Composition should be selected even if we only have its revisions.",
+			shared: &SharedInputs{
+				CompositionRevisions: []apiextensionsv1.CompositionRevision{
+					NewCompositionRevision("aws", 1, nil),
+					NewCompositionRevision("aws", 2, nil),
+				},
+				Functions: fns,
+			},
+			want: want{
+				composition:    "aws",
+				mode:           apiextensionsv1.CompositionModePipeline,
+				functions:      []string{"function-cool"},
+				compositionRef: &corev1.ObjectReference{Name: "aws"},
+				revisionRef:    &corev1.ObjectReference{Name: "aws-rev2"},
+				selected:       "aws",
+				revision:       "aws-rev2",
+			},
+		},
+		"NoComposition": {
+			reason: "We should return an error if no Composition is for the XR's type.",
+			shared: &SharedInputs{Functions: fns},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			x := xr()
+			in, sel, err := tc.shared.InputsFor(x, nil)
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("\n%s\nInputsFor(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+
+			got := want{
+				composition:    in.Composition.GetName(),
+				mode:           ptr.Deref(in.Composition.Spec.Mode, ""),
+				compositionRef: x.GetCompositionReference(),
+				revisionRef:    x.GetCompositionRevisionReference(),
+				selected:       sel.Composition.Composition.GetName(),
+			}
+			for _, fn := range in.Functions {
+				got.functions = append(got.functions, fn.GetName())
+				if fn.GetName() == DefaultPatchAndTransformFunctionName {
+					got.runtime = fn.GetAnnotations()[functions.AnnotationKeyRuntime]
+				}
+			}
+			if sel.Revision != nil {
+				got.revision = sel.Revision.Revision.GetName()
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}), cmpopts.IgnoreFields(want{}, "err")); diff != "" {
+				t.Errorf("\n%s\nInputsFor(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if in.CompositeResource != x {
+				t.Errorf("\n%s\nInputsFor(...): want the supplied XR", tc.reason)
+			}
+			if len(tc.shared.Functions) != len(fns) {
+				t.Errorf("\n%s\nInputsFor(...): shared Functions were modified", tc.reason)
+			}
+		})
+	}
+}
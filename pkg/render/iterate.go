@@ -0,0 +1,197 @@
+package render
+
+import (
+	"context"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+// DefaultMaxIterations is the default maximum number of iterations to simulate
+// when iterating until the desired state converges.
+const DefaultMaxIterations = 10
+
+// ErrNotConverged is returned when the desired state doesn't converge within
+// the maximum number of iterations.
+var ErrNotConverged = errors.New("desired state did not converge")
+
+// An Observer returns the observed state of the supplied desired composed
+// resources, as if they'd been created or updated and then reconciled by
+// their provider.
+type Observer interface {
+	Observe(ctx context.Context, desired []composed.Unstructured) ([]composed.Unstructured, error)
+}
+
+// An ObserverFn is a function that satisfies Observer.
+type ObserverFn func(ctx context.Context, desired []composed.Unstructured) ([]composed.Unstructured, error)
+
+// Observe the supplied desired composed resources.
+func (fn ObserverFn) Observe(ctx context.Context, desired []composed.Unstructured) ([]composed.Unstructured, error) {
+	return fn(ctx, desired)
+}
+
+// ObserverChain is an Observer that observes desired composed resources using
+// each of its Observers in order. Each Observer observes what the last
+// observed.
+type ObserverChain []Observer
+
+// Observe the supplied desired composed resources.
+func (c ObserverChain) Observe(ctx context.Context, desired []composed.Unstructured) ([]composed.Unstructured, error) {
+	observed := desired
+	for _, o := range c {
+		var err error
+		if observed, err = o.Observe(ctx, observed); err != nil {
+			return nil, err
+		}
+	}
+	return observed, nil
+}
+
+// StatusFixtures is an Observer that observes desired composed resources
+// exactly as they were desired, except that their status (and name, if any) is
+// taken from a matching fixture. Fixtures match desired composed resources by
+// their composition resource name annotation.
+type StatusFixtures struct {
+	fixtures map[string]composed.Unstructured
+}
+
+// NewStatusFixtures returns an Observer that uses the supplied fixtures.
+func NewStatusFixtures(fixtures []composed.Unstructured) *StatusFixtures {
+	return &StatusFixtures{fixtures: ObservedByName(fixtures)}
+}
+
+// Observe the supplied desired composed resources.
+func (f *StatusFixtures) Observe(_ context.Context, desired []composed.Unstructured) ([]composed.Unstructured, error) {
+	observed := make([]composed.Unstructured, 0, len(desired))
+	for i := range desired {
+		cd := &composed.Unstructured{Unstructured: *desired[i].Unstructured.DeepCopy()}
+		name := cd.GetAnnotations()[AnnotationKeyCompositionResourceName]
+
+		fx, ok := f.fixtures[name]
+		if !ok {
+			observed = append(observed, *cd)
+			continue
+		}
+		if n := fx.GetName(); n != "" {
+			cd.SetName(n)
+		}
+		if s, ok := fx.Object["status"]; ok {
+			cd.Object["status"] = runtime.DeepCopyJSONValue(s)
+		}
+		observed = append(observed, *cd)
+	}
+	return observed, nil
+}
+
+// RenderIterations simulates up to the supplied number of reconciles of the
+// supplied XR. The pipeline is run once per iteration. The desired composed
+// resources produced by each iteration are observed using the supplied
+// Observer, and become the observed composed resources of the next iteration.
+// The desired status of the XR also becomes the observed status of the next
+// iteration's XR. Like Crossplane, only the first iteration starts with the
+// supplied desired state and context - later iterations start with none. The
+// whole pipeline must be run, because part of it can't show whether rendering
+// converges. A Resources mode Composition is rendered using
+// function-patch-and-transform - see AsPipeline.
+//
+// If untilStable is true iteration stops early once an iteration produces the
+// same desired state as the one before it. ErrNotConverged is returned if that
+// doesn't happen within the supplied number of iterations.
+//
+// The outputs of each iteration are returned, even when an error is. If an
+// iteration fails its (partial) outputs are the last returned.
+func RenderIterations(ctx context.Context, in Inputs, iterations int, untilStable bool, obs Observer, o ...Option) ([]Outputs, error) {
+	if in.FromStep != "" || in.UntilStep != "" {
+		return nil, errors.New("cannot simulate reconciles using part of the pipeline")
+	}
+	in, err := AsPipeline(in)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Functions are started once, and used for every iteration.
+	fns, err := functions.Referenced(in.Functions, in.Composition.Spec.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	rf, err := startFunctions(ctx, fns, o...)
+	if err != nil {
+		return nil, err
+	}
+	defer rf.Cleanup()
+
+	outs := make([]Outputs, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		out, err := RunPipeline(ctx, rf, in)
+		outs = append(outs, out)
+		if err != nil {
+			return outs, errors.Wrapf(err, "iteration %d", i+1)
+		}
+
+		if untilStable && i > 0 && Converged(outs[i-1], out) {
+			return outs, nil
+		}
+
+		ors, err := obs.Observe(ctx, out.ComposedResources)
+		if err != nil {
+			return outs, errors.Wrapf(err, "iteration %d: cannot observe desired composed resources", i+1)
+		}
+		in.ObservedResources = ors
+		in.CompositeResource = ObservedComposite(in.CompositeResource, out.CompositeResource)
+
+		// Each reconcile starts with empty desired state and context.
+		in.Desired = nil
+		in.Context = nil
+	}
+
+	if untilStable {
+		return outs, errors.Wrapf(ErrNotConverged, "desired state still changed after %d iterations", iterations)
+	}
+	return outs, nil
+}
+
+// Converged returns true if the supplied outputs contain the same desired XR
+// and composed resources.
+func Converged(a, b Outputs) bool {
+	if !cmp.Equal(a.CompositeResource.UnstructuredContent(), b.CompositeResource.UnstructuredContent(), cmpopts.EquateEmpty()) {
+		return false
+	}
+	if len(a.ComposedResources) != len(b.ComposedResources) {
+		return false
+	}
+	// RenderDesired returns composed resources sorted by name.
+	for i := range a.ComposedResources {
+		if !cmp.Equal(a.ComposedResources[i].UnstructuredContent(), b.ComposedResources[i].UnstructuredContent(), cmpopts.EquateEmpty()) {
+			return false
+		}
+	}
+	return true
+}
+
+// ObservedComposite returns the observed XR as it would be after the supplied
+// desired XR was applied. Each top-level status field of the desired XR
+// replaces the corresponding field of the observed XR.
+func ObservedComposite(oxr, dxr *composite.Unstructured) *composite.Unstructured {
+	xr := &composite.Unstructured{Unstructured: *oxr.Unstructured.DeepCopy()}
+
+	ds, ok := dxr.Object["status"].(map[string]any)
+	if !ok {
+		return xr
+	}
+	s, ok := xr.Object["status"].(map[string]any)
+	if !ok {
+		s = map[string]any{}
+	}
+	for k, v := range ds {
+		s[k] = runtime.DeepCopyJSONValue(v)
+	}
+	xr.Object["status"] = s
+	return xr
+}
@@ -0,0 +1,249 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+// StagedFunctionRunner always desires composed resource 'a', in addition to the
+// desired state it's sent. It only desires
+// composed resource 'b' once 'a' is observed with a status.atProvider.id.
+type StagedFunctionRunner struct {
+	fnv1beta1.UnimplementedFunctionRunnerServiceServer
+}
+
+func (r *StagedFunctionRunner) RunFunction(_ context.Context, req *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+	d := proto.Clone(req.GetDesired()).(*fnv1beta1.State) //nolint:forcetypeassert // Clone returns the type it was passed.
+	if d.Resources == nil {
+		d.Resources = map[string]*fnv1beta1.Resource{}
+	}
+	d.Resources["a"] = &fnv1beta1.Resource{Resource: xtest.MustStructJSON(`{"apiVersion":"test.crossplane.io/v1","kind":"A"}`)}
+
+	id := req.GetObserved().GetResources()["a"].GetResource().GetFields()["status"].GetStructValue().GetFields()["atProvider"].GetStructValue().GetFields()["id"].GetStringValue()
+	if id != "" {
+		d.Resources["b"] = &fnv1beta1.Resource{Resource: xtest.MustStructJSON(`{"apiVersion":"test.crossplane.io/v1","kind":"B","spec":{"forProvider":{"aID":"` + id + `"}}}`)}
+	}
+
+	return &fnv1beta1.RunFunctionResponse{Desired: d}, nil
+}
+
+func TestRenderIterations(t *testing.T) {
+	lis := xtest.NewFunctionServer(t, &StagedFunctionRunner{})
+	defer lis.Close()
+
+	pipeline := apiextensionsv1.CompositionModePipeline
+	xr := composite.New()
+	xr.SetAPIVersion("example.org/v1")
+	xr.SetKind("XR")
+	xr.SetName("test-render")
+
+	in := Inputs{
+		CompositeResource: xr,
+		Composition: &apiextensionsv1.Composition{
+			Spec: apiextensionsv1.CompositionSpec{
+				Mode: &pipeline,
+				Pipeline: []apiextensionsv1.PipelineStep{
+					{Step: "staged", FunctionRef: apiextensionsv1.FunctionReference{Name: "function-test"}},
+				},
+			},
+		},
+		Functions: []pkgv1beta1.Function{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "function-test",
+				Annotations: map[string]string{
+					functions.AnnotationKeyRuntime:                  string(functions.AnnotationValueRuntimeDevelopment),
+					functions.AnnotationKeyRuntimeDevelopmentTarget: lis.Addr().String(),
+				},
+			},
+		}},
+	}
+
+	fixtures := []composed.Unstructured{{Unstructured: unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "test.crossplane.io/v1",
+		"kind":       "A",
+		"metadata": map[string]any{
+			"annotations": map[string]any{AnnotationKeyCompositionResourceName: "a"},
+		},
+		"status": map[string]any{
+			"atProvider": map[string]any{"id": "cool-a"},
+		},
+	}}}}
+
+	type args struct {
+		iterations  int
+		untilStable bool
+		o           Observer
+		desired     *fnv1beta1.State
+		fromStep    string
+	}
+	type want struct {
+		// The composition resource names desired by each iteration.
+		desired [][]string
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"StagedCreation": {
+			reason: "Composed resource 'b' should be desired once 'a' is observed with an ID.",
+			args: args{
+				iterations: 3,
+				o:          NewStatusFixtures(fixtures),
+			},
+			want: want{
+				desired: [][]string{{"a"}, {"a", "b"}, {"a", "b"}},
+			},
+		},
+		"UntilStable": {
+			reason: "Iteration should stop once the desired state stops changing.",
+			args: args{
+				iterations:  10,
+				untilStable: true,
+				o:           NewStatusFixtures(fixtures),
+			},
+			want: want{
+				desired: [][]string{{"a"}, {"a", "b"}, {"a", "b"}},
+			},
+		},
+		"NotConverged": {
+			reason: "ErrNotConverged should be returned if the desired state is still changing after the maximum iterations.",
+			args: args{
+				iterations:  2,
+				untilStable: true,
+				o:           NewStatusFixtures(fixtures),
+			},
+			want: want{
+				desired: [][]string{{"a"}, {"a", "b"}},
+				err:     ErrNotConverged,
+			},
+		},
+		"SeededDesiredState": {
+			reason: "Only the first iteration should start with the supplied desired state.",
+			args: args{
+				iterations: 2,
+				o:          NewStatusFixtures(fixtures),
+				desired: &fnv1beta1.State{Resources: map[string]*fnv1beta1.Resource{
+					"seed": {Resource: xtest.MustStructJSON(`{"apiVersion":"test.crossplane.io/v1","kind":"Seed"}`)},
+				}},
+			},
+			want: want{
+				desired: [][]string{{"a", "seed"}, {"a", "b"}},
+			},
+		},
+		"PartialPipeline": {
+			reason: "We should return an error if asked to run only part of the pipeline.",
+			args: args{
+				iterations: 2,
+				o:          NewStatusFixtures(fixtures),
+				fromStep:   "staged",
+			},
+			want: want{
+				desired: [][]string{},
+				err:     cmpopts.AnyError,
+			},
+		},
+		"NeverReady": {
+			reason: "Composed resource 'b' should never be desired if 'a' is never observed with an ID.",
+			args: args{
+				iterations:  10,
+				untilStable: true,
+				o:           NewStatusFixtures(nil),
+			},
+			want: want{
+				desired: [][]string{{"a"}, {"a"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			in := in
+			in.Desired = tc.args.desired
+			in.FromStep = tc.args.fromStep
+			outs, err := RenderIterations(context.Background(), in, tc.args.iterations, tc.args.untilStable, tc.args.o)
+
+			desired := make([][]string, 0, len(outs))
+			for _, out := range outs {
+				names := make([]string, 0, len(out.ComposedResources))
+				for _, cd := range out.ComposedResources {
+					names = append(names, cd.GetAnnotations()[AnnotationKeyCompositionResourceName])
+				}
+				desired = append(desired, names)
+			}
+
+			if diff := cmp.Diff(tc.want.desired, desired); diff != "" {
+				t.Errorf("%s\nRenderIterations(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nRenderIterations(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObservedComposite(t *testing.T) {
+	type args struct {
+		oxr map[string]any
+		dxr map[string]any
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   map[string]any
+	}{
+		"NoDesiredStatus": {
+			reason: "The observed XR should be unchanged if no status is desired.",
+			args: args{
+				oxr: map[string]any{"spec": map[string]any{"size": "large"}},
+				dxr: map[string]any{},
+			},
+			want: map[string]any{"spec": map[string]any{"size": "large"}},
+		},
+		"MergeStatus": {
+			reason: "Desired top-level status fields should replace observed ones.",
+			args: args{
+				oxr: map[string]any{
+					"spec":   map[string]any{"size": "large"},
+					"status": map[string]any{"a": "old", "b": "kept"},
+				},
+				dxr: map[string]any{
+					"status": map[string]any{"a": "new", "c": "added"},
+				},
+			},
+			want: map[string]any{
+				"spec":   map[string]any{"size": "large"},
+				"status": map[string]any{"a": "new", "b": "kept", "c": "added"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			oxr := &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: tc.args.oxr}}
+			dxr := &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: tc.args.dxr}}
+			got := ObservedComposite(oxr, dxr)
+
+			if diff := cmp.Diff(tc.want, got.Object); diff != "" {
+				t.Errorf("%s\nObservedComposite(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
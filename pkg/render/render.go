@@ -0,0 +1,493 @@
+// Package render renders XRs using Composition Functions, like Crossplane
+// would.
+//
+// It can be used to test a Function, for example:
+//
+//	xr, _ := load.CompositeResource("testdata/xr.yaml")
+//	comp, _ := load.Composition("testdata/composition.yaml")
+//	fns, _ := load.Functions("testdata/functions.yaml")
+//
+//	out, err := render.Render(ctx, render.Inputs{
+//		CompositeResource: xr,
+//		Composition:       comp,
+//		Functions:         fns,
+//	})
+package render
+
+import (
+	"context"
+	"sort"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/crossplane/crossplane/apis/apiextensions/v1alpha1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+// Annotations added to composed resources.
+const (
+	AnnotationKeyCompositionResourceName = "crossplane.io/composition-resource-name"
+	AnnotationKeyCompositeName           = "crossplane.io/composite"
+	AnnotationKeyClaimNamespace          = "crossplane.io/claim-namespace"
+	AnnotationKeyClaimName               = "crossplane.io/claim-name"
+)
+
+// Inputs contains all inputs to the render process.
+type Inputs struct {
+	CompositeResource *composite.Unstructured
+	Composition       *apiextensionsv1.Composition
+	Functions         []pkgv1beta1.Function
+	ObservedResources []composed.Unstructured
+
+	// EnvironmentConfigs that the Composition may select. The environment
+	// produced by merging the selected EnvironmentConfigs is supplied to the
+	// Function pipeline in its context.
+	EnvironmentConfigs []v1alpha1.EnvironmentConfig
+
+	// FromStep and UntilStep optionally restrict rendering to part of the
+	// Composition's pipeline. FromStep names the first step to run, and
+	// UntilStep the last. Only Functions referenced by the steps that are run
+	// are started.
+	FromStep  string
+	UntilStep string
+
+	// Desired state and Context sent to the first step that is run. The
+	// pipeline starts with empty desired state and context if these are nil.
+	// They're typically supplied when starting mid-pipeline using FromStep.
+	Desired *fnv1beta1.State
+	Context *structpb.Struct
+
+	// TODO(negz): Allow supplying observed XR and composed resource connection
+	// details. Maybe as Secrets? What if secret stores are in use?
+}
+
+// Outputs contains all outputs from the render process.
+type Outputs struct {
+	CompositeResource *composite.Unstructured
+	ComposedResources []composed.Unstructured
+	Results           []unstructured.Unstructured
+
+	// Deletions are the supplied observed composed resources that the Function
+	// pipeline didn't desire. Crossplane would garbage collect (i.e. delete)
+	// these. They're 'fake' KRM-like objects of kind: Deletion. Deletions are
+	// only computed when the whole pipeline was run.
+	Deletions []unstructured.Unstructured
+
+	// Context returned by the last step of the Function pipeline that was run.
+	Context *structpb.Struct
+
+	// Steps records the request sent to and the response returned by each
+	// step of the Function pipeline that was run, in order. Steps are returned
+	// even if rendering fails part way through the pipeline.
+	Steps []Step
+
+	// TODO(negz): Allow returning desired XR connection details. Maybe as a
+	// Secret? Should we honor writeConnectionSecretToRef? What if secret stores
+	// are in use?
+
+	// TODO(negz): Allow returning desired XR readiness? Or perhaps just set the
+	// ready status condition on the XR if all supplied observed resources
+	// appear ready?
+}
+
+// A Step records a step of the Function pipeline.
+type Step struct {
+	// Step is the name of the pipeline step.
+	Step string
+
+	// Index of the step in the Composition's pipeline, starting at 0. This
+	// is the step's position in the full pipeline, even when only part of
+	// the pipeline was run.
+	Index int
+
+	// Request sent to the step's Function.
+	Request *fnv1beta1.RunFunctionRequest
+
+	// Response returned by the step's Function. Nil if the step failed.
+	Response *fnv1beta1.RunFunctionResponse
+}
+
+// A StepRunner runs a pipeline step.
+type StepRunner interface {
+	RunFunction(ctx context.Context, fn apiextensionsv1.PipelineStep, req *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error)
+}
+
+// A StepRunnerFn is a function that runs a pipeline step.
+type StepRunnerFn func(ctx context.Context, fn apiextensionsv1.PipelineStep, req *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error)
+
+// RunFunction runs the supplied pipeline step.
+func (f StepRunnerFn) RunFunction(ctx context.Context, fn apiextensionsv1.PipelineStep, req *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+	return f(ctx, fn, req)
+}
+
+// An Option configures how XRs are rendered.
+type Option func(o *options)
+
+type options struct {
+	log    logging.Logger
+	fns    []functions.Option
+	runner StepRunner
+}
+
+// WithLogger configures how rendering logs, including how the Functions it
+// starts log. Rendering doesn't log by default.
+func WithLogger(l logging.Logger) Option {
+	return func(o *options) {
+		o.log = l
+	}
+}
+
+// WithFunctionOptions configures how the Functions used to render XRs are
+// started, for example to add a Runtime using functions.WithRuntime.
+func WithFunctionOptions(fo ...functions.Option) Option {
+	return func(o *options) {
+		o.fns = append(o.fns, fo...)
+	}
+}
+
+// WithStepRunner configures Render to run pipeline steps using the supplied
+// StepRunner, rather than starting the Functions the pipeline references. Use
+// it to share Functions started by StartSharedFunctions between renders.
+func WithStepRunner(r StepRunner) Option {
+	return func(o *options) {
+		o.runner = r
+	}
+}
+
+// startFunctions starts the supplied Functions, configured per the supplied
+// options.
+func startFunctions(ctx context.Context, fns []pkgv1beta1.Function, o ...Option) (*functions.Running, error) {
+	opts := &options{}
+	for _, fn := range o {
+		fn(opts)
+	}
+	if opts.log == nil {
+		// Let the Functions write errors they can't otherwise report to
+		// stderr, rather than to a logger that discards them.
+		return functions.Start(ctx, fns, opts.fns...)
+	}
+	opts.log.Debug("Starting Functions", "count", len(fns))
+	return functions.Start(ctx, fns, append([]functions.Option{functions.WithLogger(opts.log)}, opts.fns...)...)
+}
+
+// Render the desired XR and composed resources given the supplied inputs. A
+// Resources mode Composition is rendered using function-patch-and-transform -
+// see AsPipeline.
+func Render(ctx context.Context, in Inputs, o ...Option) (Outputs, error) {
+	in, err := AsPipeline(in)
+	if err != nil {
+		return Outputs{}, err
+	}
+
+	opts := &options{}
+	for _, fn := range o {
+		fn(opts)
+	}
+	if opts.runner != nil {
+		return RunPipeline(ctx, opts.runner, in)
+	}
+
+	pipeline, err := PipelineRange(in.Composition.Spec.Pipeline, in.FromStep, in.UntilStep)
+	if err != nil {
+		return Outputs{}, err
+	}
+
+	// Run the Functions referenced by our pipeline.
+	fns, err := functions.Referenced(in.Functions, pipeline)
+	if err != nil {
+		return Outputs{}, err
+	}
+	rf, err := startFunctions(ctx, fns, o...)
+	if err != nil {
+		return Outputs{}, err
+	}
+	defer rf.Cleanup()
+
+	return RunPipeline(ctx, rf, in)
+}
+
+// RunPipeline renders the desired XR and composed resources given the supplied
+// inputs, using the supplied StepRunner to run each step of the pipeline. The
+// Functions referenced by the pipeline must already be running. The Composition
+// must be in Pipeline mode - see AsPipeline.
+func RunPipeline(ctx context.Context, r StepRunner, in Inputs) (Outputs, error) {
+	if m := in.Composition.Spec.Mode; m == nil || *m != apiextensionsv1.CompositionModePipeline {
+		return Outputs{}, errors.Errorf("Composition %q must use spec.mode: Pipeline", in.Composition.GetName())
+	}
+
+	pipeline, err := PipelineRange(in.Composition.Spec.Pipeline, in.FromStep, in.UntilStep)
+	if err != nil {
+		return Outputs{}, err
+	}
+
+	// TODO(negz): Support passing in optional observed connection details for
+	// both the XR and composed resources.
+	o, err := AsState(in.CompositeResource, ObservedByName(in.ObservedResources))
+	if err != nil {
+		return Outputs{}, errors.Wrap(err, "cannot build observed composite and composed resources for RunFunctionRequest")
+	}
+
+	// The Function pipeline starts with empty desired state and context,
+	// unless we were asked to start with something else.
+	d := &fnv1beta1.State{}
+	if in.Desired != nil {
+		d = in.Desired
+	}
+	fctx, err := WithEnvironment(in.Context, in.CompositeResource, in.Composition, in.EnvironmentConfigs)
+	if err != nil {
+		return Outputs{}, err
+	}
+
+	results := make([]unstructured.Unstructured, 0)
+	steps := make([]Step, 0, len(pipeline))
+
+	// Steps are numbered by their position in the full pipeline.
+	offset := 0
+	if in.FromStep != "" {
+		offset = stepIndex(in.Composition.Spec.Pipeline, in.FromStep)
+	}
+
+	// Run any Composition Functions in the pipeline. Each Function may mutate
+	// the desired state and context returned by the last, and each Function
+	// may produce results.
+	for i, fn := range pipeline {
+		req, err := NewRunFunctionRequest(fn, o, d, fctx)
+		if err != nil {
+			return Outputs{Steps: steps}, err
+		}
+
+		rsp, err := r.RunFunction(ctx, fn, req)
+		if err != nil {
+			// Record the request that failed - it's probably the step the
+			// caller most wants to see.
+			steps = append(steps, Step{Step: fn.Step, Index: offset + i, Request: req})
+			return Outputs{Steps: steps}, err
+		}
+		steps = append(steps, Step{Step: fn.Step, Index: offset + i, Request: req, Response: rsp})
+
+		d = rsp.GetDesired()
+		fctx = rsp.GetContext()
+
+		rs, err := StepResults(fn.Step, rsp)
+		if err != nil {
+			return Outputs{Steps: steps}, err
+		}
+		results = append(results, rs...)
+	}
+
+	out, err := RenderDesired(in.CompositeResource, in.ObservedResources, d)
+	if err != nil {
+		return Outputs{Steps: steps}, err
+	}
+
+	// We can't tell what Crossplane would delete if we only ran part of the
+	// pipeline. The steps we didn't run may desire resources the steps we ran
+	// didn't.
+	if len(pipeline) < len(in.Composition.Spec.Pipeline) {
+		out.Deletions = nil
+	}
+	out.Results = results
+	out.Context = fctx
+	out.Steps = steps
+	return out, nil
+}
+
+// ObservedByName returns the supplied observed composed resources keyed by
+// their composition resource name annotation.
+func ObservedByName(ors []composed.Unstructured) map[string]composed.Unstructured {
+	observed := map[string]composed.Unstructured{}
+	for _, cd := range ors {
+		name := cd.GetAnnotations()[AnnotationKeyCompositionResourceName]
+		observed[name] = cd
+	}
+	return observed
+}
+
+// NewRunFunctionRequest returns the request to send to the supplied pipeline
+// step, given the observed state, and the desired state and context returned
+// by the previous step.
+func NewRunFunctionRequest(fn apiextensionsv1.PipelineStep, o, d *fnv1beta1.State, fctx *structpb.Struct) (*fnv1beta1.RunFunctionRequest, error) {
+	req := &fnv1beta1.RunFunctionRequest{Observed: o, Desired: d, Context: fctx}
+
+	if fn.Input != nil {
+		in := &structpb.Struct{}
+		if err := in.UnmarshalJSON(fn.Input.Raw); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal input for Composition pipeline step %q", fn.Step)
+		}
+		req.Input = in
+	}
+
+	return req, nil
+}
+
+// StepResults returns the Results the supplied pipeline step returned, as
+// 'fake' KRM-like objects of kind: Result. It returns an error if the step
+// returned a fatal Result; these stop the Composition process.
+func StepResults(step string, rsp *fnv1beta1.RunFunctionResponse) ([]unstructured.Unstructured, error) {
+	results := make([]unstructured.Unstructured, 0, len(rsp.GetResults()))
+	for _, rs := range rsp.GetResults() {
+		switch rs.Severity { //nolint:exhaustive // We intentionally have a broad default case.
+		case fnv1beta1.Severity_SEVERITY_FATAL:
+			return nil, errors.Errorf("pipeline step %q returned a fatal result: %s", step, rs.Message)
+		default:
+			results = append(results, unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "xrender.crossplane.io/v1beta1",
+				"kind":       "Result",
+				"step":       step,
+				"severity":   rs.GetSeverity().String(),
+				"message":    rs.GetMessage(),
+			}})
+		}
+	}
+	return results, nil
+}
+
+// RenderDesired renders the desired XR and composed resources from the desired
+// state returned by the last step of the Function pipeline.
+func RenderDesired(oxr *composite.Unstructured, ors []composed.Unstructured, d *fnv1beta1.State) (Outputs, error) {
+	observed := ObservedByName(ors)
+
+	// Render desired composed resources in a stable order.
+	names := make([]string, 0, len(d.GetResources()))
+	for name := range d.GetResources() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	desired := make([]composed.Unstructured, 0, len(names))
+	for _, name := range names {
+		dr := d.GetResources()[name]
+		cd := composed.New()
+		if err := FromStruct(cd, dr.GetResource()); err != nil {
+			return Outputs{}, errors.Wrapf(err, "cannot unmarshal desired composed resource %q", name)
+		}
+
+		// If this desired resource state pertains to an existing composed
+		// resource we want to maintain its name and namespace.
+		or, ok := observed[name]
+		if ok {
+			cd.SetNamespace(or.GetNamespace())
+			cd.SetName(or.GetName())
+		}
+
+		// Set standard composed resource metadata that is derived from the XR.
+		if err := RenderComposedResourceMetadata(cd, oxr, name); err != nil {
+			return Outputs{}, errors.Wrapf(err, "cannot render composed resource %q metadata", name)
+		}
+
+		desired = append(desired, *cd)
+	}
+
+	xr := composite.New()
+	if err := FromStruct(xr, d.GetComposite().GetResource()); err != nil {
+		return Outputs{}, errors.Wrap(err, "cannot render desired composite resource")
+	}
+
+	// The Function pipeline can only return the desired status of the XR, so we
+	// inject these back in to help identify which resource it is.
+	xr.SetAPIVersion(oxr.GetAPIVersion())
+	xr.SetKind(oxr.GetKind())
+	xr.SetName(oxr.GetName())
+
+	return Outputs{CompositeResource: xr, ComposedResources: desired, Deletions: Deletions(ors, d)}, nil
+}
+
+// Deletions returns the supplied observed composed resources that aren't in the
+// supplied desired state, as 'fake' KRM-like objects of kind: Deletion.
+// Crossplane would garbage collect (i.e. delete) these composed resources.
+func Deletions(ors []composed.Unstructured, d *fnv1beta1.State) []unstructured.Unstructured {
+	observed := ObservedByName(ors)
+
+	names := make([]string, 0, len(observed))
+	for name := range observed {
+		if _, ok := d.GetResources()[name]; !ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	deletions := make([]unstructured.Unstructured, 0, len(names))
+	for _, name := range names {
+		or := observed[name]
+		deletions = append(deletions, unstructured.Unstructured{Object: map[string]any{
+			"apiVersion":              "xrender.crossplane.io/v1beta1",
+			"kind":                    "Deletion",
+			"compositionResourceName": name,
+			"resource": map[string]any{
+				"apiVersion": or.GetAPIVersion(),
+				"kind":       or.GetKind(),
+				"name":       or.GetName(),
+			},
+		}})
+	}
+	return deletions
+}
+
+// PipelineRange returns the steps of the supplied pipeline from the named step
+// until the named step, inclusive. An empty from step means the first step of
+// the pipeline, and an empty until step means the last.
+func PipelineRange(pipeline []apiextensionsv1.PipelineStep, from, until string) ([]apiextensionsv1.PipelineStep, error) {
+	if from == "" && until == "" {
+		return pipeline, nil
+	}
+	start, end := 0, len(pipeline)-1
+	if from != "" {
+		start = stepIndex(pipeline, from)
+		if start < 0 {
+			return nil, errors.Errorf("cannot start from unknown pipeline step %q", from)
+		}
+	}
+	if until != "" {
+		end = stepIndex(pipeline, until)
+		if end < 0 {
+			return nil, errors.Errorf("cannot stop after unknown pipeline step %q", until)
+		}
+	}
+	if start > end {
+		return nil, errors.Errorf("pipeline step %q comes after pipeline step %q", from, until)
+	}
+	return pipeline[start : end+1], nil
+}
+
+func stepIndex(pipeline []apiextensionsv1.PipelineStep, step string) int {
+	for i := range pipeline {
+		if pipeline[i].Step == step {
+			return i
+		}
+	}
+	return -1
+}
+
+// RenderComposedResourceMetadata sets standard, required composed resource
+// metadata. It's a simplified version of the same function used by Crossplane.
+// Notably it doesn't handle 'nested' XRs - it assumes the supplied XR should be
+// treated as the top-level XR for setting the crossplane.io/composite,
+// crossplane.io/claim-namespace, and crossplane.io/claim-name annotations.
+//
+// https://github.com/crossplane/crossplane/blob/0965f0/internal/controller/apiextensions/composite/composition_render.go#L117
+func RenderComposedResourceMetadata(cd resource.Object, xr resource.Composite, name string) error {
+	cd.SetGenerateName(xr.GetName() + "-")
+	meta.AddAnnotations(cd, map[string]string{AnnotationKeyCompositionResourceName: name})
+	meta.AddLabels(cd, map[string]string{AnnotationKeyCompositeName: xr.GetName()})
+	if ref := xr.GetClaimReference(); ref != nil {
+		meta.AddLabels(cd, map[string]string{
+			AnnotationKeyClaimNamespace: ref.Namespace,
+			AnnotationKeyClaimName:      ref.Name,
+		})
+	}
+
+	or := meta.AsController(meta.TypedReferenceTo(xr, xr.GetObjectKind().GroupVersionKind()))
+	return errors.Wrapf(meta.AddControllerReference(cd, or), "cannot set composite resource %q as controller ref of composed resource", xr.GetName())
+}
@@ -0,0 +1,643 @@
+package render
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/apis/pkg/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+)
+
+func TestRender(t *testing.T) {
+	pipeline := apiextensionsv1.CompositionModePipeline
+
+	// Add all listeners here so we can close them to shutdown our gRPC servers.
+	listeners := make([]io.Closer, 0)
+
+	type args struct {
+		ctx context.Context
+		in  Inputs
+	}
+	type want struct {
+		out Outputs
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		rsp    *fnv1beta1.RunFunctionResponse
+		args   args
+		want   want
+	}{
+		"UnknownRuntime": {
+			args: args{
+				ctx: context.Background(),
+				in: Inputs{
+					CompositeResource: composite.New(),
+					Composition: &apiextensionsv1.Composition{
+						Spec: apiextensionsv1.CompositionSpec{
+							Mode: &pipeline,
+							Pipeline: []apiextensionsv1.PipelineStep{
+								{
+									Step:        "test",
+									FunctionRef: apiextensionsv1.FunctionReference{Name: "function-test"},
+								},
+							},
+						},
+					},
+					Functions: []pkgv1beta1.Function{{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "function-test",
+							Annotations: map[string]string{
+								functions.AnnotationKeyRuntime: "wat",
+							},
+						},
+					}},
+				},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"UnknownFunction": {
+			args: args{
+				ctx: context.Background(),
+				in: Inputs{
+					CompositeResource: composite.New(),
+					Composition: &apiextensionsv1.Composition{
+						Spec: apiextensionsv1.CompositionSpec{
+							Mode: &pipeline,
+							Pipeline: []apiextensionsv1.PipelineStep{
+								{
+									Step:        "test",
+									FunctionRef: apiextensionsv1.FunctionReference{Name: "function-test"},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"FatalResult": {
+			args: args{
+				ctx: context.Background(),
+				in: Inputs{
+					CompositeResource: composite.New(),
+					Composition: &apiextensionsv1.Composition{
+						Spec: apiextensionsv1.CompositionSpec{
+							Mode: &pipeline,
+							Pipeline: []apiextensionsv1.PipelineStep{
+								{
+									Step:        "test",
+									FunctionRef: apiextensionsv1.FunctionReference{Name: "function-test"},
+								},
+							},
+						},
+					},
+					Functions: []pkgv1beta1.Function{
+						func() pkgv1beta1.Function {
+
+							lis := xtest.NewFunction(t, &fnv1beta1.RunFunctionResponse{
+								Results: []*fnv1beta1.Result{
+									{
+										Severity: fnv1beta1.Severity_SEVERITY_FATAL,
+									},
+								},
+							})
+							listeners = append(listeners, lis)
+
+							return pkgv1beta1.Function{
+								ObjectMeta: metav1.ObjectMeta{
+									Name: "function-test",
+									Annotations: map[string]string{
+										functions.AnnotationKeyRuntime:                  string(functions.AnnotationValueRuntimeDevelopment),
+										functions.AnnotationKeyRuntimeDevelopmentTarget: lis.Addr().String(),
+									},
+								},
+							}
+						}(),
+					},
+				},
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+
+		"Success": {
+			args: args{
+				ctx: context.Background(),
+				in: Inputs{
+					CompositeResource: &composite.Unstructured{
+						Unstructured: unstructured.Unstructured{
+							Object: xtest.MustLoadJSON(`{
+								"apiVersion": "nop.example.org/v1alpha1",
+								"kind": "XNopResource",
+								"metadata": {
+									"name": "test-xrender"
+								}
+							}`),
+						},
+					},
+					Composition: &apiextensionsv1.Composition{
+						Spec: apiextensionsv1.CompositionSpec{
+							Mode: &pipeline,
+							Pipeline: []apiextensionsv1.PipelineStep{
+								{
+									Step:        "test",
+									FunctionRef: apiextensionsv1.FunctionReference{Name: "function-test"},
+								},
+							},
+						},
+					},
+					Functions: []pkgv1beta1.Function{
+						func() pkgv1beta1.Function {
+
+							lis := xtest.NewFunction(t, &fnv1beta1.RunFunctionResponse{
+								Desired: &fnv1beta1.State{
+									Composite: &fnv1beta1.Resource{
+										Resource: xtest.MustStructJSON(`{
+											"status": {
+												"widgets": 9001
+											}
+										}`),
+									},
+									Resources: map[string]*fnv1beta1.Resource{
+										"cool-resource": {
+											Resource: xtest.MustStructJSON(`{
+												"apiVersion": "test.crossplane.io/v1",
+												"kind": "Composed",
+												"spec": {
+													"widgets": 9002
+												}
+											}`),
+										},
+									},
+								},
+							})
+							listeners = append(listeners, lis)
+
+							return pkgv1beta1.Function{
+								ObjectMeta: metav1.ObjectMeta{
+									Name: "function-test",
+									Annotations: map[string]string{
+										functions.AnnotationKeyRuntime:                  string(functions.AnnotationValueRuntimeDevelopment),
+										functions.AnnotationKeyRuntimeDevelopmentTarget: lis.Addr().String(),
+									},
+								},
+							}
+						}(),
+					},
+				},
+			},
+			want: want{
+				out: Outputs{
+					CompositeResource: &composite.Unstructured{
+						Unstructured: unstructured.Unstructured{
+							Object: xtest.MustLoadJSON(`{
+								"apiVersion": "nop.example.org/v1alpha1",
+								"kind": "XNopResource",
+								"metadata": {
+									"name": "test-xrender"
+								},
+								"status": {
+									"widgets": 9001
+								}
+							}`),
+						},
+					},
+					ComposedResources: []composed.Unstructured{
+						{
+							Unstructured: unstructured.Unstructured{
+								Object: xtest.MustLoadJSON(`{
+									"apiVersion": "test.crossplane.io/v1",
+									"metadata": {
+										"generateName": "test-xrender-",
+										"labels": {
+											"crossplane.io/composite": "test-xrender"
+										},
+										"annotations": {
+											"crossplane.io/composition-resource-name": "cool-resource"
+										},
+										"ownerReferences": [{
+											"apiVersion": "nop.example.org/v1alpha1",
+											"kind": "XNopResource",
+											"name": "test-xrender",
+											"blockOwnerDeletion": true,
+											"controller": true,
+											"uid": ""
+										}]
+									},
+									"kind": "Composed",
+									"spec": {
+										"widgets": 9002
+									}
+								}`),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			out, err := Render(tc.args.ctx, tc.args.in)
+
+			if diff := cmp.Diff(tc.want.out, out, cmpopts.EquateEmpty(), cmpopts.IgnoreFields(Outputs{}, "Steps")); diff != "" {
+				t.Errorf("%s\nRender(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nRender(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+
+	for _, l := range listeners {
+		l.Close()
+	}
+}
+
+func TestRunPipelineSteps(t *testing.T) {
+	pipeline := apiextensionsv1.CompositionModePipeline
+
+	in := Inputs{
+		CompositeResource: composite.New(),
+		Composition: &apiextensionsv1.Composition{
+			Spec: apiextensionsv1.CompositionSpec{
+				Mode:     &pipeline,
+				Pipeline: []apiextensionsv1.PipelineStep{{Step: "one"}, {Step: "two"}, {Step: "three"}},
+			},
+		},
+		FromStep: "two",
+	}
+
+	r := StepRunnerFn(func(_ context.Context, fn apiextensionsv1.PipelineStep, _ *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+		if fn.Step == "three" {
+			return nil, errors.New("boom")
+		}
+		return &fnv1beta1.RunFunctionResponse{}, nil
+	})
+
+	out, err := RunPipeline(context.Background(), r, in)
+	if err == nil {
+		t.Fatalf("RunPipeline(...): want error from failing step, got nil")
+	}
+
+	type step struct {
+		Step        string
+		Index       int
+		HasRequest  bool
+		HasResponse bool
+	}
+	got := make([]step, 0, len(out.Steps))
+	for _, s := range out.Steps {
+		got = append(got, step{Step: s.Step, Index: s.Index, HasRequest: s.Request != nil, HasResponse: s.Response != nil})
+	}
+
+	// Steps should be numbered by their position in the full pipeline, and
+	// the failing step's request should be recorded.
+	want := []step{
+		{Step: "two", Index: 1, HasRequest: true, HasResponse: true},
+		{Step: "three", Index: 2, HasRequest: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RunPipeline(...): -want steps, +got steps:\n%s", diff)
+	}
+}
+
+func TestRunPipelineDeletions(t *testing.T) {
+	pipeline := apiextensionsv1.CompositionModePipeline
+
+	observed := func(name string) composed.Unstructured {
+		cd := composed.New()
+		cd.SetAPIVersion("example.org/v1")
+		cd.SetKind("A")
+		cd.SetName("test-" + name)
+		cd.SetAnnotations(map[string]string{AnnotationKeyCompositionResourceName: name})
+		return *cd
+	}
+
+	// Only step two desires an observed composed resource, and only a.
+	r := StepRunnerFn(func(_ context.Context, fn apiextensionsv1.PipelineStep, req *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+		d := req.GetDesired()
+		if fn.Step == "two" {
+			d = &fnv1beta1.State{Resources: map[string]*fnv1beta1.Resource{
+				"a": {Resource: xtest.MustStructJSON(`{"apiVersion":"example.org/v1","kind":"A"}`)},
+			}}
+		}
+		return &fnv1beta1.RunFunctionResponse{Desired: d}, nil
+	})
+
+	cases := map[string]struct {
+		reason string
+		from   string
+		until  string
+		want   int
+	}{
+		"WholePipeline": {
+			reason: "Observed resources the whole pipeline doesn't desire should be deleted.",
+			want:   1,
+		},
+		"UntilStep": {
+			reason: "Nothing should be deleted if we stopped before the step that desires an observed resource.",
+			until:  "one",
+			want:   0,
+		},
+		"FromStep": {
+			reason: "Nothing should be deleted if we started after the step that desires an observed resource.",
+			from:   "three",
+			want:   0,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			in := Inputs{
+				CompositeResource: composite.New(),
+				Composition: &apiextensionsv1.Composition{
+					Spec: apiextensionsv1.CompositionSpec{
+						Mode:     &pipeline,
+						Pipeline: []apiextensionsv1.PipelineStep{{Step: "one"}, {Step: "two"}, {Step: "three"}},
+					},
+				},
+				ObservedResources: []composed.Unstructured{observed("a"), observed("b")},
+				FromStep:          tc.from,
+				UntilStep:         tc.until,
+			}
+			out, err := RunPipeline(context.Background(), r, in)
+			if err != nil {
+				t.Fatalf("RunPipeline(...): %v", err)
+			}
+			if got := len(out.Deletions); got != tc.want {
+				t.Errorf("%s\nRunPipeline(...): want %d deletions, got %d: %v", tc.reason, tc.want, got, out.Deletions)
+			}
+		})
+	}
+}
+
+func TestRenderModes(t *testing.T) {
+	pipeline := apiextensionsv1.CompositionModePipeline
+	resources := apiextensionsv1.CompositionModeResources
+
+	observed := composed.New()
+	observed.SetAPIVersion("example.org/v1")
+	observed.SetKind("A")
+	observed.SetName("test-a")
+	observed.SetAnnotations(map[string]string{AnnotationKeyCompositionResourceName: "a"})
+
+	// Only function-patch-and-transform desires composed resource a.
+	r := StepRunnerFn(func(_ context.Context, fn apiextensionsv1.PipelineStep, _ *fnv1beta1.RunFunctionRequest) (*fnv1beta1.RunFunctionResponse, error) {
+		if fn.FunctionRef.Name != DefaultPatchAndTransformFunctionName {
+			return nil, errors.Errorf("unexpected Function %q", fn.FunctionRef.Name)
+		}
+		return &fnv1beta1.RunFunctionResponse{Desired: &fnv1beta1.State{Resources: map[string]*fnv1beta1.Resource{
+			"a": {Resource: xtest.MustStructJSON(`{"apiVersion":"example.org/v1","kind":"A"}`)},
+		}}}, nil
+	})
+
+	type want struct {
+		composed  int
+		deletions int
+		err       error
+	}
+	cases := map[string]struct {
+		reason string
+		mode   *apiextensionsv1.CompositionMode
+		want   want
+	}{
+		"ResourcesMode": {
+			reason: "A Resources mode Composition should be rendered using function-patch-and-transform.",
+			mode:   &resources,
+			want:   want{composed: 1},
+		},
+		"DefaultMode": {
+			reason: "A Composition with no mode should be rendered like a Resources mode Composition.",
+			want:   want{composed: 1},
+		},
+		"PipelineMode": {
+			reason: "A Pipeline mode Composition shouldn't be converted.",
+			mode:   &pipeline,
+			want:   want{err: cmpopts.AnyError},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			in := Inputs{
+				CompositeResource: composite.New(),
+				Composition: &apiextensionsv1.Composition{
+					Spec: apiextensionsv1.CompositionSpec{
+						Mode: tc.mode,
+						Resources: []apiextensionsv1.ComposedTemplate{{
+							Name: ptr.To("a"),
+							Base: runtime.RawExtension{Raw: []byte(`{"apiVersion":"example.org/v1","kind":"A"}`)},
+						}},
+						Pipeline: []apiextensionsv1.PipelineStep{{Step: "one", FunctionRef: apiextensionsv1.FunctionReference{Name: "function-one"}}},
+					},
+				},
+				ObservedResources: []composed.Unstructured{*observed},
+			}
+			out, err := Render(context.Background(), in, WithStepRunner(r))
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nRender(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if got := len(out.ComposedResources); got != tc.want.composed {
+				t.Errorf("%s\nRender(...): want %d composed resources, got %d", tc.reason, tc.want.composed, got)
+			}
+			if got := len(out.Deletions); got != tc.want.deletions {
+				t.Errorf("%s\nRender(...): want %d deletions, got %d: %v", tc.reason, tc.want.deletions, got, out.Deletions)
+			}
+		})
+	}
+
+	t.Run("RunPipeline", func(t *testing.T) {
+		in := Inputs{
+			CompositeResource: composite.New(),
+			Composition:       &apiextensionsv1.Composition{Spec: apiextensionsv1.CompositionSpec{Mode: &resources}},
+		}
+		if _, err := RunPipeline(context.Background(), r, in); err == nil {
+			t.Errorf("RunPipeline(...): want error for a Resources mode Composition, got nil")
+		}
+	})
+}
+
+func TestPipelineRange(t *testing.T) {
+	pipeline := []apiextensionsv1.PipelineStep{{Step: "one"}, {Step: "two"}, {Step: "three"}}
+
+	type args struct {
+		from  string
+		until string
+	}
+	type want struct {
+		steps []apiextensionsv1.PipelineStep
+		err   error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"WholePipeline": {
+			reason: "With no from or until step we should return the whole pipeline.",
+			want: want{
+				steps: pipeline,
+			},
+		},
+		"FromStep": {
+			reason: "We should return the from step and every step after it.",
+			args: args{
+				from: "two",
+			},
+			want: want{
+				steps: pipeline[1:],
+			},
+		},
+		"UntilStep": {
+			reason: "We should return the until step and every step before it.",
+			args: args{
+				until: "two",
+			},
+			want: want{
+				steps: pipeline[:2],
+			},
+		},
+		"SingleStep": {
+			reason: "We should return a single step when from and until are the same step.",
+			args: args{
+				from:  "two",
+				until: "two",
+			},
+			want: want{
+				steps: pipeline[1:2],
+			},
+		},
+		"UnknownStep": {
+			reason: "We should return an error if asked to start from a step that doesn't exist.",
+			args: args{
+				from: "four",
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"Backwards": {
+			reason: "We should return an error if the from step comes after the until step.",
+			args: args{
+				from:  "three",
+				until: "one",
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			steps, err := PipelineRange(pipeline, tc.args.from, tc.args.until)
+
+			if diff := cmp.Diff(tc.want.steps, steps, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("%s\nPipelineRange(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nPipelineRange(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDeletions(t *testing.T) {
+	observed := func(name, kind string) composed.Unstructured {
+		cd := composed.New()
+		cd.SetAPIVersion("example.org/v1")
+		cd.SetKind(kind)
+		cd.SetName("test-" + name)
+		cd.SetAnnotations(map[string]string{AnnotationKeyCompositionResourceName: name})
+		return *cd
+	}
+	deletion := func(name, kind string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]any{
+			"apiVersion":              "xrender.crossplane.io/v1beta1",
+			"kind":                    "Deletion",
+			"compositionResourceName": name,
+			"resource": map[string]any{
+				"apiVersion": "example.org/v1",
+				"kind":       kind,
+				"name":       "test-" + name,
+			},
+		}}
+	}
+
+	type args struct {
+		ors []composed.Unstructured
+		d   *fnv1beta1.State
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   []unstructured.Unstructured
+	}{
+		"NothingObserved": {
+			reason: "Nothing should be deleted if nothing was observed.",
+			args: args{
+				d: &fnv1beta1.State{Resources: map[string]*fnv1beta1.Resource{"a": {}}},
+			},
+			want: []unstructured.Unstructured{},
+		},
+		"AllDesired": {
+			reason: "Nothing should be deleted if everything observed is still desired.",
+			args: args{
+				ors: []composed.Unstructured{observed("a", "A")},
+				d:   &fnv1beta1.State{Resources: map[string]*fnv1beta1.Resource{"a": {}, "b": {}}},
+			},
+			want: []unstructured.Unstructured{},
+		},
+		"SomeUndesired": {
+			reason: "Observed composed resources that aren't desired should be deleted, in name order.",
+			args: args{
+				ors: []composed.Unstructured{observed("c", "C"), observed("a", "A"), observed("b", "B")},
+				d:   &fnv1beta1.State{Resources: map[string]*fnv1beta1.Resource{"b": {}}},
+			},
+			want: []unstructured.Unstructured{deletion("a", "A"), deletion("c", "C")},
+		},
+		"NothingDesired": {
+			reason: "Everything observed should be deleted if nothing is desired.",
+			args: args{
+				ors: []composed.Unstructured{observed("a", "A")},
+			},
+			want: []unstructured.Unstructured{deletion("a", "A")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Deletions(tc.args.ors, tc.args.d)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("%s\nDeletions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
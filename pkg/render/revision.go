@@ -0,0 +1,166 @@
+package render
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+)
+
+// A CompositionRevisionSelection is a CompositionRevision selected for an XR,
+// and why it was selected.
+type CompositionRevisionSelection struct {
+	Revision *apiextensionsv1.CompositionRevision
+	Reason   string
+}
+
+// SelectCompositionRevision returns the revision of the named Composition that
+// Crossplane would use for the supplied XR. If the supplied revision number is
+// greater than zero that revision is selected. Otherwise, if the XR's
+// compositionUpdatePolicy is Manual, the revision referenced by its
+// spec.compositionRevisionRef is selected. Otherwise the latest revision that
+// matches the XR's spec.compositionRevisionSelector is selected.
+func SelectCompositionRevision(xr *composite.Unstructured, comp string, revs []apiextensionsv1.CompositionRevision, revision int64) (CompositionRevisionSelection, error) {
+	candidates := make([]apiextensionsv1.CompositionRevision, 0, len(revs))
+	for _, rev := range revs {
+		if rev.GetLabels()[apiextensionsv1.LabelCompositionName] == comp {
+			candidates = append(candidates, rev)
+		}
+	}
+
+	if revision > 0 {
+		for i := range candidates {
+			if candidates[i].Spec.Revision == revision {
+				return CompositionRevisionSelection{Revision: &candidates[i], Reason: fmt.Sprintf("revision %d was requested", revision)}, nil
+			}
+		}
+		return CompositionRevisionSelection{}, errors.Errorf("Composition %q has no revision %d", comp, revision)
+	}
+
+	// The XR is pinned to its current revision.
+	if pol := xr.GetCompositionUpdatePolicy(); pol != nil && *pol == xpv1.UpdateManual && xr.GetCompositionRevisionReference() != nil {
+		return selectPinnedRevision(xr.GetCompositionRevisionReference().Name, comp, candidates)
+	}
+
+	// Crossplane defaults the update policy to Automatic.
+	return selectLatestRevision(xr, comp, candidates)
+}
+
+func selectPinnedRevision(name, comp string, candidates []apiextensionsv1.CompositionRevision) (CompositionRevisionSelection, error) {
+	reason := "the XR's compositionUpdatePolicy is Manual and its spec.compositionRevisionRef references it"
+	latest := latestRevision(candidates)
+	for i := range candidates {
+		rev := &candidates[i]
+		if rev.GetName() != name {
+			continue
+		}
+		if latest != nil && latest.Spec.Revision > rev.Spec.Revision {
+			reason += fmt.Sprintf(" - with an Automatic policy it would use revision %d (%q)", latest.Spec.Revision, latest.GetName())
+		}
+		return CompositionRevisionSelection{Revision: rev, Reason: reason}, nil
+	}
+	return CompositionRevisionSelection{}, errors.Errorf("Composition %q has no CompositionRevision %q, but %s", comp, name, reason)
+}
+
+func selectLatestRevision(xr *composite.Unstructured, comp string, candidates []apiextensionsv1.CompositionRevision) (CompositionRevisionSelection, error) {
+	reason := "it's the latest revision"
+	if sel := xr.GetCompositionRevisionSelector(); sel != nil {
+		s := labels.SelectorFromSet(sel.MatchLabels)
+		matched := make([]apiextensionsv1.CompositionRevision, 0, len(candidates))
+		for _, rev := range candidates {
+			if s.Matches(labels.Set(rev.GetLabels())) {
+				matched = append(matched, rev)
+			}
+		}
+		candidates = matched
+		reason = fmt.Sprintf("it's the latest revision matching the XR's spec.compositionRevisionSelector (%s)", s)
+	}
+
+	latest := latestRevision(candidates)
+	if latest == nil {
+		return CompositionRevisionSelection{}, errors.Errorf("Composition %q has no compatible revisions", comp)
+	}
+	if current := xr.GetCompositionRevisionReference(); current != nil && current.Name != latest.GetName() {
+		reason += fmt.Sprintf(" - the XR would move from %q", current.Name)
+	}
+	return CompositionRevisionSelection{Revision: latest, Reason: reason}, nil
+}
+
+// CompositionFromRevision returns a Composition with the supplied
+// CompositionRevision's spec. It's named for the Composition the revision is a
+// revision of.
+func CompositionFromRevision(rev *apiextensionsv1.CompositionRevision) *apiextensionsv1.Composition {
+	name := rev.GetLabels()[apiextensionsv1.LabelCompositionName]
+	if name == "" {
+		name = rev.GetName()
+	}
+
+	// CompositionRevisions copy the labels of their Composition.
+	lbls := map[string]string{}
+	for k, v := range rev.GetLabels() {
+		if k != apiextensionsv1.LabelCompositionName && k != apiextensionsv1.LabelCompositionHash {
+			lbls[k] = v
+		}
+	}
+
+	return &apiextensionsv1.Composition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+			Kind:       apiextensionsv1.CompositionKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      lbls,
+			Annotations: rev.GetAnnotations(),
+		},
+		Spec: (&apiextensionsv1.GeneratedRevisionSpecConverter{}).FromRevisionSpec(rev.Spec),
+	}
+}
+
+// WithRevisionCompositions returns the supplied Compositions, plus a
+// Composition derived from the latest revision of each Composition that only
+// the supplied CompositionRevisions mention. This lets a Composition be
+// selected when only its revisions were supplied.
+func WithRevisionCompositions(comps []apiextensionsv1.Composition, revs []apiextensionsv1.CompositionRevision) []apiextensionsv1.Composition {
+	have := map[string]bool{}
+	for _, c := range comps {
+		have[c.GetName()] = true
+	}
+
+	byComp := map[string][]apiextensionsv1.CompositionRevision{}
+	order := make([]string, 0)
+	for _, rev := range revs {
+		name := rev.GetLabels()[apiextensionsv1.LabelCompositionName]
+		if name == "" || have[name] {
+			continue
+		}
+		if _, ok := byComp[name]; !ok {
+			order = append(order, name)
+		}
+		byComp[name] = append(byComp[name], rev)
+	}
+
+	out := append([]apiextensionsv1.Composition{}, comps...)
+	for _, name := range order {
+		out = append(out, *CompositionFromRevision(latestRevision(byComp[name])))
+	}
+	return out
+}
+
+// latestRevision returns the revision with the highest revision number, or nil
+// if there are no revisions.
+func latestRevision(revs []apiextensionsv1.CompositionRevision) *apiextensionsv1.CompositionRevision {
+	var latest *apiextensionsv1.CompositionRevision
+	for i := range revs {
+		if latest == nil || revs[i].Spec.Revision > latest.Spec.Revision {
+			latest = &revs[i]
+		}
+	}
+	return latest
+}
@@ -0,0 +1,231 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+)
+
+func NewCompositionRevision(comp string, revision int64, labels map[string]string) apiextensionsv1.CompositionRevision {
+	l := map[string]string{apiextensionsv1.LabelCompositionName: comp}
+	for k, v := range labels {
+		l[k] = v
+	}
+	return apiextensionsv1.CompositionRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-rev%d", comp, revision), Labels: l},
+		Spec: apiextensionsv1.CompositionRevisionSpec{
+			CompositeTypeRef: apiextensionsv1.TypeReference{APIVersion: "example.org/v1", Kind: "XBucket"},
+			Mode:             ptr.To(apiextensionsv1.CompositionModePipeline),
+			Revision:         revision,
+		},
+	}
+}
+
+func TestSelectCompositionRevision(t *testing.T) {
+	revs := []apiextensionsv1.CompositionRevision{
+		NewCompositionRevision("aws", 1, map[string]string{"channel": "stable"}),
+		NewCompositionRevision("aws", 3, map[string]string{"channel": "beta"}),
+		NewCompositionRevision("aws", 2, map[string]string{"channel": "stable"}),
+		NewCompositionRevision("gcp", 4, nil),
+	}
+
+	xr := func(fns ...func(xr *composite.Unstructured)) *composite.Unstructured {
+		xr := composite.New()
+		for _, fn := range fns {
+			fn(xr)
+		}
+		return xr
+	}
+	withRef := func(name string) func(xr *composite.Unstructured) {
+		return func(xr *composite.Unstructured) {
+			xr.SetCompositionRevisionReference(&corev1.ObjectReference{Name: name})
+		}
+	}
+	withPolicy := func(p xpv1.UpdatePolicy) func(xr *composite.Unstructured) {
+		return func(xr *composite.Unstructured) {
+			xr.SetCompositionUpdatePolicy(&p)
+		}
+	}
+
+	type args struct {
+		xr       *composite.Unstructured
+		revision int64
+	}
+	type want struct {
+		name string
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Latest": {
+			reason: "The latest revision of the Composition should be selected by default.",
+			args: args{
+				xr: xr(),
+			},
+			want: want{
+				name: "aws-rev3",
+			},
+		},
+		"AutomaticMovesToLatest": {
+			reason: "An XR with an Automatic update policy should move to the latest revision.",
+			args: args{
+				xr: xr(withRef("aws-rev1"), withPolicy(xpv1.UpdateAutomatic)),
+			},
+			want: want{
+				name: "aws-rev3",
+			},
+		},
+		"ManualPinned": {
+			reason: "An XR with a Manual update policy should use the revision it references.",
+			args: args{
+				xr: xr(withRef("aws-rev1"), withPolicy(xpv1.UpdateManual)),
+			},
+			want: want{
+				name: "aws-rev1",
+			},
+		},
+		"ManualMissing": {
+			reason: "We should return an error if an XR with a Manual update policy references a revision that doesn't exist.",
+			args: args{
+				xr: xr(withRef("aws-rev9"), withPolicy(xpv1.UpdateManual)),
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"ManualPinnedToAnotherComposition": {
+			reason: "We should return an error if an XR with a Manual update policy references a revision of another Composition.",
+			args: args{
+				xr: xr(withRef("gcp-rev4"), withPolicy(xpv1.UpdateManual)),
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"Selector": {
+			reason: "The latest revision matching the XR's revision selector should be selected.",
+			args: args{
+				xr: xr(func(xr *composite.Unstructured) {
+					xr.SetCompositionRevisionSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"channel": "stable"}})
+				}),
+			},
+			want: want{
+				name: "aws-rev2",
+			},
+		},
+		"SelectorNoMatch": {
+			reason: "We should return an error if no revision matches the XR's revision selector.",
+			args: args{
+				xr: xr(func(xr *composite.Unstructured) {
+					xr.SetCompositionRevisionSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"channel": "alpha"}})
+				}),
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"Revision": {
+			reason: "The requested revision should be selected, even if the XR is pinned to another.",
+			args: args{
+				xr:       xr(withRef("aws-rev1"), withPolicy(xpv1.UpdateManual)),
+				revision: 2,
+			},
+			want: want{
+				name: "aws-rev2",
+			},
+		},
+		"RevisionOfAnotherComposition": {
+			reason: "We should return an error if the requested revision isn't a revision of the Composition.",
+			args: args{
+				xr:       xr(),
+				revision: 4,
+			},
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s, err := SelectCompositionRevision(tc.args.xr, "aws", revs, tc.args.revision)
+
+			got := ""
+			if s.Revision != nil {
+				got = s.Revision.GetName()
+			}
+			if diff := cmp.Diff(tc.want.name, got); diff != "" {
+				t.Errorf("%s\nSelectCompositionRevision(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nSelectCompositionRevision(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestWithRevisionCompositions(t *testing.T) {
+	comp := apiextensionsv1.Composition{ObjectMeta: metav1.ObjectMeta{Name: "aws"}}
+
+	type args struct {
+		comps []apiextensionsv1.Composition
+		revs  []apiextensionsv1.CompositionRevision
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   []apiextensionsv1.Composition
+	}{
+		"CompositionSupplied": {
+			reason: "Compositions that were supplied shouldn't be derived from their revisions.",
+			args: args{
+				comps: []apiextensionsv1.Composition{comp},
+				revs:  []apiextensionsv1.CompositionRevision{NewCompositionRevision("aws", 1, nil)},
+			},
+			want: []apiextensionsv1.Composition{comp},
+		},
+		"OnlyRevisions": {
+			reason: "A Composition should be derived from the latest revision of a Composition that wasn't supplied.",
+			args: args{
+				revs: []apiextensionsv1.CompositionRevision{
+					NewCompositionRevision("gcp", 1, map[string]string{"provider": "gcp"}),
+					NewCompositionRevision("gcp", 2, map[string]string{"provider": "gcp", "new": "true"}),
+				},
+			},
+			want: []apiextensionsv1.Composition{{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+					Kind:       apiextensionsv1.CompositionKind,
+				},
+				ObjectMeta: metav1.ObjectMeta{Name: "gcp", Labels: map[string]string{"provider": "gcp", "new": "true"}},
+				Spec: apiextensionsv1.CompositionSpec{
+					CompositeTypeRef: apiextensionsv1.TypeReference{APIVersion: "example.org/v1", Kind: "XBucket"},
+					Mode:             ptr.To(apiextensionsv1.CompositionModePipeline),
+				},
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := WithRevisionCompositions(tc.args.comps, tc.args.revs)
+
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("%s\nWithRevisionCompositions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
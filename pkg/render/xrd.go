@@ -0,0 +1,102 @@
+package render
+
+import (
+	"encoding/json"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	structuraldefaulting "k8s.io/apiextensions-apiserver/pkg/apiserver/schema/defaulting"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+)
+
+// CompositeResourceSchema returns the OpenAPI schema of the supplied XRD's
+// version with the supplied name. Like the CRD Crossplane generates for an
+// XRD, the schema also describes the XR's apiVersion, kind and metadata.
+func CompositeResourceSchema(xrd *apiextensionsv1.CompositeResourceDefinition, version string) (*apiextensions.JSONSchemaProps, error) {
+	var v *apiextensionsv1.CompositeResourceDefinitionVersion
+	for i := range xrd.Spec.Versions {
+		if xrd.Spec.Versions[i].Name == version {
+			v = &xrd.Spec.Versions[i]
+			break
+		}
+	}
+	if v == nil {
+		return nil, errors.Errorf("XRD %q has no version %q", xrd.GetName(), version)
+	}
+
+	in := &extv1.JSONSchemaProps{}
+	if v.Schema != nil && len(v.Schema.OpenAPIV3Schema.Raw) > 0 {
+		if err := json.Unmarshal(v.Schema.OpenAPIV3Schema.Raw, in); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal OpenAPI schema of XRD %q version %q", xrd.GetName(), version)
+		}
+	}
+
+	s := &apiextensions.JSONSchemaProps{}
+	if err := extv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(in, s, nil); err != nil {
+		return nil, errors.Wrapf(err, "cannot convert OpenAPI schema of XRD %q version %q", xrd.GetName(), version)
+	}
+
+	s.Type = "object"
+	if s.Properties == nil {
+		s.Properties = map[string]apiextensions.JSONSchemaProps{}
+	}
+	s.Properties["apiVersion"] = apiextensions.JSONSchemaProps{Type: "string"}
+	s.Properties["kind"] = apiextensions.JSONSchemaProps{Type: "string"}
+	s.Properties["metadata"] = apiextensions.JSONSchemaProps{Type: "object"}
+
+	return s, nil
+}
+
+// DefaultCompositeResource applies the defaults from the supplied XRD's OpenAPI
+// schema to the supplied XR, like the API server would when the XR is created.
+// It then validates the defaulted XR against the schema, returning an error
+// that describes every violation.
+func DefaultCompositeResource(xr *composite.Unstructured, xrd *apiextensionsv1.CompositeResourceDefinition) error {
+	gvk := xr.GetObjectKind().GroupVersionKind()
+	if gvk.Group != xrd.Spec.Group || gvk.Kind != xrd.Spec.Names.Kind {
+		return errors.Errorf("XRD %q defines %s.%s, not %s.%s", xrd.GetName(), xrd.Spec.Names.Kind, xrd.Spec.Group, gvk.Kind, gvk.Group)
+	}
+
+	s, err := CompositeResourceSchema(xrd, gvk.Version)
+	if err != nil {
+		return err
+	}
+
+	ss, err := structuralschema.NewStructural(s)
+	if err != nil {
+		return errors.Wrapf(err, "XRD %q has an invalid OpenAPI schema", xrd.GetName())
+	}
+	structuraldefaulting.Default(xr.Object, ss)
+
+	v, _, err := validation.NewSchemaValidator(s)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create validator for XRD %q", xrd.GetName())
+	}
+	if errs := validation.ValidateCustomResource(nil, xr.Object, v); len(errs) > 0 {
+		return errors.Wrapf(errs.ToAggregate(), "composite resource %q is invalid", xr.GetName())
+	}
+
+	return nil
+}
+
+// WithCompositeSpec returns the supplied render outputs, with the spec of the
+// supplied XR copied to the rendered XR. The Function pipeline only returns
+// the desired status of the XR. This shows what the XR looked like when the
+// pipeline ran, for example with defaults applied.
+func WithCompositeSpec(out Outputs, xr *composite.Unstructured) Outputs {
+	spec, ok := xr.Object["spec"]
+	if !ok || out.CompositeResource == nil {
+		return out
+	}
+	rxr := &composite.Unstructured{Unstructured: *out.CompositeResource.Unstructured.DeepCopy()}
+	rxr.Object["spec"] = runtime.DeepCopyJSONValue(spec)
+	out.CompositeResource = rxr
+	return out
+}
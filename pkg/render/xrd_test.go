@@ -0,0 +1,127 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+)
+
+func TestDefaultCompositeResource(t *testing.T) {
+	xrd := &apiextensionsv1.CompositeResourceDefinition{
+		Spec: apiextensionsv1.CompositeResourceDefinitionSpec{
+			Group: "example.org",
+			Names: extv1.CustomResourceDefinitionNames{Kind: "XBucket", Plural: "xbuckets"},
+			Versions: []apiextensionsv1.CompositeResourceDefinitionVersion{{
+				Name: "v1",
+				Schema: &apiextensionsv1.CompositeResourceValidation{
+					OpenAPIV3Schema: runtime.RawExtension{Raw: []byte(`{
+						"type": "object",
+						"properties": {
+							"spec": {
+								"type": "object",
+								"required": ["size"],
+								"properties": {
+									"size": {"type": "integer", "minimum": 1},
+									"region": {"type": "string", "default": "us-east-2"},
+									"tags": {"type": "object", "default": {}, "properties": {"team": {"type": "string", "default": "platform"}}}
+								}
+							}
+						}
+					}`)},
+				},
+			}},
+		},
+	}
+
+	type args struct {
+		xr  string
+		xrd *apiextensionsv1.CompositeResourceDefinition
+	}
+	type want struct {
+		xr  string
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Defaulted": {
+			reason: "Defaults from the XRD's schema should be applied to the XR.",
+			args: args{
+				xr:  `{"apiVersion":"example.org/v1","kind":"XBucket","metadata":{"name":"cool-xr"},"spec":{"size":2}}`,
+				xrd: xrd,
+			},
+			want: want{
+				xr: `{"apiVersion":"example.org/v1","kind":"XBucket","metadata":{"name":"cool-xr"},"spec":{"size":2,"region":"us-east-2","tags":{"team":"platform"}}}`,
+			},
+		},
+		"NotDefaulted": {
+			reason: "Fields that are already set should not be defaulted.",
+			args: args{
+				xr:  `{"apiVersion":"example.org/v1","kind":"XBucket","metadata":{"name":"cool-xr"},"spec":{"size":2,"region":"eu-west-1","tags":{"team":"cool"}}}`,
+				xrd: xrd,
+			},
+			want: want{
+				xr: `{"apiVersion":"example.org/v1","kind":"XBucket","metadata":{"name":"cool-xr"},"spec":{"size":2,"region":"eu-west-1","tags":{"team":"cool"}}}`,
+			},
+		},
+		"Invalid": {
+			reason: "An XR that doesn't match the XRD's schema should return an error.",
+			args: args{
+				xr:  `{"apiVersion":"example.org/v1","kind":"XBucket","metadata":{"name":"cool-xr"},"spec":{"region":42}}`,
+				xrd: xrd,
+			},
+			want: want{
+				xr:  `{"apiVersion":"example.org/v1","kind":"XBucket","metadata":{"name":"cool-xr"},"spec":{"region":42,"tags":{"team":"platform"}}}`,
+				err: cmpopts.AnyError,
+			},
+		},
+		"WrongKind": {
+			reason: "An XR of a kind the XRD doesn't define should return an error.",
+			args: args{
+				xr:  `{"apiVersion":"example.org/v1","kind":"XDatabase","metadata":{"name":"cool-xr"}}`,
+				xrd: xrd,
+			},
+			want: want{
+				xr:  `{"apiVersion":"example.org/v1","kind":"XDatabase","metadata":{"name":"cool-xr"}}`,
+				err: cmpopts.AnyError,
+			},
+		},
+		"NoSuchVersion": {
+			reason: "An XR of a version the XRD doesn't define should return an error.",
+			args: args{
+				xr:  `{"apiVersion":"example.org/v2","kind":"XBucket","metadata":{"name":"cool-xr"}}`,
+				xrd: xrd,
+			},
+			want: want{
+				xr:  `{"apiVersion":"example.org/v2","kind":"XBucket","metadata":{"name":"cool-xr"}}`,
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xr := &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: xtest.MustLoadJSON(tc.args.xr)}}
+			err := DefaultCompositeResource(xr, tc.args.xrd)
+
+			if diff := cmp.Diff(xtest.MustLoadJSON(tc.want.xr), xr.Object); diff != "" {
+				t.Errorf("%s\nDefaultCompositeResource(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nDefaultCompositeResource(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
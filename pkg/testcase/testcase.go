@@ -0,0 +1,590 @@
+// Package testcase runs test cases that render XRs and check what they render.
+package testcase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane-contrib/xrender/pkg/functions"
+	"github.com/crossplane-contrib/xrender/pkg/load"
+	"github.com/crossplane-contrib/xrender/pkg/output"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+// TestCase API group, version and kind.
+const (
+	APIVersion = "xrender.crossplane.io/v1beta1"
+	Kind       = "TestCase"
+)
+
+// A Case describes an XR to render, and what rendering it should produce.
+// Paths are relative to the directory of the file the test case was loaded
+// from.
+type Case struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Name of the test case. Defaults to the name of the file the test case
+	// was loaded from, and its index in that file.
+	Name string `json:"name,omitempty"`
+
+	// Inputs to render, like the arguments and flags of the render command.
+	CompositeResource  string   `json:"compositeResource"`
+	Composition        string   `json:"composition"`
+	Functions          string   `json:"functions"`
+	ObservedResources  []string `json:"observedResources,omitempty"`
+	EnvironmentConfigs []string `json:"environmentConfigs,omitempty"`
+	XRD                string   `json:"xrd,omitempty"`
+
+	// Context sent to the first step of the Function pipeline.
+	Context map[string]any `json:"context,omitempty"`
+
+	// Assertions about the rendered output. Violated assertions are added to
+	// the rendered Results. Violating a Fatal assertion is an error.
+	Assertions []render.Assertion `json:"assertions,omitempty"`
+
+	// Expect is what rendering should produce.
+	Expect Expectations `json:"expect"`
+
+	// Dir is the directory relative paths are relative to.
+	Dir string `json:"-"`
+}
+
+// Expectations are what rendering a test case should produce.
+type Expectations struct {
+	// Golden is a file containing the full expected output, as written by the
+	// render command with Results included.
+	Golden string `json:"golden,omitempty"`
+
+	// Resources are partial manifests of expected resources. Each must be a
+	// subset of the rendered resource it matches. A resource with a
+	// crossplane.io/composition-resource-name annotation matches the composed
+	// resource with the same annotation. Otherwise it matches the XR.
+	Resources []map[string]any `json:"resources,omitempty"`
+
+	// Results that rendering should return. Results that aren't expected are
+	// allowed.
+	Results []ExpectedResult `json:"results,omitempty"`
+
+	// Error is a regular expression matching the error rendering should
+	// return. Rendering is expected to succeed if it's empty.
+	Error string `json:"error,omitempty"`
+}
+
+// An ExpectedResult matches Results. Empty fields match any Result.
+type ExpectedResult struct {
+	// Step that returned the Result.
+	Step string `json:"step,omitempty"`
+
+	// Assertion that was violated to produce the Result.
+	Assertion string `json:"assertion,omitempty"`
+
+	// Severity of the Result, e.g. Warning or SEVERITY_WARNING.
+	Severity string `json:"severity,omitempty"`
+
+	// Message is a regular expression matching the Result's message.
+	Message string `json:"message,omitempty"`
+}
+
+// A Result is the result of running a test case.
+type Result struct {
+	// Name of the test case.
+	Name string
+
+	// Failures describe how the test case failed. It passed if there are none.
+	Failures []string
+}
+
+// Passed returns true if the test case passed.
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Inputs loads the test case's inputs, like the render command would. The
+// supplied RuntimeConfigs are applied to the Functions.
+func (tc Case) Inputs(rcs ...*functions.RuntimeConfig) (render.Inputs, render.Selection, error) {
+	ecs := make([]string, len(tc.EnvironmentConfigs))
+	for i := range tc.EnvironmentConfigs {
+		ecs[i] = tc.path(tc.EnvironmentConfigs[i])
+	}
+	s, err := load.SharedInputs(load.SharedInputFiles{
+		Compositions:       tc.path(tc.Composition),
+		Functions:          tc.path(tc.Functions),
+		EnvironmentConfigs: ecs,
+		XRD:                tc.path(tc.XRD),
+	})
+	if err != nil {
+		return render.Inputs{}, render.Selection{}, err
+	}
+	s.RuntimeConfigs = rcs
+
+	xr, err := load.CompositeResource(tc.path(tc.CompositeResource))
+	if err != nil {
+		return render.Inputs{}, render.Selection{}, errors.Wrapf(err, "cannot load composite resource from %q", tc.path(tc.CompositeResource))
+	}
+
+	ors := make([]string, len(tc.ObservedResources))
+	for i := range tc.ObservedResources {
+		ors[i] = tc.path(tc.ObservedResources[i])
+	}
+	observed, err := load.Each(ors, load.ObservedResources)
+	if err != nil {
+		return render.Inputs{}, render.Selection{}, errors.Wrap(err, "cannot load observed composed resources")
+	}
+
+	in, sel, err := s.InputsFor(xr, observed)
+	if err != nil {
+		return render.Inputs{}, sel, err
+	}
+	if tc.Context != nil {
+		in.Context, err = structpb.NewStruct(tc.Context)
+		if err != nil {
+			return render.Inputs{}, sel, errors.Wrap(err, "cannot convert context to protobuf Struct")
+		}
+	}
+	return in, sel, nil
+}
+
+// Validate the test case.
+func (tc Case) Validate() error {
+	if tc.APIVersion != APIVersion || tc.Kind != Kind {
+		return errors.Errorf("must be apiVersion: %s, kind: %s", APIVersion, Kind)
+	}
+	return render.ValidateAssertions(tc.Assertions)
+}
+
+func (tc Case) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(tc.Dir, p)
+}
+
+// An Option configures how test cases are run.
+type Option func(o *options)
+
+type options struct {
+	log        logging.Logger
+	ropts      []render.Option
+	rcs        []*functions.RuntimeConfig
+	assertions []render.Assertion
+	update     bool
+	timeout    time.Duration
+}
+
+// WithLogger configures how running test cases logs, including how rendering
+// them logs. Running test cases doesn't log by default.
+func WithLogger(l logging.Logger) Option {
+	return func(o *options) {
+		o.log = l
+	}
+}
+
+// WithRenderOptions configures how test cases are rendered, for example to
+// configure how Functions are started using render.WithFunctionOptions.
+func WithRenderOptions(ro ...render.Option) Option {
+	return func(o *options) {
+		o.ropts = append(o.ropts, ro...)
+	}
+}
+
+// WithRuntimeConfigs configures how to run the Functions test cases use. They
+// take precedence over Function annotations. Later configs take precedence.
+func WithRuntimeConfigs(rcs ...*functions.RuntimeConfig) Option {
+	return func(o *options) {
+		o.rcs = append(o.rcs, rcs...)
+	}
+}
+
+// WithAssertions adds assertions that the rendered output of every test case
+// must satisfy, in addition to its own.
+func WithAssertions(as ...render.Assertion) Option {
+	return func(o *options) {
+		o.assertions = append(o.assertions, as...)
+	}
+}
+
+// WithUpdate configures whether the golden file of each test case that expects
+// one is written, rather than checked.
+func WithUpdate(update bool) Option {
+	return func(o *options) {
+		o.update = update
+	}
+}
+
+// WithTimeout configures how long to run each test case before timing out.
+// Test cases don't time out by default.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+func newOptions(o ...Option) *options {
+	opts := &options{log: logging.NewNopLogger()}
+	for _, fn := range o {
+		fn(opts)
+	}
+	return opts
+}
+
+// Run the supplied test case. It renders the test case and checks that it
+// produced what was expected. Failing to load or render the test case's
+// inputs, and violating a Fatal assertion, are all errors the test case may
+// expect.
+func Run(ctx context.Context, tc Case, o ...Option) Result {
+	opts := newOptions(o...)
+	in, err := opts.load(tc)
+	if err != nil {
+		return opts.check(tc, render.Outputs{}, err)
+	}
+	return opts.run(ctx, tc, in)
+}
+
+// RunAll runs the supplied test cases, like Run. The Functions used by all the
+// test cases are started once, and shared by them. A test case that uses a
+// different Function with the same name as one an earlier test case uses starts
+// its own Functions. If the shared Functions can't be started each test case
+// starts its own, so the test cases that use a Function that can't start
+// report why.
+func RunAll(ctx context.Context, tcs []Case, o ...Option) []Result {
+	opts := newOptions(o...)
+
+	// Test cases may expect their inputs to fail to load, so a test case that
+	// can't be loaded fails individually, like one that can't be rendered.
+	ins := make([]render.Inputs, 0, len(tcs))
+	lerrs := make([]error, len(tcs))
+	for i := range tcs {
+		in, err := opts.load(tcs[i])
+		if err != nil {
+			lerrs[i] = err
+			continue
+		}
+		ins = append(ins, in)
+	}
+
+	rf, shared, err := opts.start(ctx, ins)
+	if err != nil {
+		opts.log.Debug("Cannot start the Functions used by the test cases", "error", err)
+		shared = make([]bool, len(ins))
+	} else {
+		defer rf.Cleanup()
+	}
+
+	results := make([]Result, 0, len(tcs))
+	j := 0 // Index of the next loaded test case's inputs.
+	for i := range tcs {
+		if lerrs[i] != nil {
+			results = append(results, opts.check(tcs[i], render.Outputs{}, lerrs[i]))
+			continue
+		}
+		ro := *opts
+		if shared[j] {
+			ro.ropts = append([]render.Option{render.WithStepRunner(rf)}, opts.ropts...)
+		}
+		results = append(results, ro.run(ctx, tcs[i], ins[j]))
+		j++
+	}
+	return results
+}
+
+// load the inputs of the supplied test case.
+func (o *options) load(tc Case) (render.Inputs, error) {
+	log := o.log.WithValues("test", tc.Name)
+	in, sel, err := tc.Inputs(o.rcs...)
+	if c := sel.Composition.Composition; c != nil {
+		log.Debug("Selected Composition", "xr", in.CompositeResource.GetName(), "composition", c.GetName(), "reason", sel.Composition.Reason)
+	}
+	if r := sel.Revision; r != nil {
+		log.Debug("Selected CompositionRevision", "revision", r.Revision.GetName(), "number", r.Revision.Spec.Revision, "reason", r.Reason)
+	}
+	return in, err
+}
+
+// start the Functions used by the supplied test case inputs once, so the test
+// cases can share them. It returns whether each test case can.
+func (o *options) start(ctx context.Context, ins []render.Inputs) (*functions.Running, []bool, error) {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	return render.StartSharedFunctions(ctx, ins, append([]render.Option{render.WithLogger(o.log)}, o.ropts...)...)
+}
+
+// run renders the supplied test case inputs, then checks the outputs.
+func (o *options) run(ctx context.Context, tc Case, in render.Inputs) Result {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	out, err := o.render(ctx, tc, in)
+	return o.check(tc, out, err)
+}
+
+func (o *options) render(ctx context.Context, tc Case, in render.Inputs) (render.Outputs, error) {
+	log := o.log.WithValues("test", tc.Name)
+	out, err := render.Render(ctx, in, append([]render.Option{render.WithLogger(log)}, o.ropts...)...)
+	if err != nil {
+		return out, err
+	}
+
+	// Show the defaulted XR if we have an XRD.
+	if tc.XRD != "" {
+		out = render.WithCompositeSpec(out, in.CompositeResource)
+	}
+
+	as := append(append([]render.Assertion{}, o.assertions...), tc.Assertions...)
+	out, err = render.WithAssertionResults(out, as)
+	if err != nil {
+		return out, err
+	}
+	return out, render.ViolationsError(render.AssertionResults(out.Results))
+}
+
+// check that the supplied outputs and error of the supplied test case are what
+// it expected.
+func (o *options) check(tc Case, out render.Outputs, err error) Result {
+	r := Result{Name: tc.Name}
+	if tc.Expect.Error != "" || err != nil {
+		if f := checkError(tc.Expect.Error, err); f != "" {
+			r.Failures = append(r.Failures, f)
+		}
+		return r
+	}
+
+	if tc.Expect.Golden != "" {
+		if f := checkGolden(tc.path(tc.Expect.Golden), out, o.update); f != "" {
+			r.Failures = append(r.Failures, f)
+		}
+	}
+	r.Failures = append(r.Failures, checkResources(tc.Expect.Resources, out)...)
+	r.Failures = append(r.Failures, checkResults(tc.Expect.Results, out.Results)...)
+	return r
+}
+
+// checkError returns a failure if the supplied error doesn't match the
+// supplied regular expression. An empty expression expects no error.
+func checkError(want string, err error) string {
+	switch {
+	case want == "" && err != nil:
+		return fmt.Sprintf("unexpected error: %s", err)
+	case want != "" && err == nil:
+		return fmt.Sprintf("expected an error matching %q, got none", want)
+	case want == "":
+		return ""
+	}
+	re, rerr := regexp.Compile(want)
+	if rerr != nil {
+		return fmt.Sprintf("invalid error regular expression %q: %s", want, rerr)
+	}
+	if !re.MatchString(err.Error()) {
+		return fmt.Sprintf("expected an error matching %q, got: %s", want, err)
+	}
+	return ""
+}
+
+// checkGolden returns a failure if the supplied outputs don't match the
+// supplied golden file. If update is true it writes the golden file instead.
+func checkGolden(file string, out render.Outputs, update bool) string {
+	buf := &bytes.Buffer{}
+	if err := output.WriteOutputs(buf, out, true); err != nil {
+		return fmt.Sprintf("cannot write outputs: %s", err)
+	}
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
+			return fmt.Sprintf("cannot create golden file directory: %s", err)
+		}
+		if err := os.WriteFile(file, buf.Bytes(), 0o600); err != nil {
+			return fmt.Sprintf("cannot write golden file %q: %s", file, err)
+		}
+		return ""
+	}
+
+	golden, err := os.ReadFile(file) //nolint:gosec // Taking this input is intentional.
+	if err != nil {
+		return fmt.Sprintf("cannot read golden file %q (update golden files to write it): %s", file, err)
+	}
+	if diff := cmp.Diff(string(golden), buf.String()); diff != "" {
+		return fmt.Sprintf("output doesn't match golden file %q: -want, +got:\n%s", file, diff)
+	}
+	return ""
+}
+
+// checkResources returns a failure for each supplied partial manifest that
+// isn't a subset of the rendered resource it matches.
+func checkResources(want []map[string]any, out render.Outputs) []string {
+	byName := map[string]*unstructured.Unstructured{}
+	for i := range out.ComposedResources {
+		byName[out.ComposedResources[i].GetAnnotations()[render.AnnotationKeyCompositionResourceName]] = &out.ComposedResources[i].Unstructured
+	}
+
+	failures := make([]string, 0)
+	for _, w := range want {
+		u := &unstructured.Unstructured{Object: w}
+		what := "the composite resource"
+		got := &out.CompositeResource.Unstructured
+		if name := u.GetAnnotations()[render.AnnotationKeyCompositionResourceName]; name != "" {
+			what = fmt.Sprintf("composed resource %q", name)
+			got = byName[name]
+		}
+		if got == nil {
+			failures = append(failures, fmt.Sprintf("expected %s, but it wasn't rendered", what))
+			continue
+		}
+		if diff := cmp.Diff(w, subset(w, normalize(got.Object))); diff != "" {
+			failures = append(failures, fmt.Sprintf("%s doesn't match: -want, +got:\n%s", what, diff))
+		}
+	}
+	return failures
+}
+
+// normalize round-trips the supplied object through JSON, so that its numbers
+// are all float64 like those of objects loaded from YAML test cases.
+func normalize(obj map[string]any) any {
+	j, err := json.Marshal(obj)
+	if err != nil {
+		return obj
+	}
+	var out any
+	if err := json.Unmarshal(j, &out); err != nil {
+		return obj
+	}
+	return out
+}
+
+// subset returns the parts of got that are described by want. Arrays must be
+// the same length to be compared element by element.
+func subset(want, got any) any {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return got
+		}
+		out := make(map[string]any, len(w))
+		for k, wv := range w {
+			if gv, ok := g[k]; ok {
+				out[k] = subset(wv, gv)
+			}
+		}
+		return out
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return got
+		}
+		out := make([]any, len(g))
+		for i := range g {
+			out[i] = subset(w[i], g[i])
+		}
+		return out
+	}
+	return got
+}
+
+// checkResults returns a failure for each supplied expected result that
+// doesn't match any of the supplied results.
+func checkResults(want []ExpectedResult, results []unstructured.Unstructured) []string {
+	failures := make([]string, 0)
+	for _, w := range want {
+		re, err := regexp.Compile(w.Message)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid result message regular expression %q: %s", w.Message, err))
+			continue
+		}
+		severity := strings.ToUpper(w.Severity)
+		if severity != "" && !strings.HasPrefix(severity, "SEVERITY_") {
+			severity = "SEVERITY_" + severity
+		}
+
+		found := false
+		for _, r := range results {
+			if matchesResult(w, severity, re, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("expected a result matching %+v, got:\n%s", w, describeResults(results)))
+		}
+	}
+	return failures
+}
+
+func matchesResult(w ExpectedResult, severity string, message *regexp.Regexp, r unstructured.Unstructured) bool {
+	step, _ := r.Object["step"].(string)
+	assertion, _ := r.Object["assertion"].(string)
+	sev, _ := r.Object["severity"].(string)
+	msg, _ := r.Object["message"].(string)
+	return (w.Step == "" || w.Step == step) &&
+		(w.Assertion == "" || w.Assertion == assertion) &&
+		(severity == "" || severity == sev) &&
+		message.MatchString(msg)
+}
+
+func describeResults(results []unstructured.Unstructured) string {
+	if len(results) == 0 {
+		return "  (no results)"
+	}
+	lines := make([]string, len(results))
+	for i, r := range results {
+		what := fmt.Sprintf("step %q", r.Object["step"])
+		if a, ok := r.Object["assertion"]; ok {
+			what = fmt.Sprintf("assertion %q", a)
+		}
+		lines[i] = fmt.Sprintf("  %s returned %s %q", what, r.Object["severity"], r.Object["message"])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Load test cases from a stream or directory of YAML manifests. Each test case
+// resolves relative paths against the directory of the file it was loaded from.
+// It returns an error if a test case isn't a valid TestCase, including if it
+// has an unknown field.
+func Load(fileOrDir string) ([]Case, error) {
+	files, err := load.YAMLFiles(fileOrDir)
+	if err != nil {
+		return nil, err
+	}
+
+	tcs := make([]Case, 0)
+	for _, file := range files {
+		stream, err := load.YAMLStreamFromFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load YAML stream from %q", file)
+		}
+		for i, y := range stream {
+			tc := Case{}
+			if err := yaml.UnmarshalStrict(y, &tc); err != nil {
+				return nil, errors.Wrapf(err, "cannot unmarshal test case YAML from %q", file)
+			}
+			if tc.Name == "" {
+				tc.Name = fmt.Sprintf("%s[%d]", filepath.Base(file), i)
+			}
+			if err := tc.Validate(); err != nil {
+				return nil, errors.Wrapf(err, "invalid test case %q", tc.Name)
+			}
+			tc.Dir = filepath.Dir(file)
+			tcs = append(tcs, tc)
+		}
+	}
+	return tcs, nil
+}
@@ -0,0 +1,380 @@
+package testcase
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+
+	fnv1beta1 "github.com/crossplane/crossplane/apis/apiextensions/fn/proto/v1beta1"
+
+	"github.com/crossplane-contrib/xrender/internal/xtest"
+	"github.com/crossplane-contrib/xrender/pkg/render"
+)
+
+func TestCheckError(t *testing.T) {
+	type args struct {
+		want string
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   bool
+	}{
+		"NoneExpected": {
+			reason: "No error should pass when none is expected.",
+			args:   args{},
+			want:   true,
+		},
+		"Unexpected": {
+			reason: "An error should fail when none is expected.",
+			args:   args{err: errors.New("boom")},
+			want:   false,
+		},
+		"Missing": {
+			reason: "No error should fail when one is expected.",
+			args:   args{want: "boom"},
+			want:   false,
+		},
+		"Matches": {
+			reason: "An error matching the expected regular expression should pass.",
+			args:   args{want: "cannot .* boom", err: errors.New("cannot go: boom")},
+			want:   true,
+		},
+		"DoesNotMatch": {
+			reason: "An error that doesn't match the expected regular expression should fail.",
+			args:   args{want: "^boom$", err: errors.New("cannot go: boom")},
+			want:   false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := checkError(tc.args.want, tc.args.err) == ""
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("%s\ncheckError(...): -want pass, +got pass:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCheckResources(t *testing.T) {
+	out := render.Outputs{
+		CompositeResource: &composite.Unstructured{Unstructured: unstructured.Unstructured{Object: xtest.MustLoadJSON(`{
+			"apiVersion": "example.org/v1",
+			"kind": "XBucket",
+			"metadata": {"name": "cool-xr"},
+			"status": {"ready": true}
+		}`)}},
+		ComposedResources: []composed.Unstructured{{Unstructured: unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "example.org/v1",
+			"kind":       "Bucket",
+			"metadata": map[string]any{
+				"annotations": map[string]any{render.AnnotationKeyCompositionResourceName: "bucket"},
+			},
+			"spec": map[string]any{
+				"size": int64(2),
+				"tags": []any{"a", "b"},
+			},
+		}}}},
+	}
+
+	cases := map[string]struct {
+		reason string
+		want   []map[string]any
+		fails  int
+	}{
+		"CompositeSubset": {
+			reason: "A subset of the XR should pass.",
+			want:   []map[string]any{xtest.MustLoadJSON(`{"status":{"ready":true}}`)},
+		},
+		"ComposedSubset": {
+			reason: "A subset of a composed resource should pass, even if its numbers aren't the same type.",
+			want:   []map[string]any{xtest.MustLoadJSON(`{"metadata":{"annotations":{"crossplane.io/composition-resource-name":"bucket"}},"spec":{"size":2}}`)},
+		},
+		"ComposedMismatch": {
+			reason: "A composed resource with a different value should fail.",
+			want:   []map[string]any{xtest.MustLoadJSON(`{"metadata":{"annotations":{"crossplane.io/composition-resource-name":"bucket"}},"spec":{"tags":["a"]}}`)},
+			fails:  1,
+		},
+		"ComposedMissing": {
+			reason: "A composed resource that wasn't rendered should fail.",
+			want:   []map[string]any{xtest.MustLoadJSON(`{"metadata":{"annotations":{"crossplane.io/composition-resource-name":"policy"}}}`)},
+			fails:  1,
+		},
+		"CompositeMissingField": {
+			reason: "A field the XR doesn't have should fail.",
+			want:   []map[string]any{xtest.MustLoadJSON(`{"status":{"widgets":9001}}`)},
+			fails:  1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := checkResources(tc.want, out)
+			if len(got) != tc.fails {
+				t.Errorf("%s\ncheckResources(...): want %d failures, got %d: %v", tc.reason, tc.fails, len(got), got)
+			}
+		})
+	}
+}
+
+func TestCheckResults(t *testing.T) {
+	results := []unstructured.Unstructured{{Object: map[string]any{
+		"apiVersion": "xrender.crossplane.io/v1beta1",
+		"kind":       "Result",
+		"step":       "patch-and-transform",
+		"severity":   "SEVERITY_WARNING",
+		"message":    "cannot patch field spec.region",
+	}}}
+
+	cases := map[string]struct {
+		reason string
+		want   []ExpectedResult
+		fails  int
+	}{
+		"MatchAll": {
+			reason: "An expected result matching step, severity and message should pass.",
+			want:   []ExpectedResult{{Step: "patch-and-transform", Severity: "Warning", Message: "cannot patch .*region"}},
+		},
+		"MatchSeverity": {
+			reason: "An expected result with only a severity should match any result with that severity.",
+			want:   []ExpectedResult{{Severity: "SEVERITY_WARNING"}},
+		},
+		"WrongSeverity": {
+			reason: "An expected result with a different severity should fail.",
+			want:   []ExpectedResult{{Severity: "Fatal"}},
+			fails:  1,
+		},
+		"WrongMessage": {
+			reason: "An expected result with a message that doesn't match should fail.",
+			want:   []ExpectedResult{{Message: "^patched"}},
+			fails:  1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := checkResults(tc.want, results)
+			if len(got) != tc.fails {
+				t.Errorf("%s\ncheckResults(...): want %d failures, got %d: %v", tc.reason, tc.fails, len(got), got)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	lis := xtest.NewFunction(t, &fnv1beta1.RunFunctionResponse{
+		Desired: &fnv1beta1.State{
+			Composite: &fnv1beta1.Resource{Resource: xtest.MustStructJSON(`{"status":{"widgets":9001}}`)},
+		},
+		Results: []*fnv1beta1.Result{{Severity: fnv1beta1.Severity_SEVERITY_WARNING, Message: "cool warning"}},
+	})
+	defer lis.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"xr.yaml": `
+apiVersion: nop.example.org/v1alpha1
+kind: XNopResource
+metadata:
+  name: test-xrender
+`,
+		"composition.yaml": `
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: test
+spec:
+  compositeTypeRef:
+    apiVersion: nop.example.org/v1alpha1
+    kind: XNopResource
+  mode: Pipeline
+  pipeline:
+  - step: test
+    functionRef:
+      name: function-test
+`,
+		"functions.yaml": fmt.Sprintf(`
+apiVersion: pkg.crossplane.io/v1beta1
+kind: Function
+metadata:
+  name: function-test
+  annotations:
+    xrender.crossplane.io/runtime: Development
+    xrender.crossplane.io/runtime-development-target: %s
+`, lis.Addr()),
+		"tests/cases.yaml": `
+apiVersion: xrender.crossplane.io/v1beta1
+kind: TestCase
+name: widgets
+compositeResource: ../xr.yaml
+composition: ../composition.yaml
+functions: ../functions.yaml
+expect:
+  golden: golden/widgets.yaml
+  resources:
+  - status:
+      widgets: 9001
+  results:
+  - severity: Warning
+    message: cool
+---
+apiVersion: xrender.crossplane.io/v1beta1
+kind: TestCase
+compositeResource: ../xr.yaml
+composition: ../composition.yaml
+functions: ../functions.yaml
+expect:
+  resources:
+  - status:
+      widgets: 9002
+`,
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tcs, err := Load(filepath.Join(dir, "tests"))
+	if err != nil {
+		t.Fatalf("Load(...): %s", err)
+	}
+	if diff := cmp.Diff([]string{"widgets", "cases.yaml[1]"}, []string{tcs[0].Name, tcs[1].Name}); diff != "" {
+		t.Errorf("Load(...): -want names, +got names:\n%s", diff)
+	}
+
+	// The golden file doesn't exist until we update it.
+	if r := Run(context.Background(), tcs[0]); r.Passed() {
+		t.Errorf("Run(...): want failure without a golden file, got pass")
+	}
+	if r := Run(context.Background(), tcs[0], WithUpdate(true)); !r.Passed() {
+		t.Errorf("Run(..., update): want pass, got failures: %v", r.Failures)
+	}
+	if r := Run(context.Background(), tcs[0]); !r.Passed() {
+		t.Errorf("Run(...): want pass with golden file, got failures: %v", r.Failures)
+	}
+	if r := Run(context.Background(), tcs[1]); len(r.Failures) != 1 {
+		t.Errorf("Run(...): want 1 failure, got %v", r.Failures)
+	}
+
+	// RunAll shares the Function the test cases use.
+	rs := RunAll(context.Background(), tcs)
+	if diff := cmp.Diff([]int{0, 1}, []int{len(rs[0].Failures), len(rs[1].Failures)}); diff != "" {
+		t.Errorf("RunAll(...): -want failures, +got failures:\n%s", diff)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	type want struct {
+		names []string
+		err   error
+	}
+	cases := map[string]struct {
+		reason string
+		yaml   string
+		want   want
+	}{
+		"Valid": {
+			reason: "A TestCase should be loaded.",
+			yaml: `
+apiVersion: xrender.crossplane.io/v1beta1
+kind: TestCase
+name: cool
+compositeResource: xr.yaml
+composition: composition.yaml
+functions: functions.yaml
+`,
+			want: want{
+				names: []string{"cool"},
+			},
+		},
+		"WrongKind": {
+			reason: "We should return an error if the manifest isn't a TestCase.",
+			yaml: `
+apiVersion: xrender.crossplane.io/v1beta1
+kind: Assertions
+compositeResource: xr.yaml
+composition: composition.yaml
+functions: functions.yaml
+`,
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"WrongAPIVersion": {
+			reason: "We should return an error if the manifest has an unknown apiVersion.",
+			yaml: `
+apiVersion: xrender.crossplane.io/v1
+kind: TestCase
+compositeResource: xr.yaml
+composition: composition.yaml
+functions: functions.yaml
+`,
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"NoTypeMeta": {
+			reason: "We should return an error if the manifest has no apiVersion or kind.",
+			yaml: `
+compositeResource: xr.yaml
+composition: composition.yaml
+functions: functions.yaml
+`,
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+		"UnknownField": {
+			reason: "We should return an error if the manifest has an unknown field.",
+			yaml: `
+apiVersion: xrender.crossplane.io/v1beta1
+kind: TestCase
+compositeResource: xr.yaml
+composition: composition.yaml
+functions: functions.yaml
+expected:
+  error: boom
+`,
+			want: want{
+				err: cmpopts.AnyError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "cases.yaml")
+			if err := os.WriteFile(file, []byte(tc.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			tcs, err := Load(file)
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nLoad(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			var names []string
+			for _, c := range tcs {
+				names = append(names, c.Name)
+			}
+			if diff := cmp.Diff(tc.want.names, names); diff != "" {
+				t.Errorf("\n%s\nLoad(...): -want names, +got names:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
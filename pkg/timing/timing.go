@@ -0,0 +1,96 @@
+// Package timing records how long each phase of rendering an XR took.
+package timing
+
+import (
+	"sync"
+	"time"
+)
+
+// A Phase of rendering an XR.
+type Phase string
+
+// Phases of rendering an XR.
+const (
+	// PhasePull is pulling a Function's runtime image.
+	PhasePull Phase = "Pull"
+
+	// PhaseStart is starting a Function, for example by creating and starting
+	// a container. It doesn't include pulling an image.
+	PhaseStart Phase = "Start"
+
+	// PhaseReady is waiting for a started Function to be ready to serve
+	// requests.
+	PhaseReady Phase = "Ready"
+
+	// PhaseRun is running a pipeline step, i.e. calling RunFunction.
+	PhaseRun Phase = "Run"
+
+	// PhaseEncode is encoding the rendered output.
+	PhaseEncode Phase = "Encode"
+)
+
+// A Record of how long a phase took.
+type Record struct {
+	// Phase that was timed.
+	Phase Phase
+
+	// Function the phase concerned, if any.
+	Function string
+
+	// Step of the pipeline the phase concerned, if any.
+	Step string
+
+	// Duration of the phase.
+	Duration time.Duration
+
+	// RequestBytes is the size of the request sent during the phase, if any.
+	RequestBytes int
+
+	// ResponseBytes is the size of the response received during the phase,
+	// if any.
+	ResponseBytes int
+}
+
+// A Recorder records how long phases took. It's safe for concurrent use. A
+// nil Recorder discards what it's asked to record.
+type Recorder struct {
+	mx      sync.Mutex
+	records []Record
+}
+
+// NewRecorder returns a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{records: make([]Record, 0)}
+}
+
+// Record how long a phase took.
+func (r *Recorder) Record(rec Record) {
+	if r == nil {
+		return
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.records = append(r.records, rec)
+}
+
+// Enabled returns true if the Recorder records what it's asked to record.
+func (r *Recorder) Enabled() bool {
+	return r != nil
+}
+
+// Records returns everything that has been recorded, in the order it was
+// recorded.
+func (r *Recorder) Records() []Record {
+	if r == nil {
+		return nil
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]Record{}, r.records...)
+}
+
+// Since records how long it has been since the supplied time.
+func (r *Recorder) Since(start time.Time, rec Record) {
+	rec.Duration = time.Since(start)
+	r.Record(rec)
+}
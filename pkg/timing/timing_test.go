@@ -0,0 +1,40 @@
+package timing
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Record(Record{Phase: PhaseRun})
+		}()
+	}
+	wg.Wait()
+
+	if got := len(r.Records()); got != 10 {
+		t.Errorf("Records(): want 10 records, got %d", got)
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+
+	if r.Enabled() {
+		t.Errorf("Enabled(): want false for a nil Recorder")
+	}
+
+	// Recording to a nil Recorder should be a no-op.
+	r.Record(Record{Phase: PhaseRun})
+	if diff := cmp.Diff([]Record(nil), r.Records()); diff != "" {
+		t.Errorf("Records(): -want, +got:\n%s", diff)
+	}
+}